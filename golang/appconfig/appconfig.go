@@ -0,0 +1,167 @@
+// Package appconfig loads the database and server settings shared by the
+// customer modules. Values are merged from, in increasing precedence:
+// built-in defaults, a JSON/YAML/TOML file, DB_* / SERVER_* environment
+// variables and command-line flags. Every value remembers which source
+// set it. A module can have further sections of its own loaded the same
+// way; see Options.Sections.
+package appconfig
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type Config struct {
+	DB     DB     `config:"db"`
+	Server Server `config:"server"`
+
+	// Options.Sections, if any
+	sections any
+
+	// key ("db.hostname") -> source ("env DB_HOST")
+	sources map[string]string
+}
+
+type DB struct {
+	Driver   string `config:"driver" env:"DB_DRIVER" default:"mysql"`
+	Hostname string `config:"hostname" env:"DB_HOST" default:"localhost"`
+	Port     int    `config:"port" env:"DB_PORT" default:"3306"`
+	User     string `config:"user" env:"DB_USER" default:"root"`
+	Password string `config:"password" env:"DB_PASSWORD" secret:"true"`
+	Database string `config:"database" env:"DB_DATABASE" default:"customersdb"`
+
+	// connection pool settings; zero values keep the database/sql defaults
+	MaxOpenConns    int           `config:"maxOpenConns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `config:"maxIdleConns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `config:"connMaxLifetime" env:"DB_CONN_MAX_LIFETIME"`
+}
+
+type Server struct {
+	Host string `config:"host" env:"SERVER_HOST" default:"0.0.0.0"`
+	Port int    `config:"port" env:"SERVER_PORT" default:"7788"`
+
+	// see http.Server; after SIGTERM the server keeps serving, failing
+	// /readyz, for DrainPeriod so that load balancers notice, then
+	// ShutdownTimeout bounds how long in-flight requests may take to finish
+	ReadHeaderTimeout time.Duration `config:"readHeaderTimeout" env:"SERVER_READ_HEADER_TIMEOUT" default:"5s"`
+	ReadTimeout       time.Duration `config:"readTimeout" env:"SERVER_READ_TIMEOUT" default:"15s"`
+	WriteTimeout      time.Duration `config:"writeTimeout" env:"SERVER_WRITE_TIMEOUT" default:"30s"`
+	IdleTimeout       time.Duration `config:"idleTimeout" env:"SERVER_IDLE_TIMEOUT" default:"60s"`
+	DrainPeriod       time.Duration `config:"drainPeriod" env:"SERVER_DRAIN_PERIOD" default:"5s"`
+	ShutdownTimeout   time.Duration `config:"shutdownTimeout" env:"SERVER_SHUTDOWN_TIMEOUT" default:"20s"`
+}
+
+// Drivers lists the supported values of DB.Driver. They are database/sql
+// driver names; the program must import the matching driver.
+var Drivers = []string{"mysql", "sqlite"}
+
+// DSN returns the data source name for DB.Driver. For sqlite, Database is
+// a file path, or ":memory:" for a private in-memory database, and may
+// carry parameters of its own ("file:customers.db?mode=ro").
+func (db DB) DSN() string {
+	if db.Driver == "sqlite" {
+		separator := "?"
+		if strings.Contains(db.Database, "?") {
+			separator = "&"
+		}
+		return db.Database + separator + "_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"
+	}
+	config := mysql.NewConfig()
+	config.User, config.Passwd = db.User, db.Password
+	config.Net, config.Addr = "tcp", net.JoinHostPort(db.Hostname, strconv.Itoa(db.Port))
+	config.DBName = db.Database
+	// clientFoundRows makes RowsAffected report matched rows, so an UPDATE
+	// that changes nothing is not mistaken for a missing row
+	config.ClientFoundRows, config.ParseTime = true, true
+	return config.FormatDSN()
+}
+
+// InMemory reports whether DB names an in-memory sqlite database, which
+// lives only as long as its single connection.
+func (db DB) InMemory() bool {
+	return db.Driver == "sqlite" && (db.Database == ":memory:" || strings.Contains(db.Database, "mode=memory"))
+}
+
+// Addr returns the host:port the HTTP server listens on.
+func (s Server) Addr() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
+
+// Check reports key invalid unless ok; format and args tell why.
+type Check func(ok bool, key, format string, args ...any)
+
+// Validator is implemented by Options.Sections that check their own
+// values. Their problems are reported along with those of DB and Server.
+type Validator interface {
+	Validate(check Check)
+}
+
+// Validate reports every invalid value at once.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, key, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s (from %s): %s", key, c.Source(key), fmt.Sprintf(format, args...)))
+		}
+	}
+
+	check(slices.Contains(Drivers, c.DB.Driver), "db.driver", "unsupported driver %q; use one of %v", c.DB.Driver, Drivers)
+	if c.DB.Driver == "mysql" {
+		check(c.DB.Hostname != "", "db.hostname", "must not be empty")
+		check(c.DB.Port > 0 && c.DB.Port < 65536, "db.port", "must be between 1 and 65535")
+		check(c.DB.User != "", "db.user", "must not be empty")
+	}
+	check(c.DB.Database != "", "db.database", "must not be empty")
+	check(c.DB.MaxOpenConns >= 0, "db.maxOpenConns", "must not be negative")
+	check(c.DB.MaxIdleConns >= 0, "db.maxIdleConns", "must not be negative")
+	check(c.DB.MaxOpenConns == 0 || c.DB.MaxIdleConns <= c.DB.MaxOpenConns,
+		"db.maxIdleConns", "must not exceed db.maxOpenConns (%d)", c.DB.MaxOpenConns)
+	check(c.DB.ConnMaxLifetime >= 0, "db.connMaxLifetime", "must not be negative")
+	check(c.Server.Port > 0 && c.Server.Port < 65536, "server.port", "must be between 1 and 65535")
+	check(c.Server.ReadHeaderTimeout >= 0, "server.readHeaderTimeout", "must not be negative")
+	check(c.Server.ReadTimeout >= 0, "server.readTimeout", "must not be negative")
+	check(c.Server.WriteTimeout >= 0, "server.writeTimeout", "must not be negative")
+	check(c.Server.IdleTimeout >= 0, "server.idleTimeout", "must not be negative")
+	check(c.Server.DrainPeriod >= 0, "server.drainPeriod", "must not be negative")
+	check(c.Server.ShutdownTimeout >= 0, "server.shutdownTimeout", "must not be negative")
+	if v, ok := c.sections.(Validator); ok {
+		v.Validate(check)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// Source tells which source set key, e.g. "default", "file config.json",
+// "env DB_HOST" or "flag -db-hostname".
+func (c *Config) Source(key string) string {
+	if source, ok := c.sources[key]; ok {
+		return source
+	}
+	return "default"
+}
+
+// String lists every setting with its source; secrets are redacted, so the
+// result is safe to log.
+func (c *Config) String() string {
+	var sb strings.Builder
+	for _, f := range c.fields() {
+		value := fmt.Sprint(f.value.Interface())
+		if list, ok := f.value.Interface().([]string); ok {
+			value = strings.Join(list, ",")
+		}
+		if f.secret && value != "" {
+			value = "********"
+		}
+		fmt.Fprintf(&sb, "%-22s = %-20s (%s)\n", f.key, value, c.Source(f.key))
+	}
+	return sb.String()
+}
@@ -0,0 +1,234 @@
+package appconfig
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func env(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("defaults only", func(t *testing.T) {
+		c, err := Load(Options{Getenv: env(nil)})
+		if err != nil {
+			t.Fatalf("was not expecting an error, got %v", err)
+		}
+		if c.DB.Port != 3306 || c.Server.Port != 7788 || c.Source("db.port") != "default" {
+			t.Errorf("wanted default ports, got %v and %v", c.DB.Port, c.Server.Port)
+		}
+	})
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"config.json", `{"db": {"hostname": "dbhost", "port": 3307, "connMaxLifetime": "5m"}}`},
+		{"config.yaml", "db:\n  hostname: dbhost\n  port: 3307\n  connMaxLifetime: 5m\n"},
+		{"config.toml", "[db]\nhostname = \"dbhost\"\nport = 3307\nconnMaxLifetime = \"5m\"\n"},
+	}
+	for _, f := range files {
+		t.Run(f.name, func(t *testing.T) {
+			filename := writeFile(t, f.name, f.content)
+			c, err := Load(Options{File: filename, Getenv: env(nil)})
+			if err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			if c.DB.Hostname != "dbhost" || c.DB.Port != 3307 || c.DB.ConnMaxLifetime != 5*time.Minute {
+				t.Errorf("file values were not applied: %+v", c.DB)
+			}
+			if c.Source("db.hostname") != "file "+filename {
+				t.Errorf("wanted source `file %v`, got `%v`", filename, c.Source("db.hostname"))
+			}
+		})
+	}
+
+	t.Run("env overrides file, flag overrides env", func(t *testing.T) {
+		filename := writeFile(t, "config.json", `{"db": {"hostname": "filehost", "user": "fileuser"}}`)
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		RegisterFlags(fs)
+		fs.Parse([]string{"-db-hostname", "flaghost"})
+
+		c, err := Load(Options{
+			File:   filename,
+			Flags:  fs,
+			Getenv: env(map[string]string{"DB_HOST": "envhost", "DB_USER": "envuser"}),
+		})
+		if err != nil {
+			t.Fatalf("was not expecting an error, got %v", err)
+		}
+		if c.DB.Hostname != "flaghost" || c.Source("db.hostname") != "flag -db-hostname" {
+			t.Errorf("wanted flaghost from the flag, got %v from %v", c.DB.Hostname, c.Source("db.hostname"))
+		}
+		if c.DB.User != "envuser" || c.Source("db.user") != "env DB_USER" {
+			t.Errorf("wanted envuser from env, got %v from %v", c.DB.User, c.Source("db.user"))
+		}
+	})
+
+	negativeTests := []struct {
+		name   string
+		file   string
+		env    map[string]string
+		errmsg string
+	}{
+		{"unknown setting", `{"db": {"hostnme": "x"}}`, nil, `unknown setting "db.hostnme"`},
+		{"not a number", `{}`, map[string]string{"DB_PORT": "abc"}, `db.port (from env DB_PORT): "abc" is not a number`},
+		{"invalid port", `{"server": {"port": 99999}}`, nil, "server.port"},
+		{"unknown driver", `{"db": {"driver": "oracle"}}`, nil, `unsupported driver "oracle"`},
+		{"idle above open", `{"db": {"maxOpenConns": 5, "maxIdleConns": 10}}`, nil, "db.maxIdleConns"},
+		{"negative drain period", `{"server": {"drainPeriod": "-1s"}}`, nil, "server.drainPeriod"},
+	}
+	for _, nt := range negativeTests {
+		t.Run(nt.name, func(t *testing.T) {
+			filename := writeFile(t, "config.json", nt.file)
+			_, err := Load(Options{File: filename, Getenv: env(nt.env)})
+			if err == nil {
+				t.Fatal("was expecting an error; did not get one")
+			}
+			if !strings.Contains(err.Error(), nt.errmsg) {
+				t.Errorf("wanted error containing '%v', got '%v'", nt.errmsg, err.Error())
+			}
+		})
+	}
+}
+
+// sections stands in for the sections of a program.
+type sections struct {
+	Feature struct {
+		Enabled bool     `config:"enabled" env:"FEATURE_ENABLED" default:"true"`
+		Origins []string `config:"origins" env:"FEATURE_ORIGINS"`
+		Methods []string `config:"methods" env:"FEATURE_METHODS" default:"GET"`
+		Limit   int      `config:"limit" env:"FEATURE_LIMIT" default:"10"`
+	} `config:"feature"`
+}
+
+func (s *sections) Validate(check Check) {
+	check(s.Feature.Limit > 0, "feature.limit", "must be positive")
+}
+
+func TestSections(t *testing.T) {
+	filename := writeFile(t, "config.yaml", "feature:\n  origins:\n    - https://www.redbus.in\n    - https://*.redbus.in\n")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	RegisterFlags(fs, &sections{})
+	fs.Parse([]string{"-feature-enabled=false", "-db-hostname", "flaghost"})
+
+	var s sections
+	c, err := Load(Options{File: filename, Flags: fs, Sections: &s,
+		Getenv: env(map[string]string{"FEATURE_METHODS": "GET, POST"})})
+	if err != nil {
+		t.Fatalf("was not expecting an error, got %v", err)
+	}
+	if len(s.Feature.Origins) != 2 || s.Feature.Origins[1] != "https://*.redbus.in" {
+		t.Errorf("wanted 2 origins from the file, got %v", s.Feature.Origins)
+	}
+	if len(s.Feature.Methods) != 2 || s.Feature.Methods[1] != "POST" || c.Source("feature.methods") != "env FEATURE_METHODS" {
+		t.Errorf("wanted GET and POST from env, got %v from %v", s.Feature.Methods, c.Source("feature.methods"))
+	}
+	if s.Feature.Enabled || c.Source("feature.enabled") != "flag -feature-enabled" || c.DB.Hostname != "flaghost" {
+		t.Errorf("wanted the flags applied, got %v and %v", s.Feature.Enabled, c.DB.Hostname)
+	}
+	if !strings.Contains(c.String(), "feature.limit") {
+		t.Errorf("wanted the sections listed, got\n%v", c)
+	}
+
+	t.Run("bare boolean flag", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		RegisterFlags(fs, &sections{})
+		if err := fs.Parse([]string{"-feature-enabled", "-server-port", "8080"}); err != nil {
+			t.Fatalf("was not expecting an error, got %v", err)
+		}
+		var s sections
+		c, err := Load(Options{Flags: fs, Sections: &s, Getenv: env(map[string]string{"FEATURE_ENABLED": "false"})})
+		if err != nil {
+			t.Fatalf("was not expecting an error, got %v", err)
+		}
+		if !s.Feature.Enabled || c.Server.Port != 8080 {
+			t.Errorf("wanted enabled on port 8080, got %v on %v", s.Feature.Enabled, c.Server.Port)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		filename := writeFile(t, "config.json", `{"feature": {"limit": 0}, "server": {"port": 0}}`)
+		_, err := Load(Options{File: filename, Sections: &sections{}, Getenv: env(nil)})
+		if err == nil {
+			t.Fatal("was expecting an error; did not get one")
+		}
+		// with the problems of DB and Server
+		if !strings.Contains(err.Error(), "feature.limit") || !strings.Contains(err.Error(), "server.port") {
+			t.Errorf("wanted both problems, got '%v'", err.Error())
+		}
+	})
+
+	t.Run("unknown without sections", func(t *testing.T) {
+		filename := writeFile(t, "config.json", `{"feature": {"limit": 5}}`)
+		if _, err := Load(Options{File: filename, Getenv: env(nil)}); err == nil {
+			t.Error("was expecting an error; did not get one")
+		}
+	})
+}
+
+func TestDSN(t *testing.T) {
+	subtests := []struct {
+		name     string
+		db       DB
+		want     string
+		inMemory bool
+	}{
+		{"mysql", DB{Driver: "mysql", User: "root", Password: "pw", Hostname: "localhost", Port: 3306, Database: "customersdb"},
+			"root:pw@tcp(localhost:3306)/customersdb?clientFoundRows=true&parseTime=true", false},
+		{"mysql password to escape", DB{Driver: "mysql", User: "app", Password: "p@ss/w?rd", Hostname: "db", Port: 3306, Database: "customersdb"},
+			"app:p@ss/w?rd@tcp(db:3306)/customersdb?clientFoundRows=true&parseTime=true", false},
+		{"sqlite file", DB{Driver: "sqlite", Database: "customers.db"},
+			"customers.db?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)", false},
+		{"sqlite memory", DB{Driver: "sqlite", Database: ":memory:"},
+			":memory:?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)", true},
+		{"sqlite shared memory", DB{Driver: "sqlite", Database: "file:customers?mode=memory&cache=shared"},
+			"file:customers?mode=memory&cache=shared&_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)", true},
+	}
+	for _, st := range subtests {
+		t.Run(st.name, func(t *testing.T) {
+			if got := st.db.DSN(); got != st.want {
+				t.Errorf("wanted %v, got %v", st.want, got)
+			}
+			if st.db.Driver == "mysql" {
+				// the driver must read back what was put in
+				c, err := mysql.ParseDSN(st.db.DSN())
+				if err != nil || c.User != st.db.User || c.Passwd != st.db.Password || c.DBName != st.db.Database {
+					t.Errorf("wanted %s/%s/%s back, got %+v (%v)", st.db.User, st.db.Password, st.db.Database, c, err)
+				}
+			}
+			if got := st.db.InMemory(); got != st.inMemory {
+				t.Errorf("wanted InMemory() %v, got %v", st.inMemory, got)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	c, err := Load(Options{Getenv: env(map[string]string{"DB_PASSWORD": "Welcome#123"})})
+	if err != nil {
+		t.Fatalf("was not expecting an error, got %v", err)
+	}
+	s := c.String()
+	if strings.Contains(s, "Welcome#123") {
+		t.Error("password was not redacted")
+	}
+	if !strings.Contains(s, "env DB_PASSWORD") {
+		t.Errorf("was expecting the source of the password, got\n%v", s)
+	}
+}
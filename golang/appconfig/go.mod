@@ -0,0 +1,9 @@
+module appconfig
+
+go 1.22.0
+
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/go-sql-driver/mysql v1.7.1
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,290 @@
+package appconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Options tells Load where to look for settings.
+type Options struct {
+	// File is a .json, .yaml/.yml or .toml file; empty means no file. The
+	// -config flag, when set, takes its place.
+	File string
+
+	// Flags is a parsed flag set that RegisterFlags was called on; nil
+	// skips flags.
+	Flags *flag.FlagSet
+
+	// Getenv defaults to os.Getenv.
+	Getenv func(string) string
+
+	// Sections, when not nil, points to a struct of further sections
+	// tagged like those of Config, e.g. the settings of one program. Load
+	// fills it in along with DB and Server; see also Validator.
+	Sections any
+}
+
+const configFlag = "config"
+
+// RegisterFlags adds -config plus one flag per setting (e.g. -db-hostname,
+// -server-port) to fs, including those of the sections that will be
+// passed to Load as Options.Sections. Only flags given on the command
+// line override the other sources; a bare boolean flag sets true.
+func RegisterFlags(fs *flag.FlagSet, sections ...any) {
+	fs.String(configFlag, "", "configuration file (.json, .yaml or .toml)")
+	all := fields(&Config{})
+	for _, s := range sections {
+		all = append(all, fields(s)...)
+	}
+	for _, f := range all {
+		usage := "overrides " + f.key
+		if f.env != "" {
+			usage += " (env " + f.env + ")"
+		}
+		if f.value.Kind() == reflect.Bool {
+			def, _ := strconv.ParseBool(f.def)
+			fs.Bool(f.flag, def, usage)
+		} else {
+			fs.String(f.flag, "", usage)
+		}
+	}
+}
+
+// Load merges defaults, the configuration file, environment variables and
+// flags, in that order, and validates the result.
+func Load(opts Options) (*Config, error) {
+	if opts.Getenv == nil {
+		opts.Getenv = os.Getenv
+	}
+
+	c := &Config{sources: map[string]string{}, sections: opts.Sections}
+	all := c.fields()
+	byKey := map[string]field{}
+	byFlag := map[string]field{}
+	for _, f := range all {
+		if f.def != "" {
+			if err := f.set(f.def); err != nil {
+				return nil, fmt.Errorf("default for %s: %w", f.key, err)
+			}
+		}
+		byKey[strings.ToLower(f.key)] = f
+		byFlag[f.flag] = f
+	}
+
+	file := opts.File
+	if opts.Flags != nil {
+		if fl := opts.Flags.Lookup(configFlag); fl != nil && fl.Value.String() != "" {
+			file = fl.Value.String()
+		}
+	}
+	if file != "" {
+		values, err := readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range values {
+			f, ok := byKey[strings.ToLower(key)]
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown setting %q", file, key)
+			}
+			if err := c.apply(f, value, "file "+file); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, f := range all {
+		if f.env == "" {
+			continue
+		}
+		if value := opts.Getenv(f.env); value != "" {
+			if err := c.apply(f, value, "env "+f.env); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if opts.Flags != nil {
+		var err error
+		opts.Flags.Visit(func(fl *flag.Flag) {
+			if f, ok := byFlag[fl.Name]; ok && err == nil {
+				err = c.apply(f, fl.Value.String(), "flag -"+fl.Name)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) apply(f field, value, source string) error {
+	if err := f.set(value); err != nil {
+		return fmt.Errorf("%s (from %s): %w", f.key, source, err)
+	}
+	c.sources[f.key] = source
+	return nil
+}
+
+// readFile decodes a configuration file and flattens it to dotted keys,
+// e.g. {"db": {"port": 3306}} becomes "db.port" -> "3306".
+func readFile(filename string) (map[string]string, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := map[string]any{}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.UseNumber()
+		err = decoder.Decode(&tree)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &tree)
+	case ".toml":
+		err = toml.Unmarshal(content, &tree)
+	default:
+		return nil, fmt.Errorf("%s: unsupported configuration format; use .json, .yaml or .toml", filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	values := map[string]string{}
+	var flatten func(prefix string, node map[string]any)
+	flatten = func(prefix string, node map[string]any) {
+		for k, v := range node {
+			switch v := v.(type) {
+			case map[string]any:
+				flatten(prefix+k+".", v)
+			case []any:
+				// lists are set like in env and flags: comma separated
+				items := make([]string, len(v))
+				for i, item := range v {
+					items[i] = fmt.Sprint(item)
+				}
+				values[prefix+k] = strings.Join(items, ",")
+			default:
+				values[prefix+k] = fmt.Sprint(v)
+			}
+		}
+	}
+	flatten("", tree)
+	return values, nil
+}
+
+// field is one setting of Config or its sections, found through its
+// struct tags.
+type field struct {
+	key    string // "db.hostname"
+	env    string // "DB_HOST"
+	flag   string // "db-hostname"
+	def    string
+	secret bool
+	value  reflect.Value
+}
+
+// fields returns the settings of Config and its sections, by key.
+func (c *Config) fields() []field {
+	all := fields(c)
+	if c.sections != nil {
+		all = append(all, fields(c.sections)...)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].key < all[j].key })
+	return all
+}
+
+// fields returns the settings of the sections of the struct sections
+// points to.
+func fields(sections any) []field {
+	var all []field
+	root := reflect.ValueOf(sections).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Type().Field(i)
+		prefix := section.Tag.Get("config")
+		if prefix == "" {
+			continue
+		}
+		for j := 0; j < section.Type.NumField(); j++ {
+			sf := section.Type.Field(j)
+			name := sf.Tag.Get("config")
+			all = append(all, field{
+				key:    prefix + "." + name,
+				env:    sf.Tag.Get("env"),
+				flag:   prefix + "-" + toKebab(name),
+				def:    sf.Tag.Get("default"),
+				secret: sf.Tag.Get("secret") == "true",
+				value:  root.Field(i).Field(j),
+			})
+		}
+	}
+	return all
+}
+
+func (f field) set(s string) error {
+	switch f.value.Interface().(type) {
+	case string:
+		f.value.SetString(s)
+	case int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", s)
+		}
+		f.value.SetInt(int64(n))
+	case time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration like \"30s\" or \"5m\"", s)
+		}
+		f.value.SetInt(int64(d))
+	case bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", s)
+		}
+		f.value.SetBool(b)
+	case []string:
+		items := []string{}
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", f.value.Type())
+	}
+	return nil
+}
+
+// toKebab turns "maxOpenConns" into "max-open-conns".
+func toKebab(name string) string {
+	var sb strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				sb.WriteByte('-')
+			}
+			r += 'a' - 'A'
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -1,35 +1,19 @@
 package dao
 
 import (
+	"appconfig"
 	"database/sql"
-	"encoding/json"
-	"fmt"
 	"miniproj/utils"
-	"os"
 
+	_ "github.com/glebarez/go-sqlite"
 	_ "github.com/go-sql-driver/mysql"
 )
 
-type Config struct {
-	Driver   string `json:"driver"`
-	Hostname string `json:"hostname"`
-	Port     string `json:"port"`
-	User     string `json:"user"`
-	Password string `json:"password"`
-	Database string `json:"database"`
-}
-
 func connect() *sql.DB {
-	file, err := os.Open("config.json")
+	config, err := appconfig.Load(appconfig.Options{File: "config.json"})
 	utils.CheckForError(err)
 
-	var config Config
-	json.NewDecoder(file).Decode(&config)
-
-	connStr := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s",
-		config.User, config.Password, config.Hostname, config.Port, config.Database)
-
-	db, err := sql.Open("mysql", connStr)
+	db, err := sql.Open(config.DB.Driver, config.DB.DSN())
 	utils.CheckForError(err)
 
 	return db
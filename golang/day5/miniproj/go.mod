@@ -2,4 +2,24 @@ module miniproj
 
 go 1.22.0
 
-require github.com/go-sql-driver/mysql v1.7.1 // indirect
+require (
+	appconfig v0.0.0
+	github.com/glebarez/go-sqlite v1.22.0
+	github.com/go-sql-driver/mysql v1.7.1
+)
+
+require (
+	github.com/BurntSushi/toml v1.4.0 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.5.0 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/sys v0.15.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+	modernc.org/libc v1.37.6 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.7.2 // indirect
+	modernc.org/sqlite v1.28.0 // indirect
+)
+
+replace appconfig => ../../appconfig
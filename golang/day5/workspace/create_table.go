@@ -4,9 +4,16 @@ import "fmt"
 
 func CreateTable() {
 	db := getDb()
+	defer db.Close()
+
+	// MySQL and SQLite spell auto increment differently
+	autoIncrement := "AUTO_INCREMENT"
+	if getConfig().DB.Driver == "sqlite" {
+		autoIncrement = "AUTOINCREMENT"
+	}
 
 	sql := `CREATE TABLE CUSTOMERS (
-		ID INTEGER PRIMARY KEY AUTO_INCREMENT,
+		ID INTEGER PRIMARY KEY ` + autoIncrement + `,
 		NAME varchar(50) NOT NULL,
 		EMAIL varchar(50) UNIQUE,
 		CITY varchar(50)
@@ -1,6 +1,14 @@
 package main
 
+import (
+	"appconfig"
+	"flag"
+)
+
 func main() {
+	appconfig.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
 	// AcceptAndAddCustomerData()
 	// AddCustomerData()
 	GetOneCustomer()
@@ -1,20 +1,30 @@
 package main
 
 import (
+	"appconfig"
 	"bufio"
 	"database/sql"
+	"flag"
 	"fmt"
 	"os"
 	"strings"
 
+	_ "github.com/glebarez/go-sqlite"
 	_ "github.com/go-sql-driver/mysql"
 )
 
+// getConfig reads config.json, DB_* environment variables and the -db-*
+// flags; the password is expected in DB_PASSWORD or -db-password.
+func getConfig() *appconfig.Config {
+	config, err := appconfig.Load(appconfig.Options{File: "config.json", Flags: flag.CommandLine})
+	checkForError(err)
+	return config
+}
+
 func getDb() *sql.DB {
-	driver := "mysql"
-	connStr := "root:Welcome#123@tcp(172.16.10.68:3306)/customersdb"
+	config := getConfig()
 
-	db, err := sql.Open(driver, connStr)
+	db, err := sql.Open(config.DB.Driver, config.DB.DSN())
 	checkForError(err)
 	return db
 }
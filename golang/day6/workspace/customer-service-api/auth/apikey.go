@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// RoleAdmin grants the API key administration endpoints. API keys can not
+// be issued with it.
+const RoleAdmin = "admin"
+
+// APIKeyRoles are the scopes an API key may be issued with.
+var APIKeyRoles = []string{RoleCustomersRead, RoleCustomersWrite}
+
+// NewAPIKey returns a random key of the form csk_<prefix>_<secret>, its
+// prefix and its hash.
+func NewAPIKey() (key, prefix, hash string) {
+	b := make([]byte, 28)
+	rand.Read(b)
+	prefix = hex.EncodeToString(b[:4])
+	key = "csk_" + prefix + "_" + base64.RawURLEncoding.EncodeToString(b[4:])
+	return key, prefix, HashAPIKey(key)
+}
+
+// HashAPIKey returns the hex SHA-256 a key is stored under. The keys are
+// random enough that a plain hash is as safe as a password hash.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+)
+
+type claimsKey struct{}
+
+// WithClaims returns a copy of ctx carrying the caller's claims.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFrom returns the claims in ctx, or nil for an unauthenticated
+// request.
+func ClaimsFrom(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(claimsKey{}).(*Claims)
+	return claims
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx naming actor as the one acting, for
+// work the service does on its own rather than for a caller.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor names the caller in ctx for the records it leaves behind: the
+// actor set with WithActor, its API key as "key:<id>", its token as
+// "sub:<subject>", or "anonymous" when authentication is off.
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok {
+		return actor
+	}
+	claims := ClaimsFrom(ctx)
+	switch {
+	case claims == nil:
+		return "anonymous"
+	case claims.APIKey != nil:
+		return "key:" + strconv.Itoa(claims.APIKey.Id)
+	}
+	return "sub:" + claims.Subject
+}
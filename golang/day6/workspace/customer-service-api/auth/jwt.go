@@ -0,0 +1,272 @@
+// Package auth verifies the JWT bearer tokens presented to the API and
+// carries the verified claims through the request context.
+//
+// Only the compact JWS serialization signed with HS256 or RS256 is
+// accepted. Which of the two a token may use follows from the configured
+// key it names, never from the token alone, so an RSA public key can not
+// be abused as an HMAC secret.
+package auth
+
+import (
+	"api/model"
+	"api/settings"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Roles checked by the customer API.
+const (
+	RoleCustomersRead  = "customers:read"
+	RoleCustomersWrite = "customers:write"
+)
+
+// ErrInvalidToken is wrapped by every error Verify returns.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims are the registered claims the API looks at plus the roles of the
+// caller, taken from a "roles" array and from the space separated OAuth
+// "scope" claim. Callers authenticated with an API key get claims made up
+// from the key, with APIKey set.
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+	Roles     []string
+	APIKey    *model.APIKey
+}
+
+// HasRole reports whether the caller was granted role.
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+// Verifier checks token signatures and the exp, nbf, iss and aud claims.
+type Verifier struct {
+	hmacSecret []byte
+	rsaKeys    map[string]*rsa.PublicKey // by kid; "" for a PEM key
+	issuer     string
+	audience   string
+	leeway     time.Duration
+	now        func() time.Time
+}
+
+// NewVerifier builds a Verifier from the auth configuration, reading the
+// RSA public key and JWKS files it names.
+func NewVerifier(config settings.Auth) (*Verifier, error) {
+	v := &Verifier{
+		rsaKeys:  map[string]*rsa.PublicKey{},
+		issuer:   config.Issuer,
+		audience: config.Audience,
+		leeway:   config.Leeway,
+		now:      time.Now,
+	}
+	if config.HMACSecret != "" {
+		v.hmacSecret = []byte(config.HMACSecret)
+	}
+	if config.PublicKeyFile != "" {
+		key, err := loadPublicKey(config.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		v.rsaKeys[""] = key
+	}
+	if config.JWKSFile != "" {
+		keys, err := loadJWKS(config.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		for kid, key := range keys {
+			v.rsaKeys[kid] = key
+		}
+	}
+	if v.hmacSecret == nil && len(v.rsaKeys) == 0 {
+		return nil, errors.New("auth: no verification key configured")
+	}
+	return v, nil
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// rawClaims is the JSON payload of a token. aud may be a string or an
+// array of strings; the numeric dates are seconds since the epoch.
+type rawClaims struct {
+	Sub   string          `json:"sub"`
+	Iss   string          `json:"iss"`
+	Aud   json.RawMessage `json:"aud"`
+	Exp   *json.Number    `json:"exp"`
+	Nbf   *json.Number    `json:"nbf"`
+	Iat   *json.Number    `json:"iat"`
+	Roles []string        `json:"roles"`
+	Scope string          `json:"scope"`
+}
+
+// Verify checks token and returns its claims. Tokens without an exp claim
+// are rejected.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalid("malformed token")
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, invalid("malformed header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalid("malformed signature")
+	}
+	if err := v.verifySignature(h, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var raw rawClaims
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, invalid("malformed claims")
+	}
+	claims, err := raw.claims()
+	if err != nil {
+		return nil, err
+	}
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *Verifier) verifySignature(h header, signed string, signature []byte) error {
+	switch h.Alg {
+	case "HS256":
+		if v.hmacSecret == nil {
+			return invalid("HS256 tokens are not accepted")
+		}
+		mac := hmac.New(sha256.New, v.hmacSecret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return invalid("bad signature")
+		}
+		return nil
+	case "RS256":
+		key, err := v.rsaKey(h.Kid)
+		if err != nil {
+			return err
+		}
+		digest := sha256.Sum256([]byte(signed))
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+			return invalid("bad signature")
+		}
+		return nil
+	default:
+		return invalid(fmt.Sprintf("unsupported algorithm %q", h.Alg))
+	}
+}
+
+// rsaKey finds the key for kid. A token without kid may only be checked
+// when there is a single RSA key to check it with.
+func (v *Verifier) rsaKey(kid string) (*rsa.PublicKey, error) {
+	if key, ok := v.rsaKeys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(v.rsaKeys) == 1 {
+		for _, key := range v.rsaKeys {
+			return key, nil
+		}
+	}
+	if kid == "" {
+		return nil, invalid("RS256 token without kid")
+	}
+	return nil, invalid(fmt.Sprintf("unknown key %q", kid))
+}
+
+func (v *Verifier) validate(c *Claims) error {
+	now := v.now()
+	if c.ExpiresAt.IsZero() {
+		return invalid("missing exp claim")
+	}
+	if now.After(c.ExpiresAt.Add(v.leeway)) {
+		return invalid("token expired")
+	}
+	if !c.NotBefore.IsZero() && now.Add(v.leeway).Before(c.NotBefore) {
+		return invalid("token not valid yet")
+	}
+	if v.issuer != "" && c.Issuer != v.issuer {
+		return invalid("unexpected issuer")
+	}
+	if v.audience != "" && !slices.Contains(c.Audience, v.audience) {
+		return invalid("unexpected audience")
+	}
+	return nil
+}
+
+func (raw rawClaims) claims() (*Claims, error) {
+	c := &Claims{Subject: raw.Sub, Issuer: raw.Iss, Roles: raw.Roles}
+	if scope := strings.Fields(raw.Scope); len(scope) > 0 {
+		c.Roles = append(slices.Clone(c.Roles), scope...)
+	}
+
+	if len(raw.Aud) > 0 {
+		var one string
+		if json.Unmarshal(raw.Aud, &one) == nil {
+			c.Audience = []string{one}
+		} else if json.Unmarshal(raw.Aud, &c.Audience) != nil {
+			return nil, invalid("malformed aud claim")
+		}
+	}
+
+	for _, date := range []struct {
+		n   *json.Number
+		dst *time.Time
+	}{{raw.Exp, &c.ExpiresAt}, {raw.Nbf, &c.NotBefore}, {raw.Iat, &c.IssuedAt}} {
+		if date.n == nil {
+			continue
+		}
+		seconds, err := date.n.Float64()
+		if err != nil {
+			return nil, invalid("malformed date claim")
+		}
+		*date.dst = time.Unix(int64(seconds), 0)
+	}
+	return c, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func invalid(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidToken, reason)
+}
+
+// SignHS256 returns a compact HS256 token for claims. The API never issues
+// tokens itself; this is for the dev token command and tests.
+func SignHS256(claims map[string]any, secret []byte) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"api/settings"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const secret = "0123456789abcdef0123456789abcdef"
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	h, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func writeJWKS(t *testing.T, kid string, key *rsa.PublicKey) string {
+	t.Helper()
+	set := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA", "kid": kid, "use": "sig", "alg": "RS256",
+		"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	b, _ := json.Marshal(set)
+	filename := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(filename, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestVerifier(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewVerifier(settings.Auth{
+		HMACSecret: secret,
+		JWKSFile:   writeJWKS(t, "k1", &key.PublicKey),
+		Issuer:     "https://issuer.example",
+		Audience:   "customers",
+	})
+	if err != nil {
+		t.Fatalf("was not expecting an error, got %v", err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	v.now = func() time.Time { return now }
+
+	claims := func(changes map[string]any) map[string]any {
+		c := map[string]any{
+			"sub": "alice", "iss": "https://issuer.example", "aud": []string{"customers", "other"},
+			"exp": now.Add(time.Minute).Unix(), "roles": []string{RoleCustomersRead}, "scope": "customers:write",
+		}
+		for k, v := range changes {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+	hs256 := func(c map[string]any) string {
+		token, _ := SignHS256(c, []byte(secret))
+		return token
+	}
+
+	t.Run("valid HS256 token", func(t *testing.T) {
+		c, err := v.Verify(hs256(claims(nil)))
+		if err != nil {
+			t.Fatalf("was not expecting an error, got %v", err)
+		}
+		if c.Subject != "alice" || !c.HasRole(RoleCustomersRead) || !c.HasRole(RoleCustomersWrite) {
+			t.Errorf("wanted alice with read and write roles, got %+v", c)
+		}
+	})
+
+	t.Run("valid RS256 token", func(t *testing.T) {
+		c, err := v.Verify(signRS256(t, key, "k1", claims(map[string]any{"aud": "customers"})))
+		if err != nil {
+			t.Fatalf("was not expecting an error, got %v", err)
+		}
+		if c.Subject != "alice" {
+			t.Errorf("wanted `alice`, got `%v`", c.Subject)
+		}
+	})
+
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	invalidTokens := []struct {
+		name  string
+		token string
+	}{
+		{"garbage", "not-a-token"},
+		{"wrong secret", func() string { tk, _ := SignHS256(claims(nil), []byte("another secret")); return tk }()},
+		{"alg none", base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+			base64.RawURLEncoding.EncodeToString([]byte(`{"exp":1900000000}`)) + "."},
+		{"unknown kid", signRS256(t, key, "k2", claims(nil))},
+		{"wrong rsa key", signRS256(t, otherKey, "k1", claims(nil))},
+		{"expired", hs256(claims(map[string]any{"exp": now.Add(-time.Minute).Unix()}))},
+		{"no exp", hs256(claims(map[string]any{"exp": nil}))},
+		{"not yet valid", hs256(claims(map[string]any{"nbf": now.Add(time.Minute).Unix()}))},
+		{"wrong issuer", hs256(claims(map[string]any{"iss": "https://evil.example"}))},
+		{"wrong audience", hs256(claims(map[string]any{"aud": "billing"}))},
+	}
+	for _, st := range invalidTokens {
+		t.Run(st.name, func(t *testing.T) {
+			_, err := v.Verify(st.token)
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("wanted %v, got %v", ErrInvalidToken, err)
+			}
+		})
+	}
+}
+
+func TestNewVerifierWithoutKeys(t *testing.T) {
+	if _, err := NewVerifier(settings.Auth{Enabled: true}); err == nil {
+		t.Error("was expecting an error; did not get one")
+	}
+}
@@ -0,0 +1,95 @@
+// Command migrate manages the schema of the customers database.
+//
+//	migrate [flags] up|down|status|redo
+//
+// It reads the same config.json, DB_* environment variables and -db-*
+// flags as the API server.
+package main
+
+import (
+	"api/dao"
+	"api/migrations"
+	"api/settings"
+	"appconfig"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	settings.RegisterFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: migrate [flags] up|down|status|redo")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	config, err := settings.Load(appconfig.Options{File: "config.json", Flags: flag.CommandLine})
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := dao.OpenDb(config.DB)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	migrator, err := migrations.New(db, config.DB.Driver)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch flag.Arg(0) {
+	case "up":
+		applied, err := migrator.Up()
+		for _, m := range applied {
+			fmt.Printf("applied  %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		m, err := migrator.Down()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if m == nil {
+			fmt.Println("nothing to roll back")
+		} else {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+	case "redo":
+		m, err := migrator.Redo()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if m == nil {
+			fmt.Println("nothing to redo")
+		} else {
+			fmt.Printf("redone   %04d_%s\n", m.Version, m.Name)
+		}
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,51 @@
+// Command token mints an HS256 bearer token for local testing, signed with
+// the auth.hmacSecret of the same configuration the API server reads.
+//
+//	token [flags] -sub alice -roles customers:read,customers:write
+package main
+
+import (
+	"api/auth"
+	"api/settings"
+	"appconfig"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+func main() {
+	sub := flag.String("sub", "dev", "subject of the token")
+	roles := flag.String("roles", auth.RoleCustomersRead+","+auth.RoleCustomersWrite, "comma separated roles")
+	ttl := flag.Duration("ttl", time.Hour, "lifetime of the token")
+	settings.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	config, err := settings.Load(appconfig.Options{File: "config.json", Flags: flag.CommandLine})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if config.Auth.HMACSecret == "" {
+		log.Fatal("auth.hmacSecret is not set")
+	}
+
+	now := time.Now()
+	claims := map[string]any{
+		"sub":   *sub,
+		"iat":   now.Unix(),
+		"exp":   now.Add(*ttl).Unix(),
+		"roles": strings.Split(*roles, ","),
+	}
+	if config.Auth.Issuer != "" {
+		claims["iss"] = config.Auth.Issuer
+	}
+	if config.Auth.Audience != "" {
+		claims["aud"] = config.Auth.Audience
+	}
+	token, err := auth.SignHS256(claims, []byte(config.Auth.HMACSecret))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(token)
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"api/auth"
+	"api/dao"
+	"api/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// APIKeyHandler serves the /api/admin/api-keys routes.
+type APIKeyHandler struct {
+	keys dao.APIKeyRepository
+	now  func() time.Time
+}
+
+func NewAPIKeyHandler(keys dao.APIKeyRepository) APIKeyHandler {
+	return APIKeyHandler{keys: keys, now: time.Now}
+}
+
+const maxOwnerLength = 100
+
+// HandleCreateAPIKey issues a key. The response is the only place the
+// key itself ever appears.
+func (h APIKeyHandler) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req model.NewAPIKey
+	if !decodeJson(w, r, &req) {
+		return
+	}
+	now := h.now()
+	if errs := validateNewAPIKey(&req, now); len(errs) > 0 {
+		writeInvalidFields(w, "The API key request has invalid fields.", errs)
+		return
+	}
+
+	key, prefix, hash := auth.NewAPIKey()
+	created, err := h.keys.Create(r.Context(), model.APIKey{
+		Prefix:    prefix,
+		Owner:     req.Owner,
+		Scopes:    req.Scopes,
+		RateLimit: req.RateLimit,
+		CreatedAt: now,
+		ExpiresAt: req.ExpiresAt,
+	}, hash)
+	if err != nil {
+		writeError(w, r, 0, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/api/admin/api-keys/%d", created.Id))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(model.IssuedAPIKey{APIKey: created, Key: key})
+}
+
+func (h APIKeyHandler) HandleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.keys.FindAll(r.Context())
+	if err != nil {
+		writeError(w, r, 0, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.APIKeyList{Data: keys})
+}
+
+// HandleRevokeAPIKey revokes a key; revoking it again changes nothing.
+func (h APIKeyHandler) HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorMessage(w, http.StatusBadRequest, "bad_request", "id must be a number")
+		return
+	}
+
+	err = h.keys.Revoke(r.Context(), id, h.now())
+	if errors.Is(err, dao.ErrAPIKeyNotFound) {
+		writeErrorMessage(w, http.StatusNotFound, "not_found", fmt.Sprintf("No API key found for id %d.", id))
+		return
+	}
+	if err != nil {
+		writeError(w, r, id, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func validateNewAPIKey(req *model.NewAPIKey, now time.Time) []model.FieldError {
+	errs := []model.FieldError{}
+	req.Owner = strings.TrimSpace(req.Owner)
+	if req.Owner == "" {
+		errs = append(errs, model.FieldError{Field: "owner", Message: "is required"})
+	} else if len(req.Owner) > maxOwnerLength {
+		errs = append(errs, model.FieldError{Field: "owner", Message: fmt.Sprintf("must be at most %d characters", maxOwnerLength)})
+	}
+
+	if len(req.Scopes) == 0 {
+		errs = append(errs, model.FieldError{Field: "scopes", Message: "is required"})
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(auth.APIKeyRoles, scope) {
+			errs = append(errs, model.FieldError{Field: "scopes",
+				Message: fmt.Sprintf("%q is not one of %s", scope, strings.Join(auth.APIKeyRoles, ", "))})
+		}
+	}
+	slices.Sort(req.Scopes)
+	req.Scopes = slices.Compact(req.Scopes)
+
+	if req.RateLimit < 0 {
+		errs = append(errs, model.FieldError{Field: "rateLimit", Message: "must not be negative"})
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		errs = append(errs, model.FieldError{Field: "expiresAt", Message: "must be in the future"})
+	}
+	return errs
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"api/dao"
+	"api/model"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAPIKeyHandler(t *testing.T) {
+	h := NewAPIKeyHandler(dao.NewMemoryAPIKeyRepository())
+	r := mux.NewRouter()
+	r.HandleFunc("/api/admin/api-keys", h.HandleListAPIKeys).Methods("GET")
+	r.HandleFunc("/api/admin/api-keys", h.HandleCreateAPIKey).Methods("POST")
+	r.HandleFunc("/api/admin/api-keys/{id}", h.HandleRevokeAPIKey).Methods("DELETE")
+
+	t.Run("create", func(t *testing.T) {
+		w := serve(r, "POST", "/api/admin/api-keys", `{"owner": "bookings", "scopes": ["customers:read", "customers:read"]}`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("wanted %v, got %v", http.StatusCreated, w.Code)
+		}
+		var issued model.IssuedAPIKey
+		json.NewDecoder(w.Body).Decode(&issued)
+		if !strings.HasPrefix(issued.Key, "csk_"+issued.Prefix+"_") || len(issued.Scopes) != 1 {
+			t.Errorf("wanted a csk_ key with one scope, got %+v", issued)
+		}
+	})
+
+	t.Run("create invalid", func(t *testing.T) {
+		w := serve(r, "POST", "/api/admin/api-keys", `{"owner": " ", "scopes": ["admin"], "expiresAt": "2001-01-01T00:00:00Z"}`)
+		var msg model.ErrorMessage
+		json.NewDecoder(w.Body).Decode(&msg)
+		if w.Code != http.StatusUnprocessableEntity || len(msg.Errors) != 3 {
+			t.Errorf("wanted 422 for owner, scopes and expiresAt, got %v %+v", w.Code, msg)
+		}
+	})
+
+	t.Run("list never shows keys", func(t *testing.T) {
+		w := serve(r, "GET", "/api/admin/api-keys", "")
+		if strings.Contains(w.Body.String(), "csk_") {
+			t.Errorf("wanted no keys in the listing, got %v", w.Body.String())
+		}
+		var list model.APIKeyList
+		json.NewDecoder(w.Body).Decode(&list)
+		if len(list.Data) != 1 {
+			t.Errorf("wanted 1 key, got %v", len(list.Data))
+		}
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		if w := serve(r, "DELETE", "/api/admin/api-keys/1", ""); w.Code != http.StatusNoContent {
+			t.Errorf("wanted %v, got %v", http.StatusNoContent, w.Code)
+		}
+		if w := serve(r, "DELETE", "/api/admin/api-keys/9", ""); w.Code != http.StatusNotFound {
+			t.Errorf("wanted %v, got %v", http.StatusNotFound, w.Code)
+		}
+	})
+}
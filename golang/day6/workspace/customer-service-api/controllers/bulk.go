@@ -0,0 +1,254 @@
+package controllers
+
+import (
+	"api/dao"
+	"api/logging"
+	"api/model"
+	"api/render"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+)
+
+const (
+	// customers saved per transaction in best-effort mode
+	importBatchSize = 500
+	maxImportErrors = 100
+	maxImportBytes  = 64 << 20
+	// customers one atomic import may hold in a single transaction
+	maxAtomicImport = 10000
+
+	// imports and exports may take longer than the server timeouts allow
+	bulkTimeout = 10 * time.Minute
+
+	exportBufferSize = 64 << 10
+)
+
+const (
+	importAtomic     = "atomic"
+	importBestEffort = "best-effort"
+)
+
+// HandleImportCustomers serves POST /api/customers:import. The body is a
+// JSON array, NDJSON or CSV, according to Content-Type, and is read as it
+// arrives. Every row is validated like a POSTed customer; ids are ignored.
+//
+// With mode=atomic, the default, everything is saved in one transaction
+// that is rolled back if any row fails, answering 422; bodies of more
+// than maxAtomicImport rows are refused with 413. With mode=best-effort,
+// rows are saved in batches of importBatchSize, each in its own
+// transaction, and failing rows are skipped.
+func (h CustomerHandler) HandleImportCustomers(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	switch mode {
+	case "":
+		mode = importAtomic
+	case importAtomic, importBestEffort:
+	default:
+		writeErrorMessage(w, http.StatusBadRequest, "bad_request", "mode must be atomic or best-effort")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Now().Add(bulkTimeout))
+	rc.SetWriteDeadline(time.Now().Add(bulkTimeout))
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	rows, err := newRowReader(r.Header.Get("Content-Type"), body)
+	if errors.Is(err, errUnsupportedImport) {
+		writeErrorMessage(w, http.StatusUnsupportedMediaType, "unsupported_media_type", err.Error())
+		return
+	}
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	report := model.ImportReport{Mode: mode, Errors: []model.ImportError{}}
+	fail := func(row int, field, message string) {
+		report.Failed++
+		if len(report.Errors) < maxImportErrors {
+			report.Errors = append(report.Errors, model.ImportError{Row: row, Field: field, Message: message})
+		} else {
+			report.Truncated = true
+		}
+	}
+
+	var imp dao.CustomerImport
+	pending := 0
+	defer func() {
+		if imp != nil {
+			imp.Rollback()
+		}
+	}()
+	commit := func() error {
+		err := imp.Commit()
+		imp = nil
+		if err == nil {
+			report.Imported += pending
+		}
+		pending = 0
+		return err
+	}
+
+	for {
+		c, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		report.Received++
+		row := report.Received
+		if mode == importAtomic && row > maxAtomicImport {
+			writeErrorMessage(w, http.StatusRequestEntityTooLarge, "body_too_large",
+				fmt.Sprintf("Atomic imports must not exceed %d customers; use mode=best-effort for more.", maxAtomicImport))
+			return
+		}
+
+		var rowErr *rowError
+		if errors.As(err, &rowErr) {
+			fail(row, rowErr.field, rowErr.message)
+			continue
+		}
+		if err != nil {
+			writeImportAborted(w, report, row, err)
+			return
+		}
+		if errs := h.validator.Validate(&c); len(errs) > 0 {
+			for _, e := range errs {
+				fail(row, e.Field, e.Message)
+			}
+			continue
+		}
+		// an atomic import is lost anyway; only validate the rest
+		if mode == importAtomic && report.Failed > 0 {
+			continue
+		}
+
+		if imp == nil {
+			if imp, err = h.repo.BeginImport(ctx); err != nil {
+				writeError(w, r, 0, err)
+				return
+			}
+		}
+		var validationErr *dao.ValidationError
+		err = imp.Save(ctx, c)
+		switch {
+		case err == nil:
+			pending++
+		case errors.Is(err, dao.ErrDuplicateEmail):
+			fail(row, "email", err.Error())
+		case errors.As(err, &validationErr):
+			fail(row, validationErr.Field, validationErr.Message)
+		default:
+			writeError(w, r, 0, err)
+			return
+		}
+
+		if mode == importBestEffort && pending == importBatchSize {
+			if err := commit(); err != nil {
+				writeError(w, r, 0, err)
+				return
+			}
+		}
+	}
+
+	status := http.StatusOK
+	if mode == importAtomic && report.Failed > 0 {
+		status = http.StatusUnprocessableEntity
+	} else if imp != nil {
+		if err := commit(); err != nil {
+			writeError(w, r, 0, err)
+			return
+		}
+	}
+	logging.Logger(ctx).Info("customers imported",
+		"mode", mode, "received", report.Received, "imported", report.Imported, "failed", report.Failed)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+var errUnsupportedImport = errors.New("imports must be " + render.JSON + " (an array), " + render.NDJSON + " or " + render.CSV)
+
+func newRowReader(contentType string, body io.Reader) (rowReader, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case render.JSON:
+		decoder := json.NewDecoder(body)
+		decoder.DisallowUnknownFields()
+		return &jsonArrayReader{decoder: decoder}, nil
+	case render.NDJSON:
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 4<<10), maxBodyBytes)
+		return &ndjsonReader{scanner: scanner}, nil
+	case render.CSV:
+		return newCsvReader(body)
+	}
+	return nil, errUnsupportedImport
+}
+
+// writeImportAborted answers a body that could not be read to the end.
+// Batches committed before it stay, in best-effort mode.
+func writeImportAborted(w http.ResponseWriter, report model.ImportReport, row int, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeErrorMessage(w, http.StatusRequestEntityTooLarge, "body_too_large",
+			fmt.Sprintf("Imports must not exceed %d bytes; %d customers were imported.", maxBytesErr.Limit, report.Imported))
+		return
+	}
+	writeErrorMessage(w, http.StatusBadRequest, "bad_request",
+		fmt.Sprintf("Row %d: %v; %d customers were imported.", row, err, report.Imported))
+}
+
+// HandleExportCustomers serves GET /api/customers:export, streaming every
+// customer in the negotiated media type as it is read from the store.
+// Once the first bytes are sent the status can no longer change, so a
+// failure after that cuts the response short.
+func (h CustomerHandler) HandleExportCustomers(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Now().Add(bulkTimeout))
+
+	mediaType := render.MediaType(r)
+	bw := bufio.NewWriterSize(w, exportBufferSize)
+	stream := render.NewCustomerStream(bw, mediaType)
+	w.Header().Set("Content-Type", render.ContentType(mediaType))
+	w.Header().Set("Content-Disposition", `attachment; filename="customers`+stream.Extension()+`"`)
+
+	count, sent := 0, false
+	err := h.repo.Export(r.Context(), func(c model.Customer) error {
+		if err := stream.Write(c); err != nil {
+			return err
+		}
+		count++
+		// hand full buffers to the client as we go
+		if bw.Buffered() >= exportBufferSize/2 {
+			sent = true
+			if err := stream.Flush(); err != nil {
+				return err
+			}
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = stream.Close()
+	}
+	if err != nil && !sent {
+		w.Header().Del("Content-Disposition")
+		writeError(w, r, 0, err)
+		return
+	}
+	if err != nil {
+		logging.Logger(r.Context()).Error("export cut short", "error", err, "exported", count)
+	}
+	bw.Flush()
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"api/model"
+	"api/render"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveImport(r http.Handler, target, contentType, body string) (*httptest.ResponseRecorder, model.ImportReport) {
+	req := httptest.NewRequest("POST", target, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var report model.ImportReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	return w, report
+}
+
+func TestImportCustomers(t *testing.T) {
+	subtests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		status      int
+		imported    int
+		failedRows  []int
+	}{
+		{"json array", "/api/customers:import", "application/json",
+			`[{"name": "Ravi", "city": "Pune", "email": "ravi@xmpl.com"}, {"id": 99, "name": "Sita", "email": "sita@xmpl.com"}]` + "\n",
+			http.StatusOK, 2, nil},
+		{"ndjson", "/api/customers:import", "application/x-ndjson; charset=utf-8",
+			"{\"name\": \"Ravi\", \"email\": \"ravi@xmpl.com\"}\n\n{\"name\": \"Sita\", \"email\": \"sita@xmpl.com\"}\n",
+			http.StatusOK, 2, nil},
+		{"csv", "/api/customers:import", "text/csv",
+			"name,email,city\nRavi,ravi@xmpl.com,Pune\n\"Sita, Jr\",sita@xmpl.com,\n",
+			http.StatusOK, 2, nil},
+		{"atomic with a bad row", "/api/customers:import", "application/json",
+			`[{"name": "Ravi", "email": "ravi@xmpl.com"}, {"name": "", "email": "not an email"}, {"name": "Sita", "email": "sita@xmpl.com"}]`,
+			http.StatusUnprocessableEntity, 0, []int{2, 2}},
+		{"best effort with bad rows", "/api/customers:import?mode=best-effort", "application/json",
+			`[{"name": "Ravi", "email": "ravi@xmpl.com"}, {"name": 5, "email": "x@xmpl.com"}, {"name": "Sita", "email": "vinod@vinod.co"},
+			  {"name": "Gita", "email": "gita@xmpl.com", "age": 3}, {"name": "Ravi2", "email": "ravi@xmpl.com"}, {"name": "Sita", "email": "sita@xmpl.com"}]`,
+			http.StatusOK, 2, []int{2, 3, 4, 5}},
+		{"best effort ndjson with broken line", "/api/customers:import?mode=best-effort", "application/x-ndjson",
+			"{\"name\": \"Ravi\", \"email\": \"ravi@xmpl.com\"}\n{\"name\": \n",
+			http.StatusOK, 1, []int{2}},
+		{"csv with a short row", "/api/customers:import?mode=best-effort", "text/csv",
+			"name,email\nRavi\nSita,sita@xmpl.com\n",
+			http.StatusOK, 1, []int{1}},
+	}
+	for _, st := range subtests {
+		t.Run(st.name, func(t *testing.T) {
+			r := newTestRouter()
+			w, report := serveImport(r, st.target, st.contentType, st.body)
+			if w.Code != st.status {
+				t.Fatalf("wanted %v, got %v: %s", st.status, w.Code, w.Body.String())
+			}
+			if report.Imported != st.imported || report.Failed != len(st.failedRows) {
+				t.Errorf("wanted %d imported and %d failed, got %+v", st.imported, len(st.failedRows), report)
+			}
+			for i, e := range report.Errors {
+				if i < len(st.failedRows) && e.Row != st.failedRows[i] {
+					t.Errorf("wanted errors for rows %v, got %+v", st.failedRows, report.Errors)
+					break
+				}
+			}
+
+			var page model.CustomerPage
+			json.NewDecoder(serve(r, "GET", "/api/customers", "").Body).Decode(&page)
+			if page.Total != 3+st.imported {
+				t.Errorf("wanted %d customers stored, got %d", 3+st.imported, page.Total)
+			}
+		})
+	}
+
+	failures := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"unknown mode", "/api/customers:import?mode=yolo", "application/json", "[]", http.StatusBadRequest},
+		{"unsupported type", "/api/customers:import", "application/xml", "<customers/>", http.StatusUnsupportedMediaType},
+		{"not an array", "/api/customers:import", "application/json", `{"name": "Ravi"}`, http.StatusBadRequest},
+		{"broken json", "/api/customers:import", "application/json", `[{"name": "Ravi", "email": "ravi@xmpl.com"}, {"na`, http.StatusBadRequest},
+		{"data after the array", "/api/customers:import", "application/json", `[] [{"name": "Ravi", "email": "ravi@xmpl.com"}]`, http.StatusBadRequest},
+		{"too many rows for atomic", "/api/customers:import", "application/x-ndjson",
+			strings.Repeat(`{"name": "Ravi", "email": "ravi@xmpl.com"}`+"\n", maxAtomicImport+1), http.StatusRequestEntityTooLarge},
+		{"unknown csv column", "/api/customers:import", "text/csv", "name,age\nRavi,3\n", http.StatusBadRequest},
+		{"empty body", "/api/customers:import", "text/csv", "", http.StatusBadRequest},
+	}
+	for _, st := range failures {
+		t.Run(st.name, func(t *testing.T) {
+			w, _ := serveImport(newTestRouter(), st.target, st.contentType, st.body)
+			if w.Code != st.status {
+				t.Errorf("wanted %v, got %v: %s", st.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestExportCustomers(t *testing.T) {
+	r := newTestRouter()
+	subtests := []struct {
+		mediaType string
+		want      string
+	}{
+		{render.JSON, `[{"id":1,"name":"Vinod","city":"Bangalore","email":"vinod@vinod.co","version":1}` + "\n" + `,{"id":2,`},
+		{render.NDJSON, `{"id":1,"name":"Vinod","city":"Bangalore","email":"vinod@vinod.co","version":1}` + "\n" + `{"id":2,`},
+		{render.CSV, "id,name,city,email\n1,Vinod,Bangalore,vinod@vinod.co\n2,"},
+		{render.XML, `<customers><customer id="1" version="1"><name>Vinod</name>`},
+	}
+	for _, st := range subtests {
+		t.Run(st.mediaType, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/customers:export", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, render.WithMediaType(req, st.mediaType))
+
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), st.want) {
+				t.Errorf("wanted 200 containing %q, got %v:\n%s", st.want, w.Code, w.Body.String())
+			}
+			if st.mediaType == render.JSON {
+				var customers []model.Customer
+				if err := json.Unmarshal(w.Body.Bytes(), &customers); err != nil || len(customers) != 3 {
+					t.Errorf("wanted a JSON array of 3 customers, got %v (%v)", len(customers), err)
+				}
+			}
+			if cd := w.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment") {
+				t.Errorf("wanted an attachment, got %q", cd)
+			}
+		})
+	}
+}
@@ -3,10 +3,14 @@ package controllers
 import (
 	"api/dao"
 	"api/model"
-	"encoding/json"
+	"api/render"
+	"api/validation"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/gorilla/mux"
 )
@@ -15,28 +19,298 @@ func Home(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, "customer service end point here")
 }
 
-func HandleGetAllCustomers(w http.ResponseWriter, r *http.Request) {
-	json.NewEncoder(w).Encode(dao.GetAllCustomers())
+// CustomerHandler serves the /api/customers routes from whichever
+// CustomerRepository it is given.
+type CustomerHandler struct {
+	repo      dao.CustomerRepository
+	validator validation.CustomerValidator
 }
 
-func HandleGetOneCustomer(w http.ResponseWriter, r *http.Request) {
+func NewCustomerHandler(repo dao.CustomerRepository, validator validation.CustomerValidator) CustomerHandler {
+	return CustomerHandler{repo: repo, validator: validator}
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+
+	// a customer is four short fields; anything bigger is not one
+	maxBodyBytes = 16 << 10
+)
+
+func (h CustomerHandler) HandleGetAllCustomers(w http.ResponseWriter, r *http.Request) {
+	q, err := parseCustomerQuery(r.URL.Query())
+	if err != nil {
+		writeErrorMessage(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	customers, total, err := h.repo.FindAll(r.Context(), q)
+	if err != nil {
+		writeError(w, r, 0, err)
+		return
+	}
+	page := model.CustomerPage{
+		Data:   customers,
+		Total:  total,
+		Limit:  q.Limit,
+		Offset: q.Offset,
+		Links:  pageLinks(r.URL, q, customers, total),
+	}
+	render.CustomerPage(w, r, page)
+}
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// HandleSearchCustomers serves GET /api/customers/search?q=...&limit=...
+func (h CustomerHandler) HandleSearchCustomers(w http.ResponseWriter, r *http.Request) {
+	if mediaType := render.MediaType(r); mediaType != render.JSON && mediaType != render.NDJSON {
+		writeErrorMessage(w, http.StatusNotAcceptable, "not_acceptable",
+			"Search results are available as "+render.JSON+" and "+render.NDJSON+".")
+		return
+	}
+	q, err := parseSearchQuery(r.URL.Query())
+	if err != nil {
+		writeErrorMessage(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	results, err := h.repo.Search(r.Context(), q)
+	if err != nil {
+		writeError(w, r, 0, err)
+		return
+	}
+	render.SearchResults(w, r, model.SearchResults{Query: q.Q, Data: results})
+}
+
+func parseSearchQuery(values url.Values) (model.SearchQuery, error) {
+	q := model.SearchQuery{Q: strings.TrimSpace(values.Get("q")), Limit: defaultSearchLimit}
+	if q.Q == "" {
+		return q, fmt.Errorf("q is required")
+	}
+	if utf8.RuneCountInString(q.Q) > validation.MaxFieldLength {
+		return q, fmt.Errorf("q must be at most %d characters", validation.MaxFieldLength)
+	}
+	if v := values.Get("limit"); v != "" {
+		var err error
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 || q.Limit > maxSearchLimit {
+			return q, fmt.Errorf("limit must be a number between 1 and %d", maxSearchLimit)
+		}
+	}
+	return q, nil
+}
+
+// parseCustomerQuery reads city, sort, order, limit, offset and after from
+// the query string of GET /api/customers.
+func parseCustomerQuery(values url.Values) (model.CustomerQuery, error) {
+	q := model.CustomerQuery{
+		City:  values.Get("city"),
+		Sort:  values.Get("sort"),
+		Order: values.Get("order"),
+		Limit: defaultPageSize,
+	}
+
+	switch q.Sort {
+	case "":
+		q.Sort = "id"
+	case "id", "name", "city", "email":
+	default:
+		return q, fmt.Errorf("sort must be one of id, name, city or email")
+	}
+
+	switch q.Order {
+	case "":
+		q.Order = "asc"
+	case "asc", "desc":
+	default:
+		return q, fmt.Errorf("order must be asc or desc")
+	}
+
+	var err error
+	if v := values.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 || q.Limit > maxPageSize {
+			return q, fmt.Errorf("limit must be a number between 1 and %d", maxPageSize)
+		}
+	}
+	if v := values.Get("offset"); v != "" {
+		if q.Offset, err = strconv.Atoi(v); err != nil || q.Offset < 0 {
+			return q, fmt.Errorf("offset must be a number >= 0")
+		}
+	}
+	if v := values.Get("after"); v != "" {
+		if q.After, err = strconv.Atoi(v); err != nil || q.After < 1 {
+			return q, fmt.Errorf("after must be a customer id >= 1")
+		}
+		if q.Sort != "id" {
+			return q, fmt.Errorf("after can only be used when sorting by id")
+		}
+		if q.Offset != 0 {
+			return q, fmt.Errorf("after and offset cannot be used together")
+		}
+	}
+	return q, nil
+}
+
+// pageLinks builds self/next/prev links that keep every other query
+// parameter of the current request.
+func pageLinks(u *url.URL, q model.CustomerQuery, customers []model.Customer, total int) model.PageLinks {
+	link := func(set map[string]string) string {
+		values := u.Query()
+		for k, v := range set {
+			if v == "" {
+				values.Del(k)
+			} else {
+				values.Set(k, v)
+			}
+		}
+		return (&url.URL{Path: u.Path, RawQuery: values.Encode()}).String()
+	}
+
+	links := model.PageLinks{Self: link(nil)}
+	limit := strconv.Itoa(q.Limit)
+
+	if q.After > 0 {
+		if len(customers) == q.Limit {
+			last := strconv.Itoa(customers[len(customers)-1].Id)
+			links.Next = link(map[string]string{"after": last, "limit": limit})
+		}
+		return links
+	}
+
+	if q.Offset+len(customers) < total {
+		links.Next = link(map[string]string{"offset": strconv.Itoa(q.Offset + q.Limit), "limit": limit})
+	}
+	if q.Offset > 0 {
+		prev := q.Offset - q.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links.Prev = link(map[string]string{"offset": strconv.Itoa(prev), "limit": limit})
+	}
+	return links
+}
+
+func (h CustomerHandler) HandleGetOneCustomer(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.Atoi(mux.Vars(r)["id"])
-	c := dao.GetOneCustomer(id)
+	c, err := h.repo.FindById(r.Context(), id)
+	if err != nil {
+		writeError(w, r, id, err)
+		return
+	}
 
-	if c == nil {
-		w.WriteHeader(http.StatusNotFound)
-		err := model.ErrorMessage{Message: fmt.Sprintf("No customer found for id %d.", id)}
-		json.NewEncoder(w).Encode(err)
-	} else {
-		json.NewEncoder(w).Encode(*c)
+	tag := etag(r, c)
+	w.Header().Set("ETag", tag)
+	if !noneMatch(r, tag) {
+		w.WriteHeader(http.StatusNotModified) // 304
+		return
+	}
+	render.Customer(w, r, http.StatusOK, c)
+}
+
+func (h CustomerHandler) HandlePostOneCustomer(w http.ResponseWriter, r *http.Request) {
+	var cust model.Customer
+	if !decodeJson(w, r, &cust) {
+		return
+	}
+	if errs := h.validator.Validate(&cust); errs != nil {
+		writeValidationErrors(w, errs)
+		return
 	}
 
+	id, err := h.repo.Save(r.Context(), cust)
+	if err != nil {
+		writeError(w, r, 0, err)
+		return
+	}
+	c, err := h.repo.FindById(r.Context(), id)
+	if err != nil {
+		writeError(w, r, id, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/customers/%d", id))
+	w.Header().Set("ETag", etag(r, c))
+	render.Customer(w, r, http.StatusCreated, c) // 201
 }
 
-func HandlePostOneCustomer(w http.ResponseWriter, r *http.Request) {
+func (h CustomerHandler) HandlePutOneCustomer(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	version, ok := h.ifMatchVersion(w, r, id)
+	if !ok {
+		return
+	}
+
 	var cust model.Customer
-	json.NewDecoder(r.Body).Decode(&cust)
-	cust.Id = dao.AddCustomer(cust)
-	w.WriteHeader(http.StatusCreated) // 201
-	json.NewEncoder(w).Encode(cust)
+	if !decodeJson(w, r, &cust) {
+		return
+	}
+	if errs := h.validator.Validate(&cust); errs != nil {
+		writeValidationErrors(w, errs)
+		return
+	}
+	cust.Id = id
+	cust.Version = version
+
+	c, err := h.repo.Update(r.Context(), cust)
+	if err != nil {
+		writeError(w, r, id, err)
+		return
+	}
+	w.Header().Set("ETag", etag(r, c))
+	render.Customer(w, r, http.StatusOK, c)
+}
+
+func (h CustomerHandler) HandlePatchOneCustomer(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	version, ok := h.ifMatchVersion(w, r, id)
+	if !ok {
+		return
+	}
+
+	var patch model.CustomerPatch
+	if !decodeJson(w, r, &patch) {
+		return
+	}
+	if errs := h.validator.ValidatePatch(&patch); errs != nil {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	c, err := h.repo.Patch(r.Context(), id, version, patch)
+	if err != nil {
+		writeError(w, r, id, err)
+		return
+	}
+	w.Header().Set("ETag", etag(r, c))
+	render.Customer(w, r, http.StatusOK, c)
+}
+
+func (h CustomerHandler) HandleDeleteOneCustomer(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	version, ok := h.ifMatchVersion(w, r, id)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.Delete(r.Context(), id, version); err != nil {
+		writeError(w, r, id, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent) // 204
+}
+
+// HandleRestoreCustomer serves POST /api/customers/{id}:restore, undoing
+// the deletion of a customer that has not been purged yet. Restoring a
+// customer that is not deleted changes nothing.
+func (h CustomerHandler) HandleRestoreCustomer(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	c, err := h.repo.Restore(r.Context(), id)
+	if err != nil {
+		writeError(w, r, id, err)
+		return
+	}
+	w.Header().Set("ETag", etag(r, c))
+	render.Customer(w, r, http.StatusOK, c)
 }
@@ -0,0 +1,272 @@
+package controllers
+
+import (
+	"api/dao"
+	"api/model"
+	"api/render"
+	"api/validation"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func newTestRouter() *mux.Router {
+	repo := dao.NewMemoryCustomerRepository(
+		model.Customer{Id: 1, Name: "Vinod", City: "Bangalore", Email: "vinod@vinod.co"},
+		model.Customer{Id: 2, Name: "Shyam", City: "Chennai", Email: "shyam@xmpl.com"},
+		model.Customer{Id: 3, Name: "Anil", City: "Bangalore", Email: "anil@xmpl.com"},
+	)
+	h := NewCustomerHandler(repo, validation.CustomerValidator{})
+
+	r := mux.NewRouter()
+	r.HandleFunc("/api/customers", h.HandleGetAllCustomers).Methods("GET")
+	r.HandleFunc("/api/customers/search", h.HandleSearchCustomers).Methods("GET")
+	r.HandleFunc("/api/customers/{id}", h.HandleGetOneCustomer).Methods("GET")
+	r.HandleFunc("/api/customers", h.HandlePostOneCustomer).Methods("POST")
+	r.HandleFunc("/api/customers:import", h.HandleImportCustomers).Methods("POST")
+	r.HandleFunc("/api/customers:export", h.HandleExportCustomers).Methods("GET")
+	r.HandleFunc("/api/customers/{id}", h.HandlePutOneCustomer).Methods("PUT")
+	r.HandleFunc("/api/customers/{id}", h.HandlePatchOneCustomer).Methods("PATCH")
+	r.HandleFunc("/api/customers/{id}", h.HandleDeleteOneCustomer).Methods("DELETE")
+	r.HandleFunc("/api/customers/{id}:restore", h.HandleRestoreCustomer).Methods("POST")
+	return r
+}
+
+// serve sends a request with body and header, given as name, value pairs.
+func serve(r http.Handler, method, target, body string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCustomerHandler(t *testing.T) {
+	r := newTestRouter()
+
+	t.Run("get existing customer", func(t *testing.T) {
+		w := serve(r, "GET", "/api/customers/1", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("wanted %v, got %v", http.StatusOK, w.Code)
+		}
+		var c model.Customer
+		json.NewDecoder(w.Body).Decode(&c)
+		if c.Name != "Vinod" {
+			t.Errorf("wanted `Vinod`, got `%v`", c.Name)
+		}
+	})
+
+	t.Run("get missing customer", func(t *testing.T) {
+		w := serve(r, "GET", "/api/customers/99", "")
+		if w.Code != http.StatusNotFound {
+			t.Errorf("wanted %v, got %v", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("list customers from a city", func(t *testing.T) {
+		w := serve(r, "GET", "/api/customers?city=Bangalore&sort=name&limit=1", "")
+		var page model.CustomerPage
+		json.NewDecoder(w.Body).Decode(&page)
+		if page.Total != 2 || len(page.Data) != 1 || page.Data[0].Name != "Anil" {
+			t.Errorf("wanted 1 of 2 customers starting with `Anil`, got %+v", page)
+		}
+		if page.Links.Next == "" {
+			t.Error("was expecting a next link; did not get one")
+		}
+	})
+
+	t.Run("search customers", func(t *testing.T) {
+		w := serve(r, "GET", "/api/customers/search?q=an", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("wanted %v, got %v", http.StatusOK, w.Code)
+		}
+		var results model.SearchResults
+		json.NewDecoder(w.Body).Decode(&results)
+		if len(results.Data) != 2 || results.Data[0].Name != "Anil" || results.Data[0].Highlights["name"] != "<mark>An</mark>il" {
+			t.Errorf("wanted 2 results starting with a highlighted `Anil`, got %+v", results)
+		}
+	})
+
+	t.Run("search without q", func(t *testing.T) {
+		if w := serve(r, "GET", "/api/customers/search?q=%20", ""); w.Code != http.StatusBadRequest {
+			t.Errorf("wanted %v, got %v", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("list customers as csv", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/customers?limit=2", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, render.WithMediaType(req, render.CSV))
+
+		want := "id,name,city,email\n1,Vinod,Bangalore,vinod@vinod.co\n2,Shyam,Chennai,shyam@xmpl.com\n"
+		if w.Body.String() != want {
+			t.Errorf("wanted %q, got %q", want, w.Body.String())
+		}
+		if w.Header().Get("X-Total-Count") != "3" {
+			t.Errorf("wanted X-Total-Count 3, got %v", w.Header().Get("X-Total-Count"))
+		}
+	})
+
+	t.Run("invalid sort field", func(t *testing.T) {
+		w := serve(r, "GET", "/api/customers?sort=password", "")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("wanted %v, got %v", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("create, patch and delete", func(t *testing.T) {
+		w := serve(r, "POST", "/api/customers", `{"name":"Kishore","city":"Vasco","email":"kishore@xmpl.com"}`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("wanted %v, got %v", http.StatusCreated, w.Code)
+		}
+		var c model.Customer
+		json.NewDecoder(w.Body).Decode(&c)
+		if c.Id != 4 || w.Header().Get("ETag") != `"1.json"` {
+			t.Fatalf("wanted new id 4 with ETag \"1.json\", got %v and %v", c.Id, w.Header().Get("ETag"))
+		}
+
+		w = serve(r, "PATCH", "/api/customers/4", `{"city":"Mysore"}`, "If-Match", `"1.json"`)
+		json.NewDecoder(w.Body).Decode(&c)
+		if c.City != "Mysore" || c.Name != "Kishore" {
+			t.Errorf("wanted only city patched, got %+v", c)
+		}
+
+		w = serve(r, "DELETE", "/api/customers/4", "", "If-Match", w.Header().Get("ETag"))
+		if w.Code != http.StatusNoContent {
+			t.Errorf("wanted %v, got %v", http.StatusNoContent, w.Code)
+		}
+		w = serve(r, "DELETE", "/api/customers/4", "", "If-Match", "*")
+		if w.Code != http.StatusNotFound {
+			t.Errorf("wanted %v, got %v", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("restore deleted customer", func(t *testing.T) {
+		w := serve(r, "POST", "/api/customers/4:restore", "")
+		var c model.Customer
+		json.NewDecoder(w.Body).Decode(&c)
+		if w.Code != http.StatusOK || c.Id != 4 || c.DeletedAt != nil || w.Header().Get("ETag") != `"4.json"` {
+			t.Fatalf("wanted customer 4 back with ETag \"4.json\", got %v %+v and %v", w.Code, c, w.Header().Get("ETag"))
+		}
+		if w := serve(r, "GET", "/api/customers/4", ""); w.Code != http.StatusOK {
+			t.Errorf("wanted %v, got %v", http.StatusOK, w.Code)
+		}
+		if w := serve(r, "POST", "/api/customers/99:restore", ""); w.Code != http.StatusNotFound {
+			t.Errorf("wanted %v, got %v", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("duplicate email", func(t *testing.T) {
+		w := serve(r, "POST", "/api/customers", `{"name":"Vinod K","city":"Mysore","email":"vinod@vinod.co"}`)
+		if w.Code != http.StatusConflict {
+			t.Errorf("wanted %v, got %v", http.StatusConflict, w.Code)
+		}
+		var msg model.ErrorMessage
+		json.NewDecoder(w.Body).Decode(&msg)
+		if msg.Code != "duplicate_email" {
+			t.Errorf("wanted `duplicate_email`, got `%v`", msg.Code)
+		}
+	})
+
+	t.Run("invalid customer", func(t *testing.T) {
+		w := serve(r, "POST", "/api/customers", `{"name":"","email":"nobody"}`)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("wanted %v, got %v", http.StatusUnprocessableEntity, w.Code)
+		}
+		var msg model.ErrorMessage
+		json.NewDecoder(w.Body).Decode(&msg)
+		if len(msg.Errors) != 2 {
+			t.Errorf("wanted 2 field errors, got %v", msg.Errors)
+		}
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		w := serve(r, "POST", "/api/customers", `{"name":"Vinod","email":"v@vinod.co","password":"x"}`)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("wanted %v, got %v", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		w := serve(r, "POST", "/api/customers", `{"name":"`+strings.Repeat("v", maxBodyBytes)+`"}`)
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("wanted %v, got %v", http.StatusRequestEntityTooLarge, w.Code)
+		}
+	})
+
+	t.Run("put missing customer", func(t *testing.T) {
+		w := serve(r, "PUT", "/api/customers/99", `{"name":"Nobody","email":"nobody@xmpl.com"}`, "If-Match", "*")
+		if w.Code != http.StatusNotFound {
+			t.Errorf("wanted %v, got %v", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("conditional requests", func(t *testing.T) {
+		w := serve(r, "GET", "/api/customers/2", "")
+		tag := w.Header().Get("ETag")
+		if tag != `"1.json"` {
+			t.Fatalf("wanted ETag \"1.json\", got %q", tag)
+		}
+
+		// each representation has a tag of its own
+		req := httptest.NewRequest("GET", "/api/customers/2", nil)
+		req.Header.Set("If-None-Match", tag)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, render.WithMediaType(req, render.CSV))
+		if w.Code != http.StatusOK || w.Header().Get("ETag") != `"1.csv"` {
+			t.Fatalf("wanted 200 with ETag \"1.csv\", got %v with %q", w.Code, w.Header().Get("ETag"))
+		}
+
+		subtests := []struct {
+			name   string
+			method string
+			body   string
+			header []string
+			want   int
+		}{
+			{"not modified", "GET", "", []string{"If-None-Match", tag}, http.StatusNotModified},
+			{"not modified weakly", "GET", "", []string{"If-None-Match", "W/" + tag}, http.StatusNotModified},
+			{"modified", "GET", "", []string{"If-None-Match", `"7.json"`}, http.StatusOK},
+			{"put without If-Match", "PUT", `{"name":"Shyam","email":"shyam@xmpl.com"}`, nil, http.StatusPreconditionRequired},
+			{"put with a stale ETag", "PUT", `{"name":"Shyam","email":"shyam@xmpl.com"}`, []string{"If-Match", `"7.json"`}, http.StatusPreconditionFailed},
+			{"put with a weak ETag", "PUT", `{"name":"Shyam","email":"shyam@xmpl.com"}`, []string{"If-Match", "W/" + tag}, http.StatusPreconditionFailed},
+			{"delete without If-Match", "DELETE", "", nil, http.StatusPreconditionRequired},
+			{"put with one of several ETags", "PUT", `{"name":"Shyam","city":"Mysore","email":"shyam@xmpl.com"}`, []string{"If-Match", `"7.json", "1.csv"`}, http.StatusOK},
+			{"patch with the replaced ETag", "PATCH", `{"city":"Chennai"}`, []string{"If-Match", tag}, http.StatusPreconditionFailed},
+		}
+		for _, st := range subtests {
+			t.Run(st.name, func(t *testing.T) {
+				w := serve(r, st.method, "/api/customers/2", st.body, st.header...)
+				if w.Code != st.want {
+					t.Errorf("wanted %v, got %v", st.want, w.Code)
+				}
+			})
+		}
+
+		w = serve(r, "GET", "/api/customers/2", "")
+		var c model.Customer
+		json.NewDecoder(w.Body).Decode(&c)
+		if c.City != "Mysore" || c.Version != 2 || w.Header().Get("ETag") != `"2.json"` {
+			t.Errorf("wanted Mysore at version 2, got %+v with ETag %v", c, w.Header().Get("ETag"))
+		}
+	})
+	t.Run("re-create deleted customer", func(t *testing.T) {
+		if w := serve(r, "DELETE", "/api/customers/3", "", "If-Match", "*"); w.Code != http.StatusNoContent {
+			t.Fatalf("wanted %v, got %v", http.StatusNoContent, w.Code)
+		}
+		w := serve(r, "POST", "/api/customers", `{"name":"Anil","city":"Bangalore","email":"anil@xmpl.com"}`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("wanted %v, got %v: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		// the deleted customer can not have its email back
+		if w := serve(r, "POST", "/api/customers/3:restore", ""); w.Code != http.StatusConflict {
+			t.Errorf("wanted %v, got %v", http.StatusConflict, w.Code)
+		}
+	})
+}
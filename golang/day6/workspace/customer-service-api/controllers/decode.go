@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"api/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// decodeJson reads a single JSON value from the request body into v,
+// rejecting bodies over maxBodyBytes and fields v does not have. On failure
+// the error response has already been written and false is returned.
+func decodeJson(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(v)
+	if err == nil && decoder.More() {
+		err = errors.New("body must contain a single JSON object")
+	}
+	if err == nil {
+		return true
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		writeErrorMessage(w, http.StatusRequestEntityTooLarge, "body_too_large",
+			fmt.Sprintf("Request body must not exceed %d bytes.", maxBytesErr.Limit))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for unknown fields
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		writeValidationErrors(w, []model.FieldError{{Field: field, Message: "is not a known field"}})
+	case errors.Is(err, io.EOF):
+		writeBadRequest(w, errors.New("body is empty"))
+	default:
+		writeBadRequest(w, err)
+	}
+	return false
+}
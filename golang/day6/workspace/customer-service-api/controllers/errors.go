@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"api/dao"
+	"api/logging"
+	"api/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// writeError maps a repository error to its HTTP status and writes it as a
+// model.ErrorMessage. id is the customer the request was about, if any.
+// Errors the client cannot act on are logged against the request ID and
+// reported without detail.
+func writeError(w http.ResponseWriter, r *http.Request, id int, err error) {
+	var validationErr *dao.ValidationError
+
+	switch {
+	case errors.Is(err, dao.ErrNotFound):
+		writeNotFound(w, id)
+	case errors.Is(err, dao.ErrDuplicateEmail):
+		writeErrorMessage(w, http.StatusConflict, "duplicate_email", err.Error())
+	case errors.Is(err, dao.ErrVersionMismatch):
+		writeErrorMessage(w, http.StatusPreconditionFailed, "precondition_failed",
+			"The customer was changed since it was read; get it again for its current ETag.")
+	case errors.As(err, &validationErr):
+		writeValidationErrors(w, []model.FieldError{{Field: validationErr.Field, Message: validationErr.Message}})
+	case errors.Is(err, dao.ErrUnavailable):
+		logging.Logger(r.Context()).Warn("customer store unavailable", "error", err)
+		w.Header().Set("Retry-After", "5")
+		writeErrorMessage(w, http.StatusServiceUnavailable, "unavailable", dao.ErrUnavailable.Error())
+	default:
+		logging.Logger(r.Context()).Error("unexpected error", "error", err)
+		writeErrorMessage(w, http.StatusInternalServerError, "internal", "An unexpected error occurred.")
+	}
+}
+
+func writeNotFound(w http.ResponseWriter, id int) {
+	writeErrorMessage(w, http.StatusNotFound, "not_found", fmt.Sprintf("No customer found for id %d.", id))
+}
+
+func writeBadRequest(w http.ResponseWriter, cause error) {
+	writeErrorMessage(w, http.StatusBadRequest, "bad_request", fmt.Sprintf("Invalid request body: %v", cause))
+}
+
+func writeValidationErrors(w http.ResponseWriter, errs []model.FieldError) {
+	writeInvalidFields(w, "The customer has invalid fields.", errs)
+}
+
+func writeInvalidFields(w http.ResponseWriter, message string, errs []model.FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity) // 422
+	json.NewEncoder(w).Encode(model.ErrorMessage{
+		Code:    "validation_failed",
+		Message: message,
+		Errors:  errs,
+	})
+}
+
+// writeErrorMessage always answers in JSON, whatever representation was
+// negotiated for the success case.
+func writeErrorMessage(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(model.ErrorMessage{Code: code, Message: message})
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"api/dao"
+	"api/model"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultEventLimit = 100
+	maxEventLimit     = 1000
+)
+
+// EventHandler serves the /api/admin/events and /api/admin/outbox routes,
+// which show the outbox and replay it to a sink from an offset.
+type EventHandler struct {
+	events dao.CustomerEventRepository
+	sinks  []string
+}
+
+// NewEventHandler serves the outbox of events to admins; sinks are the
+// names of the sinks the relay publishes to.
+func NewEventHandler(events dao.CustomerEventRepository, sinks []string) EventHandler {
+	return EventHandler{events: events, sinks: sinks}
+}
+
+// HandleListEvents serves GET /api/admin/events?after=...&limit=...
+func (h EventHandler) HandleListEvents(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	var after int64
+	limit := defaultEventLimit
+	var err error
+	if v := values.Get("after"); v != "" {
+		if after, err = strconv.ParseInt(v, 10, 64); err != nil || after < 0 {
+			writeErrorMessage(w, http.StatusBadRequest, "bad_request", "after must be a number, at least 0")
+			return
+		}
+	}
+	if v := values.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxEventLimit {
+			writeErrorMessage(w, http.StatusBadRequest, "bad_request",
+				fmt.Sprintf("limit must be a number between 1 and %d", maxEventLimit))
+			return
+		}
+	}
+
+	events, err := h.events.EventsAfter(r.Context(), after, limit)
+	if err != nil {
+		writeError(w, r, 0, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.CustomerEventList{Data: events})
+}
+
+// HandleGetOffset serves GET /api/admin/outbox/{sink}.
+func (h EventHandler) HandleGetOffset(w http.ResponseWriter, r *http.Request) {
+	sink, ok := h.sink(w, r)
+	if !ok {
+		return
+	}
+	offset, err := h.events.Offset(r.Context(), sink)
+	if err != nil {
+		writeError(w, r, 0, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.OutboxOffset{Sink: sink, Offset: offset})
+}
+
+// HandleSetOffset serves PUT /api/admin/outbox/{sink}. Setting the offset
+// back replays the events after it to the sink; setting it forward skips
+// them.
+func (h EventHandler) HandleSetOffset(w http.ResponseWriter, r *http.Request) {
+	sink, ok := h.sink(w, r)
+	if !ok {
+		return
+	}
+	var req model.OutboxOffset
+	if !decodeJson(w, r, &req) {
+		return
+	}
+	if req.Offset < 0 {
+		writeInvalidFields(w, "The offset is invalid.", []model.FieldError{{Field: "offset", Message: "must not be negative"}})
+		return
+	}
+	if err := h.events.SetOffset(r.Context(), sink, req.Offset); err != nil {
+		writeError(w, r, 0, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.OutboxOffset{Sink: sink, Offset: req.Offset})
+}
+
+// sink returns the sink named in the path, answering 404 for one the relay
+// does not publish to.
+func (h EventHandler) sink(w http.ResponseWriter, r *http.Request) (string, bool) {
+	sink := mux.Vars(r)["sink"]
+	if !slices.Contains(h.sinks, sink) {
+		writeErrorMessage(w, http.StatusNotFound, "not_found", fmt.Sprintf("No outbox sink named %q.", sink))
+		return "", false
+	}
+	return sink, true
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"api/dao"
+	"api/model"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestEventHandler(t *testing.T) {
+	repo := dao.NewMemoryCustomerRepository()
+	for _, email := range []string{"vinod@vinod.co", "shyam@xmpl.com", "anil@xmpl.com"} {
+		repo.Save(context.Background(), model.Customer{Name: "Vinod", Email: email})
+	}
+	h := NewEventHandler(repo.Events(), []string{"log", "webhook"})
+	r := mux.NewRouter()
+	r.HandleFunc("/api/admin/events", h.HandleListEvents).Methods("GET")
+	r.HandleFunc("/api/admin/outbox/{sink}", h.HandleGetOffset).Methods("GET")
+	r.HandleFunc("/api/admin/outbox/{sink}", h.HandleSetOffset).Methods("PUT")
+
+	t.Run("list", func(t *testing.T) {
+		w := serve(r, "GET", "/api/admin/events?after=1&limit=1", "")
+		var list model.CustomerEventList
+		json.NewDecoder(w.Body).Decode(&list)
+		if w.Code != http.StatusOK || len(list.Data) != 1 || list.Data[0].Id != 2 || list.Data[0].Type != model.CustomerCreated {
+			t.Errorf("wanted event 2, got %v %+v", w.Code, list)
+		}
+		for _, target := range []string{"/api/admin/events?after=-1", "/api/admin/events?limit=1001"} {
+			if w := serve(r, "GET", target, ""); w.Code != http.StatusBadRequest {
+				t.Errorf("%s: wanted %v, got %v", target, http.StatusBadRequest, w.Code)
+			}
+		}
+	})
+
+	t.Run("offsets", func(t *testing.T) {
+		w := serve(r, "PUT", "/api/admin/outbox/webhook", `{"offset": 2}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("wanted %v, got %v", http.StatusOK, w.Code)
+		}
+		w = serve(r, "GET", "/api/admin/outbox/webhook", "")
+		var offset model.OutboxOffset
+		json.NewDecoder(w.Body).Decode(&offset)
+		if offset.Sink != "webhook" || offset.Offset != 2 {
+			t.Errorf("wanted webhook at 2, got %+v", offset)
+		}
+
+		if w := serve(r, "PUT", "/api/admin/outbox/webhook", `{"offset": -1}`); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("wanted %v, got %v", http.StatusUnprocessableEntity, w.Code)
+		}
+		// only the configured sinks have offsets
+		if w := serve(r, "GET", "/api/admin/outbox/file", ""); w.Code != http.StatusNotFound {
+			t.Errorf("wanted %v, got %v", http.StatusNotFound, w.Code)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"api/logging"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Pinger is implemented by whatever the service depends on, e.g. the
+// database pool.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthHandler serves the liveness and readiness probes.
+type HealthHandler struct {
+	pinger   Pinger
+	draining atomic.Bool
+}
+
+func NewHealthHandler(pinger Pinger) *HealthHandler {
+	return &HealthHandler{pinger: pinger}
+}
+
+// Drain makes /readyz fail from now on, so load balancers stop sending
+// traffic while the server shuts down.
+func (h *HealthHandler) Drain() {
+	h.draining.Store(true)
+}
+
+// HandleHealthz reports that the process is up; it never touches the
+// database, so a database outage does not get the container restarted.
+func (h *HealthHandler) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, http.StatusOK, "ok")
+}
+
+// HandleReadyz reports whether the service can take traffic: it is not
+// shutting down and the database answers a ping.
+func (h *HealthHandler) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	if h.draining.Load() {
+		writeStatus(w, http.StatusServiceUnavailable, "shutting down")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if err := h.pinger.Ping(ctx); err != nil {
+		logging.Logger(r.Context()).Warn("readiness check failed", "error", err)
+		writeStatus(w, http.StatusServiceUnavailable, "database unavailable")
+		return
+	}
+	writeStatus(w, http.StatusOK, "ready")
+}
+
+func writeStatus(w http.ResponseWriter, code int, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"status": status})
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type MockPinger struct {
+	err error
+}
+
+func (p MockPinger) Ping(ctx context.Context) error {
+	return p.err
+}
+
+func TestHealthHandler(t *testing.T) {
+	subtests := []struct {
+		name     string
+		pinger   MockPinger
+		drain    bool
+		liveness int
+		ready    int
+	}{
+		{"database up", MockPinger{}, false, http.StatusOK, http.StatusOK},
+		{"database down", MockPinger{err: errors.New("connection refused")}, false, http.StatusOK, http.StatusServiceUnavailable},
+		{"shutting down", MockPinger{}, true, http.StatusOK, http.StatusServiceUnavailable},
+	}
+	for _, st := range subtests {
+		t.Run(st.name, func(t *testing.T) {
+			h := NewHealthHandler(st.pinger)
+			if st.drain {
+				h.Drain()
+			}
+
+			w := httptest.NewRecorder()
+			h.HandleHealthz(w, httptest.NewRequest("GET", "/healthz", nil))
+			if w.Code != st.liveness {
+				t.Errorf("healthz: wanted %v, got %v", st.liveness, w.Code)
+			}
+
+			w = httptest.NewRecorder()
+			h.HandleReadyz(w, httptest.NewRequest("GET", "/readyz", nil))
+			if w.Code != st.ready {
+				t.Errorf("readyz: wanted %v, got %v", st.ready, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"api/dao"
+	"api/model"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// HistoryHandler serves GET /api/customers/{id}/history, the audit trail
+// of one customer.
+type HistoryHandler struct {
+	repo  dao.CustomerRepository
+	audit dao.CustomerAuditRepository
+}
+
+// NewHistoryHandler serves the audit trail kept alongside repo.
+func NewHistoryHandler(repo dao.CustomerRepository, audit dao.CustomerAuditRepository) HistoryHandler {
+	return HistoryHandler{repo: repo, audit: audit}
+}
+
+// HandleGetHistory serves GET /api/customers/{id}/history?after=...&limit=...
+// The history of a deleted customer is still there; a customer with no
+// history, and no longer or never there, is not found.
+func (h HistoryHandler) HandleGetHistory(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	values := r.URL.Query()
+	var after int64
+	limit := defaultEventLimit
+	var err error
+	if v := values.Get("after"); v != "" {
+		if after, err = strconv.ParseInt(v, 10, 64); err != nil || after < 0 {
+			writeErrorMessage(w, http.StatusBadRequest, "bad_request", "after must be a number, at least 0")
+			return
+		}
+	}
+	if v := values.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxEventLimit {
+			writeErrorMessage(w, http.StatusBadRequest, "bad_request",
+				fmt.Sprintf("limit must be a number between 1 and %d", maxEventLimit))
+			return
+		}
+	}
+
+	history, err := h.audit.History(r.Context(), id, after, limit)
+	if err != nil {
+		writeError(w, r, id, err)
+		return
+	}
+	if len(history) == 0 && after == 0 {
+		// e.g. a customer added before the audit trail was kept
+		if _, err := h.repo.FindById(r.Context(), id); err != nil {
+			writeError(w, r, id, err)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.CustomerHistory{Data: history})
+}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"api/dao"
+	"api/model"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestHistoryHandler(t *testing.T) {
+	ctx := context.Background()
+	repo := dao.NewMemoryCustomerRepository(model.Customer{Id: 1, Name: "Vinod", Email: "vinod@vinod.co"})
+	id, _ := repo.Save(ctx, model.Customer{Name: "Shyam", City: "Chennai", Email: "shyam@xmpl.com"})
+	repo.Delete(ctx, id, 0)
+	h := NewHistoryHandler(repo, repo.Audit())
+	r := mux.NewRouter()
+	r.HandleFunc("/api/customers/{id}/history", h.HandleGetHistory).Methods("GET")
+
+	subtests := []struct {
+		name    string
+		target  string
+		status  int
+		entries int
+	}{
+		{"deleted customer", "/api/customers/2/history", http.StatusOK, 2},
+		{"paged", "/api/customers/2/history?after=1&limit=1", http.StatusOK, 1},
+		{"customer without history", "/api/customers/1/history", http.StatusOK, 0},
+		{"unknown customer", "/api/customers/3/history", http.StatusNotFound, 0},
+		{"bad after", "/api/customers/2/history?after=x", http.StatusBadRequest, 0},
+		{"bad limit", "/api/customers/2/history?limit=0", http.StatusBadRequest, 0},
+	}
+	for _, st := range subtests {
+		t.Run(st.name, func(t *testing.T) {
+			w := serve(r, "GET", st.target, "")
+			if w.Code != st.status {
+				t.Fatalf("wanted %v, got %v", st.status, w.Code)
+			}
+			if st.status != http.StatusOK {
+				return
+			}
+			var history model.CustomerHistory
+			if err := json.NewDecoder(w.Body).Decode(&history); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			if len(history.Data) != st.entries {
+				t.Errorf("wanted %d entries, got %+v", st.entries, history.Data)
+			}
+		})
+	}
+}
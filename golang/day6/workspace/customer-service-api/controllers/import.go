@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"api/model"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// rowReader reads the customers of an import one at a time. Next returns
+// io.EOF after the last row. A rowError only spoils its own row; any
+// other error means the rest of the body can not be read.
+type rowReader interface {
+	Next() (model.Customer, error)
+}
+
+type rowError struct {
+	field   string
+	message string
+}
+
+func (e *rowError) Error() string { return e.message }
+
+// jsonArrayReader decodes the elements of a JSON array one by one.
+type jsonArrayReader struct {
+	decoder *json.Decoder
+	started bool
+}
+
+func (jr *jsonArrayReader) Next() (model.Customer, error) {
+	if !jr.started {
+		jr.started = true
+		token, err := jr.decoder.Token()
+		if err != nil {
+			return model.Customer{}, emptyToEOF(err)
+		}
+		if token != json.Delim('[') {
+			return model.Customer{}, errors.New("body must be a JSON array of customers")
+		}
+	}
+	if !jr.decoder.More() {
+		if _, err := jr.decoder.Token(); err != nil { // the closing ]
+			return model.Customer{}, err
+		}
+		if _, err := jr.decoder.Token(); err != io.EOF {
+			return model.Customer{}, errors.New("body must hold nothing after the JSON array")
+		}
+		return model.Customer{}, io.EOF
+	}
+
+	var c model.Customer
+	err := jr.decoder.Decode(&c)
+	// a value of the wrong type or an unknown field is still read in
+	// full, so decoding can go on with the next element
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+		return c, nil
+	case errors.As(err, &typeErr):
+		return c, &rowError{field: typeErr.Field, message: "has the wrong type"}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return c, &rowError{field: strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`),
+			message: "is not a known field"}
+	}
+	return c, err
+}
+
+// ndjsonReader decodes one customer per line; blank lines are skipped.
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+}
+
+func (nr *ndjsonReader) Next() (model.Customer, error) {
+	for nr.scanner.Scan() {
+		line := bytes.TrimSpace(nr.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var c model.Customer
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&c); err != nil {
+			return c, &rowError{message: "invalid JSON: " + strings.TrimPrefix(err.Error(), "json: ")}
+		}
+		return c, nil
+	}
+	if err := nr.scanner.Err(); err != nil {
+		return model.Customer{}, err
+	}
+	return model.Customer{}, io.EOF
+}
+
+// csvReader maps the columns named in the header row to customer fields.
+// An id column is accepted and ignored, like ids everywhere in an import.
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCsvReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, emptyToEOF(err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "id", "name", "city", "email":
+			columns[name] = i
+		default:
+			return nil, fmt.Errorf("unknown CSV column %q; use id, name, city and email", name)
+		}
+	}
+	return &csvReader{reader: reader, columns: columns}, nil
+}
+
+func (cr *csvReader) Next() (model.Customer, error) {
+	record, err := cr.reader.Read()
+	if errors.Is(err, csv.ErrFieldCount) {
+		return model.Customer{}, &rowError{message: fmt.Sprintf("has %d fields, the header has %d", len(record), cr.reader.FieldsPerRecord)}
+	}
+	if err != nil {
+		return model.Customer{}, err
+	}
+	field := func(name string) string {
+		if i, ok := cr.columns[name]; ok {
+			return record[i]
+		}
+		return ""
+	}
+	return model.Customer{Name: field("name"), City: field("city"), Email: field("email")}, nil
+}
+
+// emptyToEOF reports an empty body as such rather than as a syntax error.
+func emptyToEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return errors.New("body is empty")
+	}
+	return err
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"api/dao"
+	"api/model"
+	"api/render"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// etag is the strong entity tag of the representation of a customer
+// negotiated for r: its version and the extension of the media type, e.g.
+// "3.json" or "3.csv", as the bytes of each representation differ.
+func etag(r *http.Request, c model.Customer) string {
+	return `"` + strconv.Itoa(c.Version) + render.Extension(render.MediaType(r)) + `"`
+}
+
+// tagVersion returns the version in a strong entity tag from etag, of
+// any representation.
+func tagVersion(tag string) (int, bool) {
+	if !strings.HasPrefix(tag, `"`) {
+		return 0, false
+	}
+	version, _, _ := strings.Cut(strings.Trim(tag, `"`), ".")
+	v, err := strconv.Atoi(version)
+	return v, err == nil && v > 0
+}
+
+// entityTags splits an If-Match or If-None-Match header into its entity
+// tags, weak ones still carrying their W/ prefix.
+func entityTags(header string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// noneMatch reports whether If-None-Match is absent or matches none of tag,
+// comparing weakly as RFC 9110 asks for it.
+func noneMatch(r *http.Request, tag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return true
+	}
+	for _, t := range entityTags(header) {
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return false
+		}
+	}
+	return true
+}
+
+// ifMatchVersion returns the version a write to the customer with id is
+// conditional on, taken from If-Match; 0 stands for "*". Unconditional
+// writes are refused with 428 Precondition Required (RFC 6585), rather
+// than the 412 of a stale ETag, so that no client overwrites a change it
+// has not seen. On failure the response has been written and ok is false.
+func (h CustomerHandler) ifMatchVersion(w http.ResponseWriter, r *http.Request, id int) (version int, ok bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		writeErrorMessage(w, http.StatusPreconditionRequired, "precondition_required",
+			"Send If-Match with the ETag of the customer, or *, to change it.")
+		return 0, false
+	}
+
+	versions := []int{}
+	for _, tag := range entityTags(header) {
+		if tag == "*" {
+			return 0, true
+		}
+		// weak tags never match, If-Match compares strongly; the tag of any
+		// representation of the version will do, a write changes them all
+		if v, ok := tagVersion(tag); ok {
+			versions = append(versions, v)
+		}
+	}
+
+	switch len(versions) {
+	case 0:
+		writeError(w, r, id, dao.ErrVersionMismatch)
+		return 0, false
+	case 1:
+		return versions[0], true
+	}
+	// with several tags the write is conditional on the one stored now, if
+	// it is among them
+	c, err := h.repo.FindById(r.Context(), id)
+	if err != nil {
+		writeError(w, r, id, err)
+		return 0, false
+	}
+	if !slices.Contains(versions, c.Version) {
+		writeError(w, r, id, dao.ErrVersionMismatch)
+		return 0, false
+	}
+	return c.Version, true
+}
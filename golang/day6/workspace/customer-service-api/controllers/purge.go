@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"api/purge"
+	"encoding/json"
+	"net/http"
+)
+
+// PurgeHandler serves POST /api/admin/customers:purge, which runs the
+// purge job right away rather than at its next interval.
+type PurgeHandler struct {
+	job *purge.Job
+}
+
+func NewPurgeHandler(job *purge.Job) PurgeHandler {
+	return PurgeHandler{job: job}
+}
+
+// HandlePurge removes for good the customers deleted before the retention
+// period and reports how many there were.
+func (h PurgeHandler) HandlePurge(w http.ResponseWriter, r *http.Request) {
+	report, err := h.job.Purge(r.Context())
+	if err != nil {
+		writeError(w, r, 0, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
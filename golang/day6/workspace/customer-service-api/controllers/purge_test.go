@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"api/dao"
+	"api/model"
+	"api/purge"
+	"api/settings"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestPurgeHandler(t *testing.T) {
+	ctx := context.Background()
+	repo := dao.NewMemoryCustomerRepository()
+	id, _ := repo.Save(ctx, model.Customer{Name: "Vinod", Email: "vinod@vinod.co"})
+	repo.Delete(ctx, id, 0)
+	r := mux.NewRouter()
+
+	// a negative retention period, which the configuration does not allow,
+	// purges the customers deleted just now
+	h := NewPurgeHandler(purge.NewJob(repo, settings.Purge{Retention: -time.Hour, Interval: time.Hour}))
+	r.HandleFunc("/api/admin/customers:purge", h.HandlePurge).Methods("POST")
+
+	w := serve(r, "POST", "/api/admin/customers:purge", "")
+	var report model.PurgeReport
+	json.NewDecoder(w.Body).Decode(&report)
+	if w.Code != http.StatusOK || report.Purged != 1 || report.DeletedBefore.IsZero() {
+		t.Errorf("wanted 1 customer purged, got %v %+v", w.Code, report)
+	}
+	if _, err := repo.Restore(ctx, id); err != dao.ErrNotFound {
+		t.Errorf("wanted %v, got %v", dao.ErrNotFound, err)
+	}
+}
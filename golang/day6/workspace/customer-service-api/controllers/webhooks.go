@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"api/dao"
+	"api/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxWebhookURLLength = 2000
+	minSecretLength     = 16
+	maxSecretLength     = 255
+
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 500
+)
+
+// WebhookHandler serves the /api/admin/webhooks routes: subscriptions to
+// customer events and the history of their deliveries.
+type WebhookHandler struct {
+	webhooks dao.WebhookRepository
+	now      func() time.Time
+}
+
+func NewWebhookHandler(webhooks dao.WebhookRepository) WebhookHandler {
+	return WebhookHandler{webhooks: webhooks, now: time.Now}
+}
+
+// HandleCreateWebhook subscribes a URL to events. The secret is never
+// returned; the subscriber keeps it to check the signatures.
+func (h WebhookHandler) HandleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req model.NewWebhookSubscription
+	if !decodeJson(w, r, &req) {
+		return
+	}
+	if errs := validateNewWebhook(&req); len(errs) > 0 {
+		writeInvalidFields(w, "The webhook subscription has invalid fields.", errs)
+		return
+	}
+
+	sub, err := h.webhooks.CreateSubscription(r.Context(), model.WebhookSubscription{
+		URL:       req.URL,
+		Events:    req.Events,
+		Secret:    req.Secret,
+		CreatedAt: h.now(),
+	})
+	if err != nil {
+		writeError(w, r, 0, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/api/admin/webhooks/%d", sub.Id))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sub)
+}
+
+func (h WebhookHandler) HandleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	subs, err := h.webhooks.FindSubscriptions(r.Context())
+	if err != nil {
+		writeError(w, r, 0, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.WebhookSubscriptionList{Data: subs})
+}
+
+func (h WebhookHandler) HandleGetWebhook(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.subscription(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sub)
+}
+
+// HandleDeleteWebhook unsubscribes; the deliveries not yet made are
+// dropped, and the history goes with them.
+func (h WebhookHandler) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookId(w, r)
+	if !ok {
+		return
+	}
+	err := h.webhooks.DeleteSubscription(r.Context(), id)
+	if errors.Is(err, dao.ErrWebhookNotFound) {
+		writeWebhookNotFound(w, id)
+		return
+	}
+	if err != nil {
+		writeError(w, r, 0, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListDeliveries serves GET /api/admin/webhooks/{id}/deliveries,
+// the newest first with every attempt; ?status=dead lists the
+// dead-lettered ones.
+func (h WebhookHandler) HandleListDeliveries(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	status := values.Get("status")
+	switch status {
+	case "", model.DeliveryPending, model.DeliverySucceeded, model.DeliveryDead:
+	default:
+		writeErrorMessage(w, http.StatusBadRequest, "bad_request", "status must be pending, succeeded or dead")
+		return
+	}
+	limit := defaultDeliveryLimit
+	if v := values.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxDeliveryLimit {
+			writeErrorMessage(w, http.StatusBadRequest, "bad_request",
+				fmt.Sprintf("limit must be a number between 1 and %d", maxDeliveryLimit))
+			return
+		}
+	}
+	sub, ok := h.subscription(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := h.webhooks.FindDeliveries(r.Context(), sub.Id, status, limit)
+	if err != nil {
+		writeError(w, r, 0, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.WebhookDeliveryList{Data: deliveries})
+}
+
+// HandleRedeliver makes a delivery due again with a fresh set of attempts,
+// typically one that was dead-lettered.
+func (h WebhookHandler) HandleRedeliver(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookId(w, r)
+	if !ok {
+		return
+	}
+	deliveryId, err := strconv.ParseInt(mux.Vars(r)["delivery"], 10, 64)
+	if err != nil {
+		writeErrorMessage(w, http.StatusBadRequest, "bad_request", "delivery must be a number")
+		return
+	}
+
+	delivery, err := h.webhooks.Redeliver(r.Context(), id, deliveryId, h.now())
+	if errors.Is(err, dao.ErrDeliveryNotFound) {
+		writeErrorMessage(w, http.StatusNotFound, "not_found",
+			fmt.Sprintf("No delivery %d found for webhook subscription %d.", deliveryId, id))
+		return
+	}
+	if err != nil {
+		writeError(w, r, 0, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// subscription returns the subscription with the id in the path,
+// answering 400 or 404 when there is none.
+func (h WebhookHandler) subscription(w http.ResponseWriter, r *http.Request) (model.WebhookSubscription, bool) {
+	id, ok := webhookId(w, r)
+	if !ok {
+		return model.WebhookSubscription{}, false
+	}
+	sub, err := h.webhooks.FindSubscription(r.Context(), id)
+	if errors.Is(err, dao.ErrWebhookNotFound) {
+		writeWebhookNotFound(w, id)
+		return sub, false
+	}
+	if err != nil {
+		writeError(w, r, 0, err)
+		return sub, false
+	}
+	return sub, true
+}
+
+func webhookId(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorMessage(w, http.StatusBadRequest, "bad_request", "id must be a number")
+		return 0, false
+	}
+	return id, true
+}
+
+func writeWebhookNotFound(w http.ResponseWriter, id int) {
+	writeErrorMessage(w, http.StatusNotFound, "not_found", fmt.Sprintf("No webhook subscription found for id %d.", id))
+}
+
+func validateNewWebhook(req *model.NewWebhookSubscription) []model.FieldError {
+	errs := []model.FieldError{}
+	req.URL = strings.TrimSpace(req.URL)
+	if req.URL == "" {
+		errs = append(errs, model.FieldError{Field: "url", Message: "is required"})
+	} else if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, model.FieldError{Field: "url", Message: "must be an absolute http or https URL"})
+	} else if len(req.URL) > maxWebhookURLLength {
+		errs = append(errs, model.FieldError{Field: "url", Message: fmt.Sprintf("must be at most %d characters", maxWebhookURLLength)})
+	}
+
+	if len(req.Events) == 0 {
+		errs = append(errs, model.FieldError{Field: "events", Message: "is required"})
+	}
+	for _, event := range req.Events {
+		if !slices.Contains(model.CustomerEventTypes, event) {
+			errs = append(errs, model.FieldError{Field: "events",
+				Message: fmt.Sprintf("%q is not one of %s", event, strings.Join(model.CustomerEventTypes, ", "))})
+		}
+	}
+	slices.Sort(req.Events)
+	req.Events = slices.Compact(req.Events)
+
+	if len(req.Secret) < minSecretLength || len(req.Secret) > maxSecretLength {
+		errs = append(errs, model.FieldError{Field: "secret",
+			Message: fmt.Sprintf("must be %d to %d characters", minSecretLength, maxSecretLength)})
+	}
+	return errs
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"api/dao"
+	"api/model"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	repo := dao.NewMemoryWebhookRepository()
+	h := NewWebhookHandler(repo)
+	r := mux.NewRouter()
+	r.HandleFunc("/api/admin/webhooks", h.HandleListWebhooks).Methods("GET")
+	r.HandleFunc("/api/admin/webhooks", h.HandleCreateWebhook).Methods("POST")
+	r.HandleFunc("/api/admin/webhooks/{id}", h.HandleGetWebhook).Methods("GET")
+	r.HandleFunc("/api/admin/webhooks/{id}", h.HandleDeleteWebhook).Methods("DELETE")
+	r.HandleFunc("/api/admin/webhooks/{id}/deliveries", h.HandleListDeliveries).Methods("GET")
+	r.HandleFunc("/api/admin/webhooks/{id}/deliveries/{delivery}:redeliver", h.HandleRedeliver).Methods("POST")
+
+	t.Run("create", func(t *testing.T) {
+		w := serve(r, "POST", "/api/admin/webhooks",
+			`{"url": "https://partner.example/hooks", "events": ["customer.deleted", "customer.created"], "secret": "0123456789abcdef"}`)
+		if w.Code != http.StatusCreated || w.Header().Get("Location") != "/api/admin/webhooks/1" {
+			t.Fatalf("wanted %v at /api/admin/webhooks/1, got %v %v", http.StatusCreated, w.Code, w.Header().Get("Location"))
+		}
+		if strings.Contains(w.Body.String(), "0123456789abcdef") {
+			t.Errorf("wanted no secret in the response, got %v", w.Body.String())
+		}
+		var sub model.WebhookSubscription
+		json.NewDecoder(w.Body).Decode(&sub)
+		if len(sub.Events) != 2 || sub.Events[0] != model.CustomerCreated {
+			t.Errorf("wanted both event types, got %+v", sub)
+		}
+	})
+
+	t.Run("create invalid", func(t *testing.T) {
+		w := serve(r, "POST", "/api/admin/webhooks", `{"url": "ftp://partner.example", "events": ["customer.merged"], "secret": "short"}`)
+		var msg model.ErrorMessage
+		json.NewDecoder(w.Body).Decode(&msg)
+		if w.Code != http.StatusUnprocessableEntity || len(msg.Errors) != 3 {
+			t.Errorf("wanted 422 for url, events and secret, got %v %+v", w.Code, msg)
+		}
+	})
+
+	t.Run("deliveries", func(t *testing.T) {
+		now := time.Now()
+		ctx := context.Background()
+		repo.AddDelivery(ctx, model.WebhookDelivery{SubscriptionId: 1, EventId: 7, EventType: model.CustomerCreated,
+			Status: model.DeliveryPending, NextAttemptAt: &now, CreatedAt: now})
+		claimed, _ := repo.ClaimDeliveries(ctx, now, now, 1)
+		d := claimed[0]
+		d.Status, d.AttemptCount, d.NextAttemptAt, d.LastError = model.DeliveryDead, 1, nil, "500 Internal Server Error"
+		repo.RecordAttempt(ctx, d, model.WebhookAttempt{Attempt: 1, StatusCode: 500, AttemptedAt: now})
+
+		w := serve(r, "GET", "/api/admin/webhooks/1/deliveries?status=dead", "")
+		var list model.WebhookDeliveryList
+		json.NewDecoder(w.Body).Decode(&list)
+		if w.Code != http.StatusOK || len(list.Data) != 1 || len(list.Data[0].Attempts) != 1 {
+			t.Fatalf("wanted the dead delivery with its attempt, got %v %+v", w.Code, list)
+		}
+
+		w = serve(r, "POST", "/api/admin/webhooks/1/deliveries/1:redeliver", "")
+		var redelivered model.WebhookDelivery
+		json.NewDecoder(w.Body).Decode(&redelivered)
+		if w.Code != http.StatusOK || redelivered.Status != model.DeliveryPending {
+			t.Errorf("wanted a pending delivery, got %v %+v", w.Code, redelivered)
+		}
+
+		tests := []struct {
+			method, target string
+			status         int
+		}{
+			{"GET", "/api/admin/webhooks/1/deliveries?status=lost", http.StatusBadRequest},
+			{"GET", "/api/admin/webhooks/1/deliveries?limit=0", http.StatusBadRequest},
+			{"GET", "/api/admin/webhooks/9/deliveries", http.StatusNotFound},
+			{"POST", "/api/admin/webhooks/1/deliveries/9:redeliver", http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			if w := serve(r, tt.method, tt.target, ""); w.Code != tt.status {
+				t.Errorf("%s %s: wanted %v, got %v", tt.method, tt.target, tt.status, w.Code)
+			}
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if w := serve(r, "DELETE", "/api/admin/webhooks/1", ""); w.Code != http.StatusNoContent {
+			t.Errorf("wanted %v, got %v", http.StatusNoContent, w.Code)
+		}
+		if w := serve(r, "GET", "/api/admin/webhooks/1", ""); w.Code != http.StatusNotFound {
+			t.Errorf("wanted %v, got %v", http.StatusNotFound, w.Code)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// SqlAPIKeyRepository is the APIKeyRepository backed by the API_KEYS
+// table. Times are stored in UTC with second precision.
+type SqlAPIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewSqlAPIKeyRepository(db *sql.DB) *SqlAPIKeyRepository {
+	return &SqlAPIKeyRepository{db: db}
+}
+
+const apiKeyColumns = "ID, PREFIX, OWNER, SCOPES, RATE_LIMIT, CREATED_AT, EXPIRES_AT, REVOKED_AT"
+
+func (repo *SqlAPIKeyRepository) Create(ctx context.Context, key model.APIKey, hash string) (model.APIKey, error) {
+	key.CreatedAt = key.CreatedAt.UTC().Truncate(time.Second)
+	if key.ExpiresAt != nil {
+		expiresAt := key.ExpiresAt.UTC().Truncate(time.Second)
+		key.ExpiresAt = &expiresAt
+	}
+
+	result, err := repo.db.ExecContext(ctx,
+		"INSERT INTO API_KEYS(PREFIX, KEY_HASH, OWNER, SCOPES, RATE_LIMIT, CREATED_AT, EXPIRES_AT) VALUES(?, ?, ?, ?, ?, ?, ?)",
+		key.Prefix, hash, key.Owner, strings.Join(key.Scopes, " "), key.RateLimit, key.CreatedAt, key.ExpiresAt)
+	if err != nil {
+		return model.APIKey{}, translateError(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return model.APIKey{}, translateError(err)
+	}
+	key.Id = int(id)
+	return key, nil
+}
+
+func (repo *SqlAPIKeyRepository) FindByHash(ctx context.Context, hash string) (model.APIKey, error) {
+	key, err := scanAPIKey(repo.db.QueryRowContext(ctx, "select "+apiKeyColumns+" from API_KEYS where KEY_HASH=?", hash))
+	if err == sql.ErrNoRows {
+		return model.APIKey{}, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return model.APIKey{}, translateError(err)
+	}
+	return key, nil
+}
+
+func (repo *SqlAPIKeyRepository) FindAll(ctx context.Context) ([]model.APIKey, error) {
+	rows, err := repo.db.QueryContext(ctx, "select "+apiKeyColumns+" from API_KEYS order by ID")
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	keys := []model.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+	return keys, nil
+}
+
+func (repo *SqlAPIKeyRepository) Revoke(ctx context.Context, id int, at time.Time) error {
+	result, err := repo.db.ExecContext(ctx,
+		"UPDATE API_KEYS SET REVOKED_AT=COALESCE(REVOKED_AT, ?) WHERE ID=?", at.UTC().Truncate(time.Second), id)
+	if err != nil {
+		return translateError(err)
+	}
+	err = checkAffected(result)
+	if err == ErrNotFound {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
+
+// scanner is satisfied by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row scanner) (model.APIKey, error) {
+	var key model.APIKey
+	var scopes string
+	var expiresAt, revokedAt sql.NullTime
+	err := row.Scan(&key.Id, &key.Prefix, &key.Owner, &scopes, &key.RateLimit, &key.CreatedAt, &expiresAt, &revokedAt)
+	if err != nil {
+		return key, err
+	}
+	key.Scopes = strings.Fields(scopes)
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return key, nil
+}
@@ -0,0 +1,72 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyRepositories(t *testing.T) {
+	repos := []struct {
+		name string
+		repo APIKeyRepository
+	}{
+		{"sql", NewSqlAPIKeyRepository(newSqliteDb(t))},
+		{"memory", NewMemoryAPIKeyRepository()},
+	}
+	for _, rt := range repos {
+		t.Run(rt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := rt.repo
+			created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+			expires := created.Add(24 * time.Hour)
+
+			key, err := repo.Create(ctx, model.APIKey{
+				Prefix: "abcd1234", Owner: "bookings", Scopes: []string{"customers:read", "customers:write"},
+				RateLimit: 10, CreatedAt: created, ExpiresAt: &expires,
+			}, "hash-1")
+			if err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			if key.Id == 0 {
+				t.Error("was expecting an id; did not get one")
+			}
+
+			found, err := repo.FindByHash(ctx, "hash-1")
+			if err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			if found.Owner != "bookings" || len(found.Scopes) != 2 || found.RateLimit != 10 ||
+				!found.CreatedAt.Equal(created) || found.ExpiresAt == nil || !found.ExpiresAt.Equal(expires) {
+				t.Errorf("wanted the created key back, got %+v", found)
+			}
+			if _, err := repo.FindByHash(ctx, "hash-2"); err != ErrAPIKeyNotFound {
+				t.Errorf("wanted %v, got %v", ErrAPIKeyNotFound, err)
+			}
+
+			revoked := created.Add(time.Hour)
+			if err := repo.Revoke(ctx, key.Id, revoked); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			// a second revocation keeps the first time
+			if err := repo.Revoke(ctx, key.Id, revoked.Add(time.Hour)); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			if err := repo.Revoke(ctx, 99, revoked); err != ErrAPIKeyNotFound {
+				t.Errorf("wanted %v, got %v", ErrAPIKeyNotFound, err)
+			}
+
+			all, err := repo.FindAll(ctx)
+			if err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			if len(all) != 1 || all[0].RevokedAt == nil || !all[0].RevokedAt.Equal(revoked) {
+				t.Errorf("wanted one key revoked at %v, got %+v", revoked, all)
+			}
+			if all[0].Active(revoked) {
+				t.Error("wanted the revoked key to be inactive")
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKeyRepository stores issued API keys by the hash of the key.
+type APIKeyRepository interface {
+	// stores key under hash and returns it with its new id
+	Create(ctx context.Context, key model.APIKey, hash string) (model.APIKey, error)
+
+	// ErrAPIKeyNotFound when no key has hash; revoked and expired keys
+	// are returned like any other
+	FindByHash(ctx context.Context, hash string) (model.APIKey, error)
+
+	// all keys, including revoked ones, by id
+	FindAll(ctx context.Context) ([]model.APIKey, error)
+
+	// marks the key revoked at the given time, unless it already was;
+	// ErrAPIKeyNotFound when there is no such key
+	Revoke(ctx context.Context, id int, at time.Time) error
+}
+
+// MemoryAPIKeyRepository keeps API keys in memory, for the memory and
+// file customer stores; keys do not survive a restart.
+type MemoryAPIKeyRepository struct {
+	mu     sync.RWMutex
+	keys   []model.APIKey
+	hashes []string
+}
+
+func NewMemoryAPIKeyRepository() *MemoryAPIKeyRepository {
+	return &MemoryAPIKeyRepository{}
+}
+
+func (repo *MemoryAPIKeyRepository) Create(ctx context.Context, key model.APIKey, hash string) (model.APIKey, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	key.Id = len(repo.keys) + 1
+	key.Scopes = slices.Clone(key.Scopes)
+	repo.keys = append(repo.keys, key)
+	repo.hashes = append(repo.hashes, hash)
+	return key, nil
+}
+
+func (repo *MemoryAPIKeyRepository) FindByHash(ctx context.Context, hash string) (model.APIKey, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	i := slices.Index(repo.hashes, hash)
+	if i < 0 {
+		return model.APIKey{}, ErrAPIKeyNotFound
+	}
+	return repo.keys[i], nil
+}
+
+func (repo *MemoryAPIKeyRepository) FindAll(ctx context.Context) ([]model.APIKey, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	return slices.Clone(repo.keys), nil
+}
+
+func (repo *MemoryAPIKeyRepository) Revoke(ctx context.Context, id int, at time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if id < 1 || id > len(repo.keys) {
+		return ErrAPIKeyNotFound
+	}
+	if repo.keys[id-1].RevokedAt == nil {
+		repo.keys[id-1].RevokedAt = &at
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+// SqlCustomerAuditRepository reads the CUSTOMER_AUDIT trail that
+// SqlCustomerRepository appends to.
+type SqlCustomerAuditRepository struct {
+	db *sql.DB
+}
+
+func NewSqlCustomerAuditRepository(db *sql.DB) *SqlCustomerAuditRepository {
+	return &SqlCustomerAuditRepository{db: db}
+}
+
+func (repo *SqlCustomerAuditRepository) History(ctx context.Context, customerId int, after int64, limit int) ([]model.AuditEntry, error) {
+	rows, err := repo.db.QueryContext(ctx,
+		"select ID, CUSTOMER_ID, OPERATION, ACTOR, REQUEST_ID, CHANGES, CREATED_AT from CUSTOMER_AUDIT "+
+			"where CUSTOMER_ID=? and ID>? order by ID limit ?",
+		customerId, after, limit)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	history := []model.AuditEntry{}
+	for rows.Next() {
+		var e model.AuditEntry
+		var changes string
+		if err := rows.Scan(&e.Id, &e.CustomerId, &e.Operation, &e.Actor, &e.RequestId, &changes, &e.At); err != nil {
+			return nil, translateError(err)
+		}
+		if err := json.Unmarshal([]byte(changes), &e.Changes); err != nil {
+			return nil, err
+		}
+		history = append(history, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+	return history, nil
+}
@@ -0,0 +1,111 @@
+package dao
+
+import (
+	"api/auth"
+	"api/logging"
+	"api/model"
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCustomerAudit(t *testing.T) {
+	sqlRepo := newSqliteRepository(t)
+	memoryRepo := NewMemoryCustomerRepository()
+	repos := []struct {
+		name  string
+		repo  CustomerRepository
+		audit CustomerAuditRepository
+	}{
+		{"sql", sqlRepo, NewSqlCustomerAuditRepository(sqlRepo.db)},
+		{"memory", memoryRepo, memoryRepo.Audit()},
+	}
+	for _, rt := range repos {
+		t.Run(rt.name, func(t *testing.T) {
+			ctx := logging.WithRequestId(context.Background(), "req-1")
+			keyCtx := auth.WithClaims(ctx, &auth.Claims{APIKey: &model.APIKey{Id: 7}})
+			tokenCtx := auth.WithClaims(ctx, &auth.Claims{Subject: "vinod"})
+			repo, audit := rt.repo, rt.audit
+
+			id, err := repo.Save(ctx, model.Customer{Name: "Vinod", City: "Bangalore", Email: "vinod@vinod.co"})
+			if err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			other, _ := repo.Save(ctx, model.Customer{Name: "Shyam", Email: "shyam@xmpl.com"})
+			if _, err := repo.Update(keyCtx, model.Customer{Id: id, Name: "Vinod", City: "Mysore", Email: "vinod@vinod.co"}); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			city := "Hassan"
+			// failed writes leave no trace
+			if _, err := repo.Patch(tokenCtx, id, 1, model.CustomerPatch{City: &city}); err != ErrVersionMismatch {
+				t.Errorf("wanted %v, got %v", ErrVersionMismatch, err)
+			}
+			if _, err := repo.Patch(tokenCtx, id, 2, model.CustomerPatch{City: &city}); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			if err := repo.Delete(tokenCtx, id, 0); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+
+			history, err := audit.History(ctx, id, 0, 10)
+			if err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			wanted := []struct {
+				operation string
+				actor     string
+				changed   string
+				before    any
+				after     any
+			}{
+				{model.AuditInsert, "anonymous", "city", nil, "Bangalore"},
+				{model.AuditUpdate, "key:7", "city", "Bangalore", "Mysore"},
+				{model.AuditUpdate, "sub:vinod", "city", "Mysore", "Hassan"},
+			}
+			if len(history) != len(wanted)+1 {
+				t.Fatalf("wanted %d entries, got %+v", len(wanted)+1, history)
+			}
+			for i, w := range wanted {
+				e := history[i]
+				change, ok := e.Changes[w.changed]
+				if e.Operation != w.operation || e.Actor != w.actor || e.RequestId != "req-1" || e.CustomerId != id ||
+					e.At.IsZero() || !ok || change.Before != w.before || change.After != w.after {
+					t.Errorf("entry %d: wanted %v by %v changing %v from %v to %v, got %+v",
+						i, w.operation, w.actor, w.changed, w.before, w.after, e)
+				}
+			}
+			// an update touches only what it changes, and the version
+			if _, ok := history[1].Changes["name"]; ok || len(history[1].Changes) != 2 {
+				t.Errorf("wanted city and version changed, got %v", history[1].Changes)
+			}
+			// a delete only sets deletedAt, and the version
+			deleted := history[3]
+			if change := deleted.Changes["deletedAt"]; deleted.Operation != model.AuditDelete || deleted.Actor != "sub:vinod" ||
+				change.Before != nil || change.After == nil || len(deleted.Changes) != 2 {
+				t.Errorf("wanted the delete setting deletedAt, got %+v", deleted)
+			}
+
+			page, _ := audit.History(ctx, id, history[1].Id, 1)
+			if len(page) != 1 || page[0].Id != history[2].Id {
+				t.Errorf("wanted entry 3, got %+v", page)
+			}
+			if others, _ := audit.History(ctx, other, 0, 10); len(others) != 1 || others[0].Operation != model.AuditInsert {
+				t.Errorf("wanted the insert of customer %d, got %+v", other, others)
+			}
+			if none, err := audit.History(ctx, 999, 0, 10); err != nil || len(none) != 0 {
+				t.Errorf("wanted no entries, got %v (%v)", none, err)
+			}
+
+			// subjects are cut to fit, between runes
+			longCtx := auth.WithClaims(ctx, &auth.Claims{Subject: strings.Repeat("é", 200)})
+			if _, err := repo.Patch(longCtx, other, 0, model.CustomerPatch{City: &city}); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			others, _ := audit.History(ctx, other, 0, 10)
+			if actor := others[len(others)-1].Actor; len(actor) != 254 || !utf8.ValidString(actor) {
+				t.Errorf("wanted 254 bytes of valid UTF-8, got %d (%v)", len(actor), utf8.ValidString(actor))
+			}
+		})
+	}
+}
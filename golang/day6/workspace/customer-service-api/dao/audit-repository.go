@@ -0,0 +1,82 @@
+package dao
+
+import (
+	"api/auth"
+	"api/logging"
+	"api/model"
+	"api/utils"
+	"context"
+	"sort"
+	"sync"
+)
+
+// maxActorLength is the most of an actor kept with an audit entry; token
+// subjects have no limit of their own.
+const maxActorLength = 255
+
+// CustomerAuditRepository reads the audit trail that the
+// CustomerRepository appends to with every insert, update, delete,
+// restore and purge it makes. The trail is append-only and outlives the
+// customers in it.
+type CustomerAuditRepository interface {
+	// up to limit entries of the customer with customerId with an id above
+	// after, oldest first; none when the customer has no history
+	History(ctx context.Context, customerId int, after int64, limit int) ([]model.AuditEntry, error)
+}
+
+// auditEntry describes the operation from before to after made on behalf
+// of the caller in ctx; before is nil for an insert and after for a purge.
+func auditEntry(ctx context.Context, operation string, before, after *model.Customer) model.AuditEntry {
+	entry := model.AuditEntry{
+		Operation: operation,
+		Actor:     utils.Truncate(auth.Actor(ctx), maxActorLength),
+		RequestId: logging.RequestId(ctx),
+		Changes:   model.Diff(before, after),
+		At:        now(),
+	}
+	if after != nil {
+		entry.CustomerId = after.Id
+	} else {
+		entry.CustomerId = before.Id
+	}
+	return entry
+}
+
+// MemoryCustomerAuditRepository is the audit trail of a
+// MemoryCustomerRepository. Entries live as long as the process.
+type MemoryCustomerAuditRepository struct {
+	mu      sync.RWMutex
+	entries []model.AuditEntry
+}
+
+func NewMemoryCustomerAuditRepository() *MemoryCustomerAuditRepository {
+	return &MemoryCustomerAuditRepository{}
+}
+
+// add records the operation from before to after; the repository calls
+// it while holding its own lock, like MemoryCustomerEventRepository.add.
+func (repo *MemoryCustomerAuditRepository) add(ctx context.Context, operation string, before, after *model.Customer) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	entry := auditEntry(ctx, operation, before, after)
+	entry.Id = int64(len(repo.entries) + 1)
+	repo.entries = append(repo.entries, entry)
+}
+
+func (repo *MemoryCustomerAuditRepository) History(ctx context.Context, customerId int, after int64, limit int) ([]model.AuditEntry, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	history := []model.AuditEntry{}
+	start := sort.Search(len(repo.entries), func(i int) bool { return repo.entries[i].Id > after })
+	for _, e := range repo.entries[start:] {
+		if len(history) == limit {
+			break
+		}
+		if e.CustomerId == customerId {
+			history = append(history, e)
+		}
+	}
+	return history, nil
+}
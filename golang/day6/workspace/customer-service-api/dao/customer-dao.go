@@ -2,82 +2,603 @@ package dao
 
 import (
 	"api/model"
-	"api/utils"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glebarez/go-sqlite"
+	"github.com/go-sql-driver/mysql"
 )
 
-func AddCustomer(customer model.Customer) int {
-	db := connect()
-	defer db.Close()
+// SqlCustomerRepository is the CustomerRepository backed by the
+// CUSTOMERS table in MySQL or SQLite; its SQL is valid in both. It shares
+// one connection pool and prepares each statement once. Every write adds
+// an event to CUSTOMER_EVENTS and an entry to CUSTOMER_AUDIT in the same
+// transaction. Deleting sets DELETED_AT; every read but Restore's leaves
+// those rows out.
+type SqlCustomerRepository struct {
+	db *sql.DB
+
+	insertStmt   *sql.Stmt
+	findByIdStmt *sql.Stmt
+	updateStmt   *sql.Stmt
+	deleteStmt   *sql.Stmt
+	// the deleted customers too, for Restore
+	findAnyStmt *sql.Stmt
+	restoreStmt *sql.Stmt
+
+	// adds to the CUSTOMER_EVENTS outbox, in the transaction of each write
+	insertEventStmt *sql.Stmt
+	// appends to the CUSTOMER_AUDIT trail, likewise
+	insertAuditStmt *sql.Stmt
+
+	// statements for FindAll and Search, keyed by their SQL text; there is
+	// one per combination of filter, sort column and direction
+	mu        sync.Mutex
+	listStmts map[string]*sql.Stmt
 
-	stmt, err := db.Prepare("INSERT INTO CUSTOMERS(NAME, CITY, EMAIL) VALUES(?, ?, ?)")
-	utils.CheckForError(err)
-	defer stmt.Close()
+	// whether the CUSTOMERS_SEARCH FULLTEXT index of MySQL is there
+	fullText bool
+	// the SQL function lowering like strings.ToLower
+	lower string
+}
+
+func NewSqlCustomerRepository(db *sql.DB) (*SqlCustomerRepository, error) {
+	repo := &SqlCustomerRepository{db: db, listStmts: map[string]*sql.Stmt{}, lower: "LOWER"}
+	if _, ok := db.Driver().(*sqlite.Driver); ok {
+		repo.lower = sqliteLower
+	}
 
-	result, err := stmt.Exec(customer.Name, customer.City, customer.Email)
-	utils.CheckForError(err)
+	statements := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&repo.insertStmt, "INSERT INTO CUSTOMERS(NAME, CITY, EMAIL, UPDATED_AT) VALUES(?, ?, ?, ?)"},
+		{&repo.findByIdStmt, "select " + customerColumns + " from CUSTOMERS where ID=? AND DELETED_AT IS NULL"},
+		{&repo.updateStmt, "UPDATE CUSTOMERS SET NAME=?, CITY=?, EMAIL=?, VERSION=VERSION+1, UPDATED_AT=? " +
+			"WHERE ID=? AND VERSION=? AND DELETED_AT IS NULL"},
+		{&repo.deleteStmt, "UPDATE CUSTOMERS SET DELETED_AT=?, VERSION=VERSION+1, UPDATED_AT=? " +
+			"WHERE ID=? AND VERSION=? AND DELETED_AT IS NULL"},
+		{&repo.findAnyStmt, "select " + customerColumns + " from CUSTOMERS where ID=?"},
+		{&repo.restoreStmt, "UPDATE CUSTOMERS SET DELETED_AT=NULL, VERSION=VERSION+1, UPDATED_AT=? " +
+			"WHERE ID=? AND VERSION=? AND DELETED_AT IS NOT NULL"},
+		{&repo.insertEventStmt, "INSERT INTO CUSTOMER_EVENTS(TYPE, CUSTOMER_ID, PAYLOAD, CREATED_AT) VALUES(?, ?, ?, ?)"},
+		{&repo.insertAuditStmt, "INSERT INTO CUSTOMER_AUDIT(CUSTOMER_ID, OPERATION, ACTOR, REQUEST_ID, CHANGES, CREATED_AT) VALUES(?, ?, ?, ?, ?, ?)"},
+	}
+	for _, s := range statements {
+		stmt, err := db.Prepare(s.query)
+		if err != nil {
+			repo.Close()
+			return nil, translateError(err)
+		}
+		*s.stmt = stmt
+	}
 
-	newId, _ := result.LastInsertId()
-	return int(newId)
+	if _, ok := db.Driver().(*mysql.MySQLDriver); ok {
+		var indexes int
+		err := db.QueryRow("select count(*) from information_schema.statistics " +
+			"where table_schema = DATABASE() and table_name = 'CUSTOMERS' and index_name = 'CUSTOMERS_SEARCH'").Scan(&indexes)
+		repo.fullText = err == nil && indexes > 0
+	}
+	return repo, nil
 }
 
-func GetOneCustomer(id int) *model.Customer {
+// Close releases the prepared statements; the *sql.DB is left open for
+// its owner to close.
+func (repo *SqlCustomerRepository) Close() error {
+	for _, stmt := range []*sql.Stmt{repo.insertStmt, repo.findByIdStmt, repo.updateStmt, repo.deleteStmt, repo.findAnyStmt,
+		repo.restoreStmt, repo.insertEventStmt, repo.insertAuditStmt} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
 
-	db := connect()
-	stmt, err := db.Prepare("select ID, NAME, CITY, EMAIL from CUSTOMERS where ID=?")
-	utils.CheckForError(err)
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for query, stmt := range repo.listStmts {
+		stmt.Close()
+		delete(repo.listStmts, query)
+	}
+	return nil
+}
 
-	row := stmt.QueryRow(id)
-	utils.CheckForError(err)
+// customerColumns are the columns scanCustomer reads, in its order.
+const customerColumns = "ID, NAME, CITY, EMAIL, VERSION, UPDATED_AT, DELETED_AT"
 
+// scanCustomer reads the customerColumns of a row, followed by extra.
+func scanCustomer(row scanner, extra ...any) (model.Customer, error) {
 	var c model.Customer
-	err = row.Scan(&c.Id, &c.Name, &c.City, &c.Email)
+	var updatedAt, deletedAt sql.NullTime
+	dest := append([]any{&c.Id, &c.Name, &c.City, &c.Email, &c.Version, &updatedAt, &deletedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return model.Customer{}, err
+	}
+	if updatedAt.Valid {
+		c.UpdatedAt = &updatedAt.Time
+	}
+	if deletedAt.Valid {
+		c.DeletedAt = &deletedAt.Time
+	}
+	return c, nil
+}
+
+// now is when a write happens, as stored in UPDATED_AT.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
 
+func (repo *SqlCustomerRepository) Save(ctx context.Context, customer model.Customer) (int, error) {
+	err := repo.inTx(ctx, func(tx *sql.Tx) error {
+		updatedAt := now()
+		result, err := tx.StmtContext(ctx, repo.insertStmt).ExecContext(ctx, customer.Name, customer.City, customer.Email, updatedAt)
+		if err != nil {
+			return translateError(err)
+		}
+		newId, err := result.LastInsertId()
+		if err != nil {
+			return translateError(err)
+		}
+		customer.Id, customer.Version, customer.UpdatedAt = int(newId), 1, &updatedAt
+		return repo.changed(ctx, tx, model.AuditInsert, nil, &customer)
+	})
 	if err != nil {
-		return nil
+		return 0, err
 	}
-	return &c
+	return customer.Id, nil
 }
 
-func GetAllCustomers() []model.Customer {
+func (repo *SqlCustomerRepository) FindById(ctx context.Context, id int) (model.Customer, error) {
+	return findCustomer(repo.findByIdStmt.QueryRowContext(ctx, id))
+}
 
-	db := connect()
-	stmt, err := db.Prepare("select ID, NAME, CITY, EMAIL from CUSTOMERS")
-	utils.CheckForError(err)
+// findInTx is FindById within tx; a query outside of it would wait for
+// the transaction on SQLite.
+func (repo *SqlCustomerRepository) findInTx(ctx context.Context, tx *sql.Tx, id int) (model.Customer, error) {
+	return findCustomer(tx.StmtContext(ctx, repo.findByIdStmt).QueryRowContext(ctx, id))
+}
 
-	rows, err := stmt.Query()
-	utils.CheckForError(err)
+func findCustomer(row *sql.Row) (model.Customer, error) {
+	c, err := scanCustomer(row)
+	if err == sql.ErrNoRows {
+		return model.Customer{}, ErrNotFound
+	}
+	if err != nil {
+		return model.Customer{}, translateError(err)
+	}
+	return c, nil
+}
 
-	customers := []model.Customer{}
+// Update always writes conditionally on VERSION; without a version it
+// uses the one stored just before, so that the version it returns is the
+// one it wrote.
+func (repo *SqlCustomerRepository) Update(ctx context.Context, customer model.Customer) (model.Customer, error) {
+	err := repo.inTx(ctx, func(tx *sql.Tx) error {
+		current, err := repo.findInTx(ctx, tx, customer.Id)
+		if err != nil {
+			return err
+		}
+		if customer.Version == 0 {
+			customer.Version = current.Version
+		}
+		customer, err = repo.update(ctx, tx, current, customer)
+		return err
+	})
+	if err != nil {
+		return model.Customer{}, err
+	}
+	return customer, nil
+}
+
+// update writes customer, read as before within tx, conditionally on
+// customer.Version.
+func (repo *SqlCustomerRepository) update(ctx context.Context, tx *sql.Tx, before, customer model.Customer) (model.Customer, error) {
+	updatedAt := now()
+	result, err := tx.StmtContext(ctx, repo.updateStmt).ExecContext(ctx, customer.Name, customer.City, customer.Email, updatedAt,
+		customer.Id, customer.Version)
+	if err != nil {
+		return model.Customer{}, translateError(err)
+	}
+	if err := repo.checkWritten(ctx, tx, result, customer.Id); err != nil {
+		return model.Customer{}, err
+	}
+	customer.Version++
+	customer.UpdatedAt = &updatedAt
+	return customer, repo.changed(ctx, tx, model.AuditUpdate, &before, &customer)
+}
+
+func (repo *SqlCustomerRepository) Patch(ctx context.Context, id, version int, patch model.CustomerPatch) (model.Customer, error) {
+	var c model.Customer
+	err := repo.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := repo.findInTx(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if version != 0 && version != before.Version {
+			return ErrVersionMismatch
+		}
+		c = before
+		applyPatch(&c, patch)
+		c, err = repo.update(ctx, tx, before, c)
+		return err
+	})
+	if err != nil {
+		return model.Customer{}, err
+	}
+	return c, nil
+}
+
+// Delete reads the customer first, for the event and the audit trail to
+// carry it.
+func (repo *SqlCustomerRepository) Delete(ctx context.Context, id, version int) error {
+	return repo.inTx(ctx, func(tx *sql.Tx) error {
+		c, err := repo.findInTx(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if version != 0 && version != c.Version {
+			return ErrVersionMismatch
+		}
+		deletedAt := now()
+		result, err := tx.StmtContext(ctx, repo.deleteStmt).ExecContext(ctx, deletedAt, deletedAt, id, c.Version)
+		if err != nil {
+			return translateError(err)
+		}
+		if err := repo.checkWritten(ctx, tx, result, id); err != nil {
+			return err
+		}
+		deleted := c
+		deleted.Version++
+		deleted.UpdatedAt, deleted.DeletedAt = &deletedAt, &deletedAt
+		return repo.changed(ctx, tx, model.AuditDelete, &c, &deleted)
+	})
+}
+
+// Restore leaves a customer that is not deleted as it is.
+func (repo *SqlCustomerRepository) Restore(ctx context.Context, id int) (model.Customer, error) {
+	var c model.Customer
+	err := repo.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := findCustomer(tx.StmtContext(ctx, repo.findAnyStmt).QueryRowContext(ctx, id))
+		if err != nil || before.DeletedAt == nil {
+			c = before
+			return err
+		}
+		updatedAt := now()
+		result, err := tx.StmtContext(ctx, repo.restoreStmt).ExecContext(ctx, updatedAt, id, before.Version)
+		if err != nil {
+			return translateError(err)
+		}
+		if err := checkAffected(result); err == ErrNotFound {
+			// restored or purged in between
+			return ErrVersionMismatch
+		} else if err != nil {
+			return err
+		}
+		c = before
+		c.Version++
+		c.UpdatedAt, c.DeletedAt = &updatedAt, nil
+		return repo.changed(ctx, tx, model.AuditRestore, &before, &c)
+	})
+	if err != nil {
+		return model.Customer{}, err
+	}
+	return c, nil
+}
+
+// purgeBatch is how many customers Purge removes per transaction.
+const purgeBatch = 100
 
+// Purge removes the customers in batches, each in a transaction of its
+// own, so that a large purge does not hold locks for long.
+func (repo *SqlCustomerRepository) Purge(ctx context.Context, deletedBefore time.Time) (int, error) {
+	purged := 0
+	for {
+		var found, deleted int
+		err := repo.inTx(ctx, func(tx *sql.Tx) error {
+			customers, err := purgeable(ctx, tx, deletedBefore)
+			if err != nil {
+				return err
+			}
+			for _, c := range customers {
+				result, err := tx.ExecContext(ctx, "DELETE FROM CUSTOMERS WHERE ID=? AND VERSION=?", c.Id, c.Version)
+				if err != nil {
+					return translateError(err)
+				}
+				if err := checkAffected(result); err == ErrNotFound {
+					continue // restored in between
+				} else if err != nil {
+					return err
+				}
+				if err := repo.changed(ctx, tx, model.AuditPurge, &c, nil); err != nil {
+					return err
+				}
+				deleted++
+			}
+			found = len(customers)
+			return nil
+		})
+		if err != nil {
+			return purged, err
+		}
+		purged += deleted
+		if found < purgeBatch {
+			return purged, nil
+		}
+	}
+}
+
+// purgeable returns the next batch of customers deleted before
+// deletedBefore.
+func purgeable(ctx context.Context, tx *sql.Tx, deletedBefore time.Time) ([]model.Customer, error) {
+	rows, err := tx.QueryContext(ctx, "select "+customerColumns+" from CUSTOMERS "+
+		"where DELETED_AT IS NOT NULL AND DELETED_AT<? order by ID limit ?", deletedBefore.UTC().Truncate(time.Second), purgeBatch)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	customers := []model.Customer{}
 	for rows.Next() {
-		var c model.Customer
-		err := rows.Scan(&c.Id, &c.Name, &c.City, &c.Email)
-		utils.CheckForError(err)
+		c, err := scanCustomer(rows)
+		if err != nil {
+			return nil, translateError(err)
+		}
 		customers = append(customers, c)
 	}
+	return customers, translateError(rows.Err())
+}
 
-	return customers
+// inTx runs fn in a transaction, which is committed when fn returns nil.
+// The errors of fn are returned as they are, so fn translates them.
+func (repo *SqlCustomerRepository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return translateError(err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return translateError(tx.Commit())
+}
 
+// changed records a write in the outbox and the audit trail, within the
+// transaction making it; before is nil for an insert and after for a
+// purge.
+func (repo *SqlCustomerRepository) changed(ctx context.Context, tx *sql.Tx, operation string, before, after *model.Customer) error {
+	if eventType, customer, ok := eventFor(operation, before, after); ok {
+		if err := repo.addEvent(ctx, tx, eventType, customer); err != nil {
+			return err
+		}
+	}
+	return repo.addAudit(ctx, tx, auditEntry(ctx, operation, before, after))
 }
 
-func GetAllCustomersFromCity(city string) []model.Customer {
+// addEvent adds the change to customer to the outbox, within the
+// transaction making it.
+func (repo *SqlCustomerRepository) addEvent(ctx context.Context, tx *sql.Tx, eventType string, customer model.Customer) error {
+	payload, err := json.Marshal(customer)
+	if err != nil {
+		return err
+	}
+	_, err = tx.StmtContext(ctx, repo.insertEventStmt).ExecContext(ctx, eventType, customer.Id, string(payload), now())
+	return translateError(err)
+}
+
+// addAudit appends entry to the audit trail, within the transaction
+// making the write.
+func (repo *SqlCustomerRepository) addAudit(ctx context.Context, tx *sql.Tx, entry model.AuditEntry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+	_, err = tx.StmtContext(ctx, repo.insertAuditStmt).ExecContext(ctx, entry.CustomerId, entry.Operation, entry.Actor,
+		entry.RequestId, string(changes), entry.At)
+	return translateError(err)
+}
+
+// checkAffected returns ErrNotFound when a write matched no row.
+func checkAffected(result sql.Result) error {
+	count, err := result.RowsAffected()
+	if err != nil {
+		return translateError(err)
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
 
-	db := connect()
-	stmt, err := db.Prepare("select ID, NAME, CITY, EMAIL from CUSTOMERS where CITY=?")
-	utils.CheckForError(err)
+// checkWritten tells why a conditional write to the customer with id
+// matched no row: ErrNotFound when it is gone, ErrVersionMismatch when it
+// has changed.
+func (repo *SqlCustomerRepository) checkWritten(ctx context.Context, tx *sql.Tx, result sql.Result, id int) error {
+	if err := checkAffected(result); err != ErrNotFound {
+		return err
+	}
+	if _, err := repo.findInTx(ctx, tx, id); err != nil {
+		return err
+	}
+	return ErrVersionMismatch
+}
 
-	rows, err := stmt.Query(city)
-	utils.CheckForError(err)
+// sortColumns maps the accepted values of CustomerQuery.Sort to columns;
+// anything else is rejected before it can reach the SQL text.
+var sortColumns = map[string]string{
+	"id":    "ID",
+	"name":  "NAME",
+	"city":  "CITY",
+	"email": "EMAIL",
+}
 
-	customers := []model.Customer{}
+func (repo *SqlCustomerRepository) FindAll(ctx context.Context, q model.CustomerQuery) ([]model.Customer, int, error) {
+	where := " where DELETED_AT IS NULL"
+	args := []any{}
+	if q.City != "" {
+		where += " and CITY=?"
+		args = append(args, q.City)
+	}
 
+	countStmt, err := repo.listStmt("select count(*) from CUSTOMERS" + where)
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int
+	if err := countStmt.QueryRowContext(ctx, args...).Scan(&total); err != nil {
+		return nil, 0, translateError(err)
+	}
+
+	column, ok := sortColumns[q.Sort]
+	if !ok {
+		column = "ID"
+	}
+	direction := "asc"
+	if q.Order == "desc" {
+		direction = "desc"
+	}
+
+	if q.After > 0 {
+		comparison := ">"
+		if direction == "desc" {
+			comparison = "<"
+		}
+		where += " and ID" + comparison + "?"
+		args = append(args, q.After)
+	}
+
+	query := "select " + customerColumns + " from CUSTOMERS" + where +
+		" order by " + column + " " + direction
+	if column != "ID" {
+		// keeps the order stable between pages when sort values repeat
+		query += ", ID " + direction
+	}
+	query += " limit ?"
+	args = append(args, q.Limit)
+	if q.After == 0 {
+		query += " offset ?"
+		args = append(args, q.Offset)
+	}
+
+	listStmt, err := repo.listStmt(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := listStmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+	defer rows.Close()
+
+	customers := []model.Customer{}
 	for rows.Next() {
-		var c model.Customer
-		err := rows.Scan(&c.Id, &c.Name, &c.City, &c.Email)
-		utils.CheckForError(err)
+		c, err := scanCustomer(rows)
+		if err != nil {
+			return nil, 0, translateError(err)
+		}
 		customers = append(customers, c)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, translateError(err)
+	}
+
+	return customers, total, nil
+}
+
+// listStmt returns the cached prepared statement for query, preparing it
+// on first use.
+func (repo *SqlCustomerRepository) listStmt(query string) (*sql.Stmt, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if stmt, ok := repo.listStmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := repo.db.Prepare(query)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	repo.listStmts[query] = stmt
+	return stmt, nil
+}
+
+func applyPatch(c *model.Customer, patch model.CustomerPatch) {
+	if patch.Name != nil {
+		c.Name = *patch.Name
+	}
+	if patch.City != nil {
+		c.City = *patch.City
+	}
+	if patch.Email != nil {
+		c.Email = *patch.Email
+	}
+}
+
+// Search scores every customer with the weights of searchFields using
+// LIKE, so that substrings match on any backend. With the FULLTEXT index
+// of MySQL, customers matching any word of the query by prefix match too,
+// with those words highlighted, and the full-text relevance is added to
+// the score.
+func (repo *SqlCustomerRepository) Search(ctx context.Context, q model.SearchQuery) ([]model.SearchResult, error) {
+	term := strings.ToLower(strings.TrimSpace(q.Q))
+	prefix, contains := likePattern("", term, "%"), likePattern("%", term, "%")
+
+	scores, matches := []string{}, []string{}
+	scoreArgs, matchArgs := []any{}, []any{}
+	for _, f := range searchFields {
+		column := repo.lower + "(" + f.column + ")"
+		scores = append(scores, fmt.Sprintf(
+			"%d*(CASE WHEN %s = ? THEN %d WHEN %s LIKE ? ESCAPE '!' THEN %d WHEN %s LIKE ? ESCAPE '!' THEN %d ELSE 0 END)",
+			f.weight, column, exactMatch, column, prefixMatch, column, substringMatch))
+		scoreArgs = append(scoreArgs, term, prefix, contains)
+		matches = append(matches, column+" LIKE ? ESCAPE '!'")
+		matchArgs = append(matchArgs, contains)
+	}
+	terms := []string{term}
+	if words := fullTextWords(term); repo.fullText && len(words) > 0 {
+		const match = "MATCH(NAME, EMAIL, CITY) AGAINST (? IN BOOLEAN MODE)"
+		query := strings.Join(words, "* ") + "*"
+		scores = append(scores, match)
+		scoreArgs = append(scoreArgs, query)
+		matches = append(matches, match)
+		matchArgs = append(matchArgs, query)
+		terms = append(terms, words...)
+	}
 
-	return customers
+	query := "select " + customerColumns + ", " + strings.Join(scores, " + ") + " as SCORE from CUSTOMERS" +
+		" where DELETED_AT IS NULL and (" + strings.Join(matches, " or ") + ") order by SCORE desc, ID limit ?"
+	stmt, err := repo.listStmt(query)
+	if err != nil {
+		return nil, err
+	}
+	args := append(append(scoreArgs, matchArgs...), q.Limit)
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	results := []model.SearchResult{}
+	for rows.Next() {
+		var score float64
+		c, err := scanCustomer(rows, &score)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		results = append(results, searchResult(c, terms, score))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+	return results, nil
+}
 
+// fullTextWords splits term into the words of a boolean mode query,
+// dropping the characters that are operators there.
+func fullTextWords(term string) []string {
+	return strings.FieldsFunc(term, func(r rune) bool {
+		return strings.ContainsRune(` +-<>()~*"@`, r)
+	})
 }
@@ -0,0 +1,213 @@
+package dao
+
+import (
+	"api/migrations"
+	"api/model"
+	"appconfig"
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// newSqliteDb returns a migrated, in-memory SQLite database.
+func newSqliteDb(t *testing.T) *sql.DB {
+	db, err := OpenDb(appconfig.DB{Driver: "sqlite", Database: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// newSqliteRepository returns a SqlCustomerRepository over newSqliteDb.
+func newSqliteRepository(t *testing.T) *SqlCustomerRepository {
+	repo, err := NewSqlCustomerRepository(newSqliteDb(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+func TestSqlCustomerRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := newSqliteRepository(t)
+
+	for _, c := range []model.Customer{
+		{Name: "Vinod", City: "Bangalore", Email: "vinod@vinod.co"},
+		{Name: "Shyam", City: "Chennai", Email: "shyam@xmpl.com"},
+		{Name: "Anil", City: "Bangalore", Email: "anil@xmpl.com"},
+	} {
+		if _, err := repo.Save(ctx, c); err != nil {
+			t.Fatalf("was not expecting an error, got %v", err)
+		}
+	}
+
+	t.Run("find by id", func(t *testing.T) {
+		c, err := repo.FindById(ctx, 2)
+		if err != nil || c.Name != "Shyam" {
+			t.Errorf("wanted `Shyam`, got %v (%v)", c, err)
+		}
+		if _, err := repo.FindById(ctx, 99); err != ErrNotFound {
+			t.Errorf("wanted %v, got %v", ErrNotFound, err)
+		}
+	})
+
+	t.Run("find all from a city", func(t *testing.T) {
+		customers, total, err := repo.FindAll(ctx, model.CustomerQuery{City: "Bangalore", Sort: "name", Limit: 1})
+		if err != nil {
+			t.Fatalf("was not expecting an error, got %v", err)
+		}
+		if total != 2 || len(customers) != 1 || customers[0].Name != "Anil" {
+			t.Errorf("wanted 1 of 2 customers starting with `Anil`, got %v of %v", customers, total)
+		}
+	})
+
+	t.Run("find all after cursor", func(t *testing.T) {
+		customers, _, err := repo.FindAll(ctx, model.CustomerQuery{After: 1, Limit: 10})
+		if err != nil {
+			t.Fatalf("was not expecting an error, got %v", err)
+		}
+		if len(customers) != 2 || customers[0].Id != 2 {
+			t.Errorf("wanted customers 2 and 3, got %v", customers)
+		}
+	})
+
+	t.Run("duplicate email", func(t *testing.T) {
+		_, err := repo.Save(ctx, model.Customer{Name: "Vinod K", Email: "vinod@vinod.co"})
+		if err != ErrDuplicateEmail {
+			t.Errorf("wanted %v, got %v", ErrDuplicateEmail, err)
+		}
+	})
+
+	t.Run("versions", func(t *testing.T) {
+		c, err := repo.Update(ctx, model.Customer{Id: 2, Name: "Shyam", City: "Mysore", Email: "shyam@xmpl.com", Version: 1})
+		if err != nil || c.Version != 2 || c.UpdatedAt == nil {
+			t.Fatalf("wanted version 2, got %v (%v)", c, err)
+		}
+		if stored, _ := repo.FindById(ctx, 2); stored.Version != 2 || stored.City != "Mysore" {
+			t.Errorf("wanted Mysore at version 2, got %v", stored)
+		}
+		if _, err := repo.Update(ctx, model.Customer{Id: 2, Name: "Shyam", Version: 1}); err != ErrVersionMismatch {
+			t.Errorf("wanted %v, got %v", ErrVersionMismatch, err)
+		}
+		if err := repo.Delete(ctx, 2, 1); err != ErrVersionMismatch {
+			t.Errorf("wanted %v, got %v", ErrVersionMismatch, err)
+		}
+		if _, err := repo.Update(ctx, model.Customer{Id: 99, Name: "Nobody", Version: 1}); err != ErrNotFound {
+			t.Errorf("wanted %v, got %v", ErrNotFound, err)
+		}
+	})
+
+	t.Run("patch and delete", func(t *testing.T) {
+		city := "Mysore"
+		c, err := repo.Patch(ctx, 3, 0, model.CustomerPatch{City: &city})
+		if err != nil || c.City != "Mysore" || c.Name != "Anil" || c.Version != 2 {
+			t.Errorf("wanted only city patched, got %v (%v)", c, err)
+		}
+		if _, err := repo.Patch(ctx, 3, 1, model.CustomerPatch{City: &city}); err != ErrVersionMismatch {
+			t.Errorf("wanted %v, got %v", ErrVersionMismatch, err)
+		}
+		if err := repo.Delete(ctx, 3, 2); err != nil {
+			t.Errorf("was not expecting an error, got %v", err)
+		}
+		if err := repo.Delete(ctx, 3, 0); err != ErrNotFound {
+			t.Errorf("wanted %v, got %v", ErrNotFound, err)
+		}
+	})
+}
+
+func TestSoftDelete(t *testing.T) {
+	repos := []struct {
+		name string
+		repo CustomerRepository
+	}{
+		{"sql", newSqliteRepository(t)},
+		{"memory", NewMemoryCustomerRepository()},
+	}
+	for _, rt := range repos {
+		t.Run(rt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := rt.repo
+			id, _ := repo.Save(ctx, model.Customer{Name: "Vinod", City: "Bangalore", Email: "vinod@vinod.co"})
+			kept, _ := repo.Save(ctx, model.Customer{Name: "Shyam", City: "Bangalore", Email: "shyam@xmpl.com"})
+			if err := repo.Delete(ctx, id, 1); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+
+			// gone for every read and write
+			if _, err := repo.FindById(ctx, id); err != ErrNotFound {
+				t.Errorf("wanted %v, got %v", ErrNotFound, err)
+			}
+			if all, total, _ := repo.FindAll(ctx, model.CustomerQuery{City: "Bangalore", Limit: 10}); total != 1 || all[0].Id != kept {
+				t.Errorf("wanted only customer %d, got %v of %d", kept, all, total)
+			}
+			if results, _ := repo.Search(ctx, model.SearchQuery{Q: "vinod", Limit: 10}); len(results) != 0 {
+				t.Errorf("wanted no results, got %v", results)
+			}
+			exported := 0
+			repo.Export(ctx, func(model.Customer) error { exported++; return nil })
+			if exported != 1 {
+				t.Errorf("wanted 1 customer exported, got %d", exported)
+			}
+			if _, err := repo.Update(ctx, model.Customer{Id: id, Name: "Vinod", Email: "vinod@vinod.co"}); err != ErrNotFound {
+				t.Errorf("wanted %v, got %v", ErrNotFound, err)
+			}
+			if err := repo.Delete(ctx, id, 0); err != ErrNotFound {
+				t.Errorf("wanted %v, got %v", ErrNotFound, err)
+			}
+			// the email is free again, so the customer can be created anew,
+			// but then the deleted one can not come back until it is freed
+			other, err := repo.Save(ctx, model.Customer{Name: "Other", Email: "vinod@vinod.co"})
+			if err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			if _, err := repo.Restore(ctx, id); err != ErrDuplicateEmail {
+				t.Errorf("wanted %v, got %v", ErrDuplicateEmail, err)
+			}
+			if err := repo.Delete(ctx, other, 0); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+
+			c, err := repo.Restore(ctx, id)
+			if err != nil || c.DeletedAt != nil || c.Version != 3 || c.City != "Bangalore" {
+				t.Fatalf("wanted the customer back at version 3, got %+v (%v)", c, err)
+			}
+			if again, err := repo.Restore(ctx, id); err != nil || again.Version != 3 {
+				t.Errorf("wanted restoring twice to change nothing, got %+v (%v)", again, err)
+			}
+			if _, err := repo.FindById(ctx, id); err != nil {
+				t.Errorf("was not expecting an error, got %v", err)
+			}
+			if _, err := repo.Restore(ctx, 99); err != ErrNotFound {
+				t.Errorf("wanted %v, got %v", ErrNotFound, err)
+			}
+
+			repo.Delete(ctx, id, 0)
+			if purged, err := repo.Purge(ctx, time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+				t.Errorf("wanted nothing deleted an hour ago purged, got %d (%v)", purged, err)
+			}
+			if purged, err := repo.Purge(ctx, time.Now().Add(time.Hour)); err != nil || purged != 2 {
+				t.Errorf("wanted 2 customers purged, got %d (%v)", purged, err)
+			}
+			if _, err := repo.Restore(ctx, id); err != ErrNotFound {
+				t.Errorf("wanted %v, got %v", ErrNotFound, err)
+			}
+			if _, err := repo.FindById(ctx, kept); err != nil {
+				t.Errorf("was not expecting an error, got %v", err)
+			}
+			if _, err := repo.Save(ctx, model.Customer{Name: "Other", Email: "vinod@vinod.co"}); err != nil {
+				t.Errorf("was not expecting an error, got %v", err)
+			}
+		})
+	}
+}
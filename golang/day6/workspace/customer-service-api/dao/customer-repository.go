@@ -0,0 +1,75 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"time"
+)
+
+// Implementations report failures with ErrNotFound, ErrDuplicateEmail,
+// ErrVersionMismatch, *ValidationError or ErrUnavailable where they apply.
+// ctx carries the caller's deadline and request ID.
+//
+// Every write that happens adds a CustomerEvent to the outbox of the
+// repository and an AuditEntry to its audit trail, atomically with the
+// write; see CustomerEventRepository and CustomerAuditRepository.
+//
+// Writes are optimistic: a version of 0 means "whatever is stored", any
+// other version must be the one stored for the write to happen.
+//
+// Deletes are soft: a deleted customer is left out of every read and
+// write but Restore, and keeps its email, until Purge removes it.
+type CustomerRepository interface {
+	// returns one page of customers matching q, and the total number of
+	// customers matching the filter regardless of paging
+	FindAll(ctx context.Context, q model.CustomerQuery) ([]model.Customer, int, error)
+
+	// when there is no matching customer, return with ErrNotFound
+	FindById(ctx context.Context, id int) (model.Customer, error)
+
+	// after successful operation, new id generated is returned
+	Save(ctx context.Context, customer model.Customer) (int, error)
+
+	// replaces name, city and email of the customer with customer.Id and
+	// returns it with its new version; ErrNotFound when there is no such
+	// customer, ErrVersionMismatch when customer.Version is not 0 and not
+	// the stored version
+	Update(ctx context.Context, customer model.Customer) (model.Customer, error)
+
+	// applies the non-nil fields of patch and returns the updated customer;
+	// ErrNotFound and ErrVersionMismatch as for Update
+	Patch(ctx context.Context, id, version int, patch model.CustomerPatch) (model.Customer, error)
+
+	// ErrNotFound and ErrVersionMismatch as for Update
+	Delete(ctx context.Context, id, version int) error
+
+	// undoes the deletion of the customer with id and returns it with its
+	// new version; ErrNotFound when there is no such customer, deleted or
+	// not
+	Restore(ctx context.Context, id int) (model.Customer, error)
+
+	// removes for good the customers deleted before deletedBefore and
+	// returns how many there were
+	Purge(ctx context.Context, deletedBefore time.Time) (int, error)
+
+	// returns up to q.Limit customers matching q.Q, the most relevant
+	// first
+	Search(ctx context.Context, q model.SearchQuery) ([]model.SearchResult, error)
+
+	// starts a bulk insert whose customers become visible together on
+	// Commit
+	BeginImport(ctx context.Context) (CustomerImport, error)
+
+	// calls fn with every customer in id order, without holding them all
+	// in memory; stops at, and returns, the first error of fn
+	Export(ctx context.Context, fn func(model.Customer) error) error
+}
+
+// CustomerImport is a bulk insert in progress. A failing Save only fails
+// that customer; the others can still be committed. Rollback after Commit
+// does nothing, so it can always be deferred.
+type CustomerImport interface {
+	Save(ctx context.Context, customer model.Customer) error
+	Commit() error
+	Rollback() error
+}
@@ -1,53 +1,58 @@
 package dao
 
 import (
-	"api/utils"
+	"appconfig"
 	"database/sql"
-	"encoding/json"
-	"fmt"
-	"os"
+	"database/sql/driver"
+	"strings"
 
+	"github.com/glebarez/go-sqlite"
 	_ "github.com/go-sql-driver/mysql"
 )
 
-type Config struct {
-	Driver   string `json:"driver"`
-	Hostname string `json:"hostname"`
-	Port     string `json:"port"`
-	User     string `json:"user"`
-	Password string `json:"password"`
-	Database string `json:"database"`
+// sqliteLower is LOWER for SQLite, whose own only lowers ASCII letters,
+// while search lowers the query with strings.ToLower.
+const sqliteLower = "GO_LOWER"
+
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction(sqliteLower, 1,
+		func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			switch v := args[0].(type) {
+			case string:
+				return strings.ToLower(v), nil
+			case []byte:
+				return strings.ToLower(string(v)), nil
+			}
+			return args[0], nil
+		})
 }
 
-func connect() *sql.DB {
-	file, err := os.Open("config.json")
-	utils.CheckForError(err)
-
-	var config Config
-	json.NewDecoder(file).Decode(&config)
-
-	keys := []string{"DB_HOST", "DB_USER", "DB_PASSWORD", "DB_PORT"}
-	for _, key := range keys {
-		val := os.Getenv(key)
-		if val != "" {
-			switch key {
-			case "DB_HOST":
-				config.Hostname = val
-			case "DB_USER":
-				config.User = val
-			case "DB_PASSWORD":
-				config.Password = val
-			case "DB_PORT":
-				config.Port = val
-			}
-		}
+// OpenDb creates the connection pool shared by the whole service. It is
+// meant to be called once at startup and closed on exit.
+func OpenDb(config appconfig.DB) (*sql.DB, error) {
+	db, err := sql.Open(config.Driver, config.DSN())
+	if err != nil {
+		return nil, err
 	}
 
-	connStr := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s",
-		config.User, config.Password, config.Hostname, config.Port, config.Database)
+	if config.InMemory() {
+		// every connection would get its own empty database, and closing
+		// the last one throws the data away
+		db.SetMaxOpenConns(1)
+		db.SetMaxIdleConns(1)
+		db.SetConnMaxLifetime(0)
+		return db, nil
+	}
 
-	db, err := sql.Open("mysql", connStr)
-	utils.CheckForError(err)
+	if config.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	if config.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
 
-	return db
+	return db, nil
 }
@@ -0,0 +1,103 @@
+package dao
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/glebarez/go-sqlite"
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrNotFound       = errors.New("customer not found")
+	ErrDuplicateEmail = errors.New("a customer with this email already exists")
+	ErrUnavailable    = errors.New("customer store is unavailable")
+
+	// ErrVersionMismatch means the customer was changed after the version
+	// given to a write was read.
+	ErrVersionMismatch = errors.New("the customer was changed by someone else")
+)
+
+// ValidationError is returned when the store rejects a customer's values,
+// e.g. a NULL name or a value too long for its column.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// MySQL server error numbers and SQLite extended result codes translated
+// by translateError
+const (
+	mysqlDuplicateEntry = 1062
+	mysqlBadNull        = 1048
+	mysqlDataTooLong    = 1406
+
+	sqliteBusy                 = 5
+	sqliteCantOpen             = 14
+	sqliteConstraintNotNull    = 1299
+	sqliteConstraintPrimaryKey = 1555
+	sqliteConstraintUnique     = 2067
+)
+
+// isUniqueViolation reports whether err is a duplicate value in a UNIQUE
+// or PRIMARY KEY column.
+func isUniqueViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlDuplicateEntry
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqliteConstraintUnique || sqliteErr.Code() == sqliteConstraintPrimaryKey
+	}
+	return false
+}
+
+// translateError turns a database/sql, MySQL or SQLite driver error into
+// one of the errors above, so callers never have to look at driver
+// specifics. Errors it does not recognise are wrapped and returned as-is.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case mysqlDuplicateEntry:
+			return ErrDuplicateEmail
+		case mysqlBadNull, mysqlDataTooLong:
+			return &ValidationError{Message: mysqlErr.Message}
+		}
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqliteConstraintUnique:
+			return ErrDuplicateEmail
+		case sqliteConstraintNotNull:
+			return &ValidationError{Message: sqliteErr.Error()}
+		case sqliteBusy, sqliteCantOpen:
+			return fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	return fmt.Errorf("customer store: %w", err)
+}
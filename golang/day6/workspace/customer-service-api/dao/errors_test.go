@@ -0,0 +1,41 @@
+package dao
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestTranslateError(t *testing.T) {
+	t.Run("duplicate entry", func(t *testing.T) {
+		err := translateError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+		if err != ErrDuplicateEmail {
+			t.Errorf("wanted %v, got %v", ErrDuplicateEmail, err)
+		}
+	})
+
+	t.Run("data too long", func(t *testing.T) {
+		err := translateError(&mysql.MySQLError{Number: 1406, Message: "Data too long for column 'NAME'"})
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("was expecting a *ValidationError, got %v", err)
+		}
+	})
+
+	t.Run("bad connection", func(t *testing.T) {
+		err := translateError(driver.ErrBadConn)
+		if !errors.Is(err, ErrUnavailable) {
+			t.Errorf("wanted %v, got %v", ErrUnavailable, err)
+		}
+	})
+
+	t.Run("anything else", func(t *testing.T) {
+		cause := errors.New("boom")
+		err := translateError(cause)
+		if !errors.Is(err, cause) || errors.Is(err, ErrUnavailable) {
+			t.Errorf("was expecting the original error wrapped, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+// SqlCustomerEventRepository reads the CUSTOMER_EVENTS outbox that
+// SqlCustomerRepository writes, and keeps the offsets of the sinks in
+// OUTBOX_OFFSETS.
+type SqlCustomerEventRepository struct {
+	db *sql.DB
+}
+
+func NewSqlCustomerEventRepository(db *sql.DB) *SqlCustomerEventRepository {
+	return &SqlCustomerEventRepository{db: db}
+}
+
+func (repo *SqlCustomerEventRepository) EventsAfter(ctx context.Context, after int64, limit int) ([]model.CustomerEvent, error) {
+	rows, err := repo.db.QueryContext(ctx,
+		"select ID, TYPE, CUSTOMER_ID, PAYLOAD, CREATED_AT from CUSTOMER_EVENTS where ID>? order by ID limit ?",
+		after, limit)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	events := []model.CustomerEvent{}
+	for rows.Next() {
+		var e model.CustomerEvent
+		var payload string
+		if err := rows.Scan(&e.Id, &e.Type, &e.CustomerId, &payload, &e.OccurredAt); err != nil {
+			return nil, translateError(err)
+		}
+		if err := json.Unmarshal([]byte(payload), &e.Customer); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+	return events, nil
+}
+
+func (repo *SqlCustomerEventRepository) Offset(ctx context.Context, sink string) (int64, error) {
+	var offset int64
+	err := repo.db.QueryRowContext(ctx, "select EVENT_ID from OUTBOX_OFFSETS where SINK=?", sink).Scan(&offset)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return offset, translateError(err)
+}
+
+// SetOffset updates the row of sink, adding it the first time; should
+// another relay add it in between, the update is tried again.
+func (repo *SqlCustomerEventRepository) SetOffset(ctx context.Context, sink string, offset int64) error {
+	updated, err := repo.updateOffset(ctx, sink, offset)
+	if err != nil || updated {
+		return err
+	}
+	_, err = repo.db.ExecContext(ctx,
+		"INSERT INTO OUTBOX_OFFSETS(SINK, EVENT_ID, UPDATED_AT) VALUES(?, ?, ?)", sink, offset, now())
+	if isUniqueViolation(err) {
+		_, err = repo.updateOffset(ctx, sink, offset)
+		return err
+	}
+	return translateError(err)
+}
+
+func (repo *SqlCustomerEventRepository) updateOffset(ctx context.Context, sink string, offset int64) (bool, error) {
+	result, err := repo.db.ExecContext(ctx,
+		"UPDATE OUTBOX_OFFSETS SET EVENT_ID=?, UPDATED_AT=? WHERE SINK=?", offset, now(), sink)
+	if err != nil {
+		return false, translateError(err)
+	}
+	err = checkAffected(result)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
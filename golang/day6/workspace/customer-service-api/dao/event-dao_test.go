@@ -0,0 +1,109 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"testing"
+)
+
+func TestCustomerEvents(t *testing.T) {
+	sqlRepo := newSqliteRepository(t)
+	memoryRepo := NewMemoryCustomerRepository()
+	repos := []struct {
+		name   string
+		repo   CustomerRepository
+		events CustomerEventRepository
+	}{
+		{"sql", sqlRepo, NewSqlCustomerEventRepository(sqlRepo.db)},
+		{"memory", memoryRepo, memoryRepo.Events()},
+	}
+	for _, rt := range repos {
+		t.Run(rt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo, events := rt.repo, rt.events
+
+			id, err := repo.Save(ctx, model.Customer{Name: "Vinod", City: "Bangalore", Email: "vinod@vinod.co"})
+			if err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			if _, err := repo.Update(ctx, model.Customer{Id: id, Name: "Vinod", City: "Mysore", Email: "vinod@vinod.co"}); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			city := "Hassan"
+			if _, err := repo.Patch(ctx, id, 2, model.CustomerPatch{City: &city}); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			// failed writes add nothing
+			if _, err := repo.Patch(ctx, id, 1, model.CustomerPatch{City: &city}); err != ErrVersionMismatch {
+				t.Errorf("wanted %v, got %v", ErrVersionMismatch, err)
+			}
+			if _, err := repo.Save(ctx, model.Customer{Name: "Dup", Email: "vinod@vinod.co"}); err != ErrDuplicateEmail {
+				t.Errorf("wanted %v, got %v", ErrDuplicateEmail, err)
+			}
+			if err := repo.Delete(ctx, id, 0); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+
+			imp, _ := repo.BeginImport(ctx)
+			imp.Save(ctx, model.Customer{Name: "Ravi", Email: "ravi@xmpl.com"})
+			if err := imp.Commit(); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			imp, _ = repo.BeginImport(ctx)
+			imp.Save(ctx, model.Customer{Name: "Shyam", Email: "shyam@xmpl.com"})
+			imp.Rollback()
+
+			all, err := events.EventsAfter(ctx, 0, 10)
+			if err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			wanted := []struct {
+				eventType string
+				city      string
+				version   int
+			}{
+				{model.CustomerCreated, "Bangalore", 1},
+				{model.CustomerUpdated, "Mysore", 2},
+				{model.CustomerUpdated, "Hassan", 3},
+				{model.CustomerDeleted, "Hassan", 3},
+				{model.CustomerCreated, "", 1},
+			}
+			if len(all) != len(wanted) {
+				t.Fatalf("wanted %d events, got %v", len(wanted), all)
+			}
+			for i, w := range wanted {
+				e := all[i]
+				if e.Type != w.eventType || e.Customer.City != w.city || e.Customer.Version != w.version ||
+					e.CustomerId != e.Customer.Id || e.OccurredAt.IsZero() {
+					t.Errorf("event %d: wanted %v of %q at version %d, got %+v", i, w.eventType, w.city, w.version, e)
+				}
+				if i > 0 && e.Id <= all[i-1].Id {
+					t.Errorf("wanted ids going up, got %d after %d", e.Id, all[i-1].Id)
+				}
+			}
+			if all[4].Customer.Name != "Ravi" {
+				t.Errorf("wanted the imported customer, got %+v", all[4].Customer)
+			}
+
+			page, _ := events.EventsAfter(ctx, all[1].Id, 2)
+			if len(page) != 2 || page[0].Id != all[2].Id || page[1].Id != all[3].Id {
+				t.Errorf("wanted events 3 and 4, got %v", page)
+			}
+
+			if offset, err := events.Offset(ctx, "log"); err != nil || offset != 0 {
+				t.Errorf("wanted 0, got %v (%v)", offset, err)
+			}
+			for _, offset := range []int64{all[3].Id, all[1].Id} {
+				if err := events.SetOffset(ctx, "log", offset); err != nil {
+					t.Fatalf("was not expecting an error, got %v", err)
+				}
+				if got, err := events.Offset(ctx, "log"); err != nil || got != offset {
+					t.Errorf("wanted %v, got %v (%v)", offset, got, err)
+				}
+			}
+			if offset, _ := events.Offset(ctx, "file"); offset != 0 {
+				t.Errorf("wanted 0, got %v", offset)
+			}
+		})
+	}
+}
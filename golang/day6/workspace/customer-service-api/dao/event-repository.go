@@ -0,0 +1,89 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"sort"
+	"sync"
+)
+
+// CustomerEventRepository reads the outbox of customer events, which the
+// CustomerRepository adds to with every change it makes, and keeps how far
+// each sink has got through it.
+type CustomerEventRepository interface {
+	// up to limit events with an id above after, by id
+	EventsAfter(ctx context.Context, after int64, limit int) ([]model.CustomerEvent, error)
+
+	// the id of the last event delivered to sink; 0 before the first
+	Offset(ctx context.Context, sink string) (int64, error)
+
+	// records that sink has got up to the event with id offset; setting a
+	// lower offset makes the events after it be delivered again
+	SetOffset(ctx context.Context, sink string, offset int64) error
+}
+
+// eventFor returns the event a write adds to the outbox, carrying the
+// customer after it or, for a deletion, as it was. Purges add none: the
+// customer has been gone for clients since its deletion.
+func eventFor(operation string, before, after *model.Customer) (string, model.Customer, bool) {
+	switch operation {
+	case model.AuditInsert:
+		return model.CustomerCreated, *after, true
+	case model.AuditUpdate:
+		return model.CustomerUpdated, *after, true
+	case model.AuditDelete:
+		return model.CustomerDeleted, *before, true
+	case model.AuditRestore:
+		return model.CustomerRestored, *after, true
+	}
+	return "", model.Customer{}, false
+}
+
+// MemoryCustomerEventRepository is the outbox of a MemoryCustomerRepository.
+// Events live as long as the process.
+type MemoryCustomerEventRepository struct {
+	mu      sync.RWMutex
+	events  []model.CustomerEvent
+	offsets map[string]int64
+}
+
+func NewMemoryCustomerEventRepository() *MemoryCustomerEventRepository {
+	return &MemoryCustomerEventRepository{offsets: map[string]int64{}}
+}
+
+// add records an event for customer; the repository calls it while
+// holding its own lock, so events are in the order of the changes.
+func (repo *MemoryCustomerEventRepository) add(eventType string, customer model.Customer) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.events = append(repo.events, model.CustomerEvent{
+		Id:         int64(len(repo.events) + 1),
+		Type:       eventType,
+		CustomerId: customer.Id,
+		Customer:   customer,
+		OccurredAt: now(),
+	})
+}
+
+func (repo *MemoryCustomerEventRepository) EventsAfter(ctx context.Context, after int64, limit int) ([]model.CustomerEvent, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	start := sort.Search(len(repo.events), func(i int) bool { return repo.events[i].Id > after })
+	end := min(start+limit, len(repo.events))
+	return append([]model.CustomerEvent{}, repo.events[start:end]...), nil
+}
+
+func (repo *MemoryCustomerEventRepository) Offset(ctx context.Context, sink string) (int64, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	return repo.offsets[sink], nil
+}
+
+func (repo *MemoryCustomerEventRepository) SetOffset(ctx context.Context, sink string, offset int64) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.offsets[sink] = offset
+	return nil
+}
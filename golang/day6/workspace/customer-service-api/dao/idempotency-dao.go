@@ -0,0 +1,92 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// SqlIdempotencyRepository is the IdempotencyRepository backed by the
+// IDEMPOTENCY_KEYS table. Times are stored in UTC with second precision.
+type SqlIdempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewSqlIdempotencyRepository(db *sql.DB) *SqlIdempotencyRepository {
+	return &SqlIdempotencyRepository{db: db}
+}
+
+func (repo *SqlIdempotencyRepository) Reserve(ctx context.Context, req model.IdempotentRequest) error {
+	createdAt := req.CreatedAt.UTC().Truncate(time.Second)
+	_, err := repo.db.ExecContext(ctx,
+		"DELETE FROM IDEMPOTENCY_KEYS WHERE SCOPE=? AND IDEMPOTENCY_KEY=? AND EXPIRES_AT<=?",
+		req.Scope, req.Key, createdAt)
+	if err != nil {
+		return translateError(err)
+	}
+
+	_, err = repo.db.ExecContext(ctx,
+		"INSERT INTO IDEMPOTENCY_KEYS(SCOPE, IDEMPOTENCY_KEY, FINGERPRINT, CREATED_AT, EXPIRES_AT) VALUES(?, ?, ?, ?, ?)",
+		req.Scope, req.Key, req.Fingerprint, createdAt, req.ExpiresAt.UTC().Truncate(time.Second))
+	if isUniqueViolation(err) {
+		return ErrIdempotencyKeyTaken
+	}
+	return translateError(err)
+}
+
+func (repo *SqlIdempotencyRepository) Find(ctx context.Context, scope, key string) (model.IdempotentRequest, error) {
+	req := model.IdempotentRequest{Scope: scope, Key: key}
+	var status sql.NullInt64
+	var header sql.NullString
+	err := repo.db.QueryRowContext(ctx,
+		"select FINGERPRINT, STATUS, HEADERS, BODY, CREATED_AT, EXPIRES_AT from IDEMPOTENCY_KEYS where SCOPE=? and IDEMPOTENCY_KEY=?",
+		scope, key).Scan(&req.Fingerprint, &status, &header, &req.Body, &req.CreatedAt, &req.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return model.IdempotentRequest{}, ErrIdempotencyKeyNotFound
+	}
+	if err != nil {
+		return model.IdempotentRequest{}, translateError(err)
+	}
+
+	req.Status = int(status.Int64)
+	if header.Valid {
+		if err := json.Unmarshal([]byte(header.String), &req.Header); err != nil {
+			return model.IdempotentRequest{}, translateError(err)
+		}
+	}
+	return req, nil
+}
+
+func (repo *SqlIdempotencyRepository) Complete(ctx context.Context, scope, key string, status int, header map[string]string, body []byte) error {
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	result, err := repo.db.ExecContext(ctx,
+		"UPDATE IDEMPOTENCY_KEYS SET STATUS=?, HEADERS=?, BODY=? WHERE SCOPE=? AND IDEMPOTENCY_KEY=?",
+		status, string(encoded), body, scope, key)
+	if err != nil {
+		return translateError(err)
+	}
+	err = checkAffected(result)
+	if err == ErrNotFound {
+		return ErrIdempotencyKeyNotFound
+	}
+	return err
+}
+
+func (repo *SqlIdempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	_, err := repo.db.ExecContext(ctx, "DELETE FROM IDEMPOTENCY_KEYS WHERE SCOPE=? AND IDEMPOTENCY_KEY=?", scope, key)
+	return translateError(err)
+}
+
+func (repo *SqlIdempotencyRepository) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	result, err := repo.db.ExecContext(ctx, "DELETE FROM IDEMPOTENCY_KEYS WHERE EXPIRES_AT<=?", now.UTC().Truncate(time.Second))
+	if err != nil {
+		return 0, translateError(err)
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), translateError(err)
+}
@@ -0,0 +1,79 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyRepositories(t *testing.T) {
+	repos := []struct {
+		name string
+		repo IdempotencyRepository
+	}{
+		{"sql", NewSqlIdempotencyRepository(newSqliteDb(t))},
+		{"memory", NewMemoryIdempotencyRepository()},
+	}
+	for _, rt := range repos {
+		t.Run(rt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := rt.repo
+			created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+			req := model.IdempotentRequest{
+				Scope: "key:1", Key: "k-1", Fingerprint: "f-1", CreatedAt: created, ExpiresAt: created.Add(time.Hour),
+			}
+
+			if err := repo.Reserve(ctx, req); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			if err := repo.Reserve(ctx, req); err != ErrIdempotencyKeyTaken {
+				t.Errorf("wanted %v, got %v", ErrIdempotencyKeyTaken, err)
+			}
+			// the same key of another caller is another request
+			other := req
+			other.Scope = "key:2"
+			if err := repo.Reserve(ctx, other); err != nil {
+				t.Errorf("was not expecting an error, got %v", err)
+			}
+
+			found, err := repo.Find(ctx, "key:1", "k-1")
+			if err != nil || found.Completed() || found.Fingerprint != "f-1" {
+				t.Fatalf("wanted a reserved request, got %+v (%v)", found, err)
+			}
+
+			header := map[string]string{"Content-Type": "application/json"}
+			if err := repo.Complete(ctx, "key:1", "k-1", 201, header, []byte(`{"id":4}`)); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			found, err = repo.Find(ctx, "key:1", "k-1")
+			if err != nil || found.Status != 201 || string(found.Body) != `{"id":4}` ||
+				found.Header["Content-Type"] != "application/json" || !found.ExpiresAt.Equal(req.ExpiresAt) {
+				t.Errorf("wanted the stored response, got %+v (%v)", found, err)
+			}
+
+			if err := repo.Release(ctx, "key:2", "k-1"); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			if _, err := repo.Find(ctx, "key:2", "k-1"); err != ErrIdempotencyKeyNotFound {
+				t.Errorf("wanted %v, got %v", ErrIdempotencyKeyNotFound, err)
+			}
+
+			// an expired key can be used again
+			later := req
+			later.Fingerprint = "f-2"
+			later.CreatedAt = req.ExpiresAt
+			later.ExpiresAt = req.ExpiresAt.Add(time.Hour)
+			if err := repo.Reserve(ctx, later); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			if found, _ := repo.Find(ctx, "key:1", "k-1"); found.Fingerprint != "f-2" || found.Completed() {
+				t.Errorf("wanted the new request, got %+v", found)
+			}
+
+			if deleted, err := repo.DeleteExpired(ctx, later.ExpiresAt); err != nil || deleted != 1 {
+				t.Errorf("wanted 1 deleted, got %v (%v)", deleted, err)
+			}
+		})
+	}
+}
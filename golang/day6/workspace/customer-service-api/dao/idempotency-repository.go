@@ -0,0 +1,109 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+	ErrIdempotencyKeyTaken    = errors.New("idempotency key already used")
+)
+
+// IdempotencyRepository stores requests sent with an Idempotency-Key by
+// scope and key, so that retries of them can be answered from the first
+// response.
+type IdempotencyRepository interface {
+	// stores req, without a response, unless its scope and key are taken
+	// by an unexpired request (ErrIdempotencyKeyTaken); an expired one is
+	// replaced
+	Reserve(ctx context.Context, req model.IdempotentRequest) error
+
+	// ErrIdempotencyKeyNotFound when nothing is stored for scope and key
+	Find(ctx context.Context, scope, key string) (model.IdempotentRequest, error)
+
+	// stores the response of a reserved request
+	Complete(ctx context.Context, scope, key string, status int, header map[string]string, body []byte) error
+
+	// forgets a reserved request, so that it can be retried
+	Release(ctx context.Context, scope, key string) error
+
+	// removes the requests that expired before now and returns how many
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+// MemoryIdempotencyRepository keeps idempotent requests in memory, for the
+// memory and file customer stores.
+type MemoryIdempotencyRepository struct {
+	mu       sync.Mutex
+	requests map[[2]string]model.IdempotentRequest
+}
+
+func NewMemoryIdempotencyRepository() *MemoryIdempotencyRepository {
+	return &MemoryIdempotencyRepository{requests: map[[2]string]model.IdempotentRequest{}}
+}
+
+func (repo *MemoryIdempotencyRepository) Reserve(ctx context.Context, req model.IdempotentRequest) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	id := [2]string{req.Scope, req.Key}
+	if stored, ok := repo.requests[id]; ok && req.CreatedAt.Before(stored.ExpiresAt) {
+		return ErrIdempotencyKeyTaken
+	}
+	req.Status, req.Header, req.Body = 0, nil, nil
+	repo.requests[id] = req
+	return nil
+}
+
+func (repo *MemoryIdempotencyRepository) Find(ctx context.Context, scope, key string) (model.IdempotentRequest, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	req, ok := repo.requests[[2]string{scope, key}]
+	if !ok {
+		return model.IdempotentRequest{}, ErrIdempotencyKeyNotFound
+	}
+	return req, nil
+}
+
+func (repo *MemoryIdempotencyRepository) Complete(ctx context.Context, scope, key string, status int, header map[string]string, body []byte) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	id := [2]string{scope, key}
+	req, ok := repo.requests[id]
+	if !ok {
+		return ErrIdempotencyKeyNotFound
+	}
+	req.Status, req.Header, req.Body = status, maps.Clone(header), slices.Clone(body)
+	repo.requests[id] = req
+	return nil
+}
+
+func (repo *MemoryIdempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	delete(repo.requests, [2]string{scope, key})
+	return nil
+}
+
+func (repo *MemoryIdempotencyRepository) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	deleted := 0
+	for id, req := range repo.requests {
+		if !now.Before(req.ExpiresAt) {
+			delete(repo.requests, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,188 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"database/sql"
+)
+
+// sqlImport inserts within one transaction. Both MySQL and SQLite only
+// undo the failing statement on a constraint violation, so the
+// transaction stays usable after a failed Save. The events and audit
+// entries of the import are added at Commit, keeping the time between
+// taking their ids and committing them short for the outbox relay.
+type sqlImport struct {
+	ctx   context.Context
+	repo  *SqlCustomerRepository
+	tx    *sql.Tx
+	stmt  *sql.Stmt
+	saved []model.Customer
+}
+
+func (repo *SqlCustomerRepository) BeginImport(ctx context.Context) (CustomerImport, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &sqlImport{ctx: ctx, repo: repo, tx: tx, stmt: tx.StmtContext(ctx, repo.insertStmt)}, nil
+}
+
+func (imp *sqlImport) Save(ctx context.Context, customer model.Customer) error {
+	updatedAt := now()
+	result, err := imp.stmt.ExecContext(ctx, customer.Name, customer.City, customer.Email, updatedAt)
+	if err != nil {
+		return translateError(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return translateError(err)
+	}
+	customer.Id, customer.Version, customer.UpdatedAt = int(id), 1, &updatedAt
+	imp.saved = append(imp.saved, customer)
+	return nil
+}
+
+func (imp *sqlImport) Commit() error {
+	for _, c := range imp.saved {
+		if err := imp.repo.changed(imp.ctx, imp.tx, model.AuditInsert, nil, &c); err != nil {
+			imp.tx.Rollback()
+			return err
+		}
+	}
+	return translateError(imp.tx.Commit())
+}
+
+func (imp *sqlImport) Rollback() error {
+	if err := imp.tx.Rollback(); err != nil && err != sql.ErrTxDone {
+		return translateError(err)
+	}
+	return nil
+}
+
+// exportPage is the number of customers Export reads per query.
+const exportPage = 500
+
+// Export reads the customers a page at a time, after the last id of the
+// previous page, and closes each page's rows before handing them to fn,
+// so a slow client never holds a connection or a cursor open.
+func (repo *SqlCustomerRepository) Export(ctx context.Context, fn func(model.Customer) error) error {
+	lastId := 0
+	for {
+		page, err := repo.exportPage(ctx, lastId)
+		if err != nil {
+			return err
+		}
+		for _, c := range page {
+			if err := fn(c); err != nil {
+				return err
+			}
+		}
+		if len(page) < exportPage {
+			return nil
+		}
+		lastId = page[len(page)-1].Id
+	}
+}
+
+func (repo *SqlCustomerRepository) exportPage(ctx context.Context, afterId int) ([]model.Customer, error) {
+	rows, err := repo.db.QueryContext(ctx, "select "+customerColumns+" from CUSTOMERS "+
+		"where ID > ? AND DELETED_AT IS NULL order by ID limit ?", afterId, exportPage)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	page := make([]model.Customer, 0, exportPage)
+	for rows.Next() {
+		c, err := scanCustomer(rows)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		page = append(page, c)
+	}
+	return page, translateError(rows.Err())
+}
+
+// memoryImport holds the customers back until Commit, checking emails
+// against the repository and against each other on the way in.
+type memoryImport struct {
+	ctx         context.Context
+	repo        *MemoryCustomerRepository
+	pending     []model.Customer
+	emails      map[string]bool
+	afterCommit func() error
+}
+
+func (repo *MemoryCustomerRepository) BeginImport(ctx context.Context) (CustomerImport, error) {
+	return &memoryImport{ctx: ctx, repo: repo, emails: map[string]bool{}}, nil
+}
+
+func (imp *memoryImport) Save(ctx context.Context, customer model.Customer) error {
+	imp.repo.mu.RLock()
+	taken := imp.repo.emailTaken(customer.Email, 0)
+	imp.repo.mu.RUnlock()
+	if taken || imp.emails[customer.Email] {
+		return ErrDuplicateEmail
+	}
+	imp.emails[customer.Email] = true
+	imp.pending = append(imp.pending, customer)
+	return nil
+}
+
+// Commit fails as a whole if another writer took one of the emails since
+// it was saved.
+func (imp *memoryImport) Commit() error {
+	repo := imp.repo
+	repo.mu.Lock()
+	for _, c := range imp.pending {
+		if repo.emailTaken(c.Email, 0) {
+			repo.mu.Unlock()
+			return ErrDuplicateEmail
+		}
+	}
+	for _, c := range imp.pending {
+		repo.lastId++
+		c.Id = repo.lastId
+		c = versioned(c, 1)
+		repo.customers[c.Id] = c
+		repo.changed(imp.ctx, model.AuditInsert, nil, &c)
+	}
+	repo.mu.Unlock()
+
+	imp.pending = nil
+	if imp.afterCommit != nil {
+		return imp.afterCommit()
+	}
+	return nil
+}
+
+func (imp *memoryImport) Rollback() error {
+	imp.pending = nil
+	return nil
+}
+
+// Export works on a snapshot, so fn may take its time without blocking
+// writers.
+func (repo *MemoryCustomerRepository) Export(ctx context.Context, fn func(model.Customer) error) error {
+	repo.mu.RLock()
+	customers := repo.all()
+	repo.mu.RUnlock()
+
+	for _, c := range customers {
+		if c.DeletedAt != nil {
+			continue
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BeginImport writes the file once per committed import rather than once
+// per customer.
+func (repo *JsonFileCustomerRepository) BeginImport(ctx context.Context) (CustomerImport, error) {
+	imp, _ := repo.MemoryCustomerRepository.BeginImport(ctx)
+	imp.(*memoryImport).afterCommit = repo.flush
+	return imp, nil
+}
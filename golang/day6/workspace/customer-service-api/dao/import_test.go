@@ -0,0 +1,95 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestCustomerImport(t *testing.T) {
+	jsonRepo, err := NewJsonFileCustomerRepository(filepath.Join(t.TempDir(), "customers.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos := []struct {
+		name string
+		repo CustomerRepository
+	}{
+		{"sql", newSqliteRepository(t)},
+		{"memory", NewMemoryCustomerRepository()},
+		{"json file", jsonRepo},
+	}
+	for _, rt := range repos {
+		t.Run(rt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := rt.repo
+			repo.Save(ctx, model.Customer{Name: "Vinod", City: "Bangalore", Email: "vinod@vinod.co"})
+
+			imp, err := repo.BeginImport(ctx)
+			if err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			if err := imp.Save(ctx, model.Customer{Name: "Ravi", Email: "ravi@xmpl.com"}); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			// a failed row leaves the import usable
+			for _, email := range []string{"vinod@vinod.co", "ravi@xmpl.com"} {
+				if err := imp.Save(ctx, model.Customer{Name: "Dup", Email: email}); err != ErrDuplicateEmail {
+					t.Errorf("wanted %v, got %v", ErrDuplicateEmail, err)
+				}
+			}
+			if err := imp.Save(ctx, model.Customer{Name: "Sita", Email: "sita@xmpl.com"}); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			if err := imp.Commit(); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			imp.Rollback()
+
+			rolledBack, _ := repo.BeginImport(ctx)
+			rolledBack.Save(ctx, model.Customer{Name: "Gita", Email: "gita@xmpl.com"})
+			rolledBack.Rollback()
+
+			names := []string{}
+			err = repo.Export(ctx, func(c model.Customer) error {
+				names = append(names, c.Name)
+				return nil
+			})
+			if err != nil || len(names) != 3 || names[0] != "Vinod" || names[2] != "Sita" {
+				t.Errorf("wanted Vinod, Ravi and Sita, got %v (%v)", names, err)
+			}
+		})
+	}
+}
+
+func TestSqlExportPages(t *testing.T) {
+	ctx := context.Background()
+	repo := newSqliteRepository(t)
+
+	imp, _ := repo.BeginImport(ctx)
+	for i := 1; i <= exportPage+2; i++ {
+		imp.Save(ctx, model.Customer{Name: "Customer", Email: fmt.Sprintf("c%d@xmpl.com", i)})
+	}
+	if err := imp.Commit(); err != nil {
+		t.Fatalf("was not expecting an error, got %v", err)
+	}
+	repo.Delete(ctx, exportPage+1, 0)
+
+	lastId, exported := 0, 0
+	err := repo.Export(ctx, func(c model.Customer) error {
+		if c.Id <= lastId {
+			t.Fatalf("wanted ids in order, got %d after %d", c.Id, lastId)
+		}
+		lastId = c.Id
+		exported++
+		// the test database has a single connection, so this only gets
+		// one while no page is being read
+		_, err := repo.FindById(ctx, c.Id)
+		return err
+	})
+	if err != nil || exported != exportPage+1 || lastId != exportPage+2 {
+		t.Errorf("wanted %d customers up to id %d, got %d up to %d (%v)", exportPage+1, exportPage+2, exported, lastId, err)
+	}
+}
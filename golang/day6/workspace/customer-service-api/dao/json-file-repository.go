@@ -0,0 +1,118 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// JsonFileCustomerRepository is a MemoryCustomerRepository that is loaded
+// from, and written back to, a JSON array of customers after every change.
+type JsonFileCustomerRepository struct {
+	*MemoryCustomerRepository
+	filename string
+	flushMu  sync.Mutex
+}
+
+// NewJsonFileCustomerRepository loads customers from filename; a missing
+// file is treated as an empty list and created on the first write.
+func NewJsonFileCustomerRepository(filename string) (*JsonFileCustomerRepository, error) {
+	var customers []model.Customer
+
+	content, err := os.ReadFile(filename)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if len(content) > 0 {
+		if err := json.Unmarshal(content, &customers); err != nil {
+			return nil, err
+		}
+	}
+
+	return &JsonFileCustomerRepository{
+		MemoryCustomerRepository: NewMemoryCustomerRepository(customers...),
+		filename:                 filename,
+	}, nil
+}
+
+func (repo *JsonFileCustomerRepository) Save(ctx context.Context, customer model.Customer) (int, error) {
+	id, err := repo.MemoryCustomerRepository.Save(ctx, customer)
+	if err != nil {
+		return 0, err
+	}
+	return id, repo.flush()
+}
+
+func (repo *JsonFileCustomerRepository) Update(ctx context.Context, customer model.Customer) (model.Customer, error) {
+	c, err := repo.MemoryCustomerRepository.Update(ctx, customer)
+	if err != nil {
+		return c, err
+	}
+	return c, repo.flush()
+}
+
+func (repo *JsonFileCustomerRepository) Patch(ctx context.Context, id, version int, patch model.CustomerPatch) (model.Customer, error) {
+	c, err := repo.MemoryCustomerRepository.Patch(ctx, id, version, patch)
+	if err != nil {
+		return c, err
+	}
+	return c, repo.flush()
+}
+
+func (repo *JsonFileCustomerRepository) Delete(ctx context.Context, id, version int) error {
+	if err := repo.MemoryCustomerRepository.Delete(ctx, id, version); err != nil {
+		return err
+	}
+	return repo.flush()
+}
+
+func (repo *JsonFileCustomerRepository) Restore(ctx context.Context, id int) (model.Customer, error) {
+	c, err := repo.MemoryCustomerRepository.Restore(ctx, id)
+	if err != nil {
+		return c, err
+	}
+	return c, repo.flush()
+}
+
+func (repo *JsonFileCustomerRepository) Purge(ctx context.Context, deletedBefore time.Time) (int, error) {
+	purged, err := repo.MemoryCustomerRepository.Purge(ctx, deletedBefore)
+	if err != nil || purged == 0 {
+		return purged, err
+	}
+	return purged, repo.flush()
+}
+
+// flush writes all customers to a temporary file and renames it over the
+// original, so a crash never leaves a half-written file behind.
+func (repo *JsonFileCustomerRepository) flush() error {
+	repo.flushMu.Lock()
+	defer repo.flushMu.Unlock()
+
+	repo.mu.RLock()
+	content, err := json.MarshalIndent(repo.all(), "", "  ")
+	repo.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(repo.filename), filepath.Base(repo.filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), repo.filename)
+}
@@ -0,0 +1,76 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestJsonFileCustomerRepository(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "customers.json")
+
+	repo, err := NewJsonFileCustomerRepository(filename)
+	if err != nil {
+		t.Fatalf("was not expecting an error, got %v", err)
+	}
+	id, err := repo.Save(ctx, model.Customer{Name: "Vinod", City: "Bangalore", Email: "vinod@vinod.co"})
+	if err != nil {
+		t.Fatalf("was not expecting an error, got %v", err)
+	}
+
+	reloaded, err := NewJsonFileCustomerRepository(filename)
+	if err != nil {
+		t.Fatalf("was not expecting an error, got %v", err)
+	}
+	c, err := reloaded.FindById(ctx, id)
+	if err != nil {
+		t.Fatalf("was not expecting an error, got %v", err)
+	}
+	if c.Name != "Vinod" || c.Version != 1 {
+		t.Errorf("wanted `Vinod` at version 1, got %v", c)
+	}
+
+	if err := reloaded.Delete(ctx, 99, 0); err != ErrNotFound {
+		t.Errorf("wanted %v, got %v", ErrNotFound, err)
+	}
+}
+
+func TestPageOf(t *testing.T) {
+	customers := func() []model.Customer {
+		return []model.Customer{
+			{Id: 1, Name: "Vinod"}, {Id: 2, Name: "Anil"}, {Id: 3, Name: "Shyam"}, {Id: 4, Name: "Anil"},
+		}
+	}
+
+	subtests := []struct {
+		name string
+		q    model.CustomerQuery
+		want []int
+	}{
+		{"by id", model.CustomerQuery{Limit: 2}, []int{1, 2}},
+		{"by id with offset", model.CustomerQuery{Limit: 2, Offset: 3}, []int{4}},
+		{"by name", model.CustomerQuery{Sort: "name", Limit: 10}, []int{2, 4, 3, 1}},
+		{"by name desc", model.CustomerQuery{Sort: "name", Order: "desc", Limit: 10}, []int{1, 3, 4, 2}},
+		{"after cursor", model.CustomerQuery{Limit: 2, After: 2}, []int{3, 4}},
+		{"after cursor desc", model.CustomerQuery{Order: "desc", Limit: 2, After: 2}, []int{1}},
+	}
+	for _, st := range subtests {
+		t.Run(st.name, func(t *testing.T) {
+			got := []int{}
+			for _, c := range pageOf(customers(), st.q) {
+				got = append(got, c.Id)
+			}
+			if len(got) != len(st.want) {
+				t.Fatalf("wanted %v, got %v", st.want, got)
+			}
+			for i := range got {
+				if got[i] != st.want[i] {
+					t.Errorf("wanted %v, got %v", st.want, got)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryCustomerRepository keeps customers in a map; useful for running
+// the API without a database and for tests. Its changes go to the outbox
+// returned by Events and the audit trail returned by Audit. Deleted
+// customers stay in the map, with DeletedAt set, until they are purged.
+type MemoryCustomerRepository struct {
+	mu        sync.RWMutex
+	customers map[int]model.Customer
+	lastId    int
+	events    *MemoryCustomerEventRepository
+	audit     *MemoryCustomerAuditRepository
+}
+
+func NewMemoryCustomerRepository(customers ...model.Customer) *MemoryCustomerRepository {
+	repo := &MemoryCustomerRepository{
+		customers: map[int]model.Customer{},
+		events:    NewMemoryCustomerEventRepository(),
+		audit:     NewMemoryCustomerAuditRepository(),
+	}
+	for _, c := range customers {
+		if c.Version == 0 {
+			// e.g. from a JSON file written before versions existed
+			c.Version = 1
+		}
+		repo.customers[c.Id] = c
+		if c.Id > repo.lastId {
+			repo.lastId = c.Id
+		}
+	}
+	return repo
+}
+
+// Events returns the outbox of the repository. The customers it was
+// created with have no events.
+func (repo *MemoryCustomerRepository) Events() *MemoryCustomerEventRepository {
+	return repo.events
+}
+
+// Audit returns the audit trail of the repository. The customers it was
+// created with have no history.
+func (repo *MemoryCustomerRepository) Audit() *MemoryCustomerAuditRepository {
+	return repo.audit
+}
+
+// changed records a write in the outbox and the audit trail; before is
+// nil for an insert and after for a purge.
+func (repo *MemoryCustomerRepository) changed(ctx context.Context, operation string, before, after *model.Customer) {
+	if eventType, customer, ok := eventFor(operation, before, after); ok {
+		repo.events.add(eventType, customer)
+	}
+	repo.audit.add(ctx, operation, before, after)
+}
+
+func (repo *MemoryCustomerRepository) FindAll(ctx context.Context, q model.CustomerQuery) ([]model.Customer, int, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	matched := []model.Customer{}
+	for _, c := range repo.customers {
+		if c.DeletedAt == nil && (q.City == "" || c.City == q.City) {
+			matched = append(matched, c)
+		}
+	}
+	return pageOf(matched, q), len(matched), nil
+}
+
+func (repo *MemoryCustomerRepository) FindById(ctx context.Context, id int) (model.Customer, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	c, ok := repo.customers[id]
+	if !ok || c.DeletedAt != nil {
+		return model.Customer{}, ErrNotFound
+	}
+	return c, nil
+}
+
+func (repo *MemoryCustomerRepository) Save(ctx context.Context, customer model.Customer) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if repo.emailTaken(customer.Email, 0) {
+		return 0, ErrDuplicateEmail
+	}
+	repo.lastId++
+	customer.Id = repo.lastId
+	customer = versioned(customer, 1)
+	repo.customers[customer.Id] = customer
+	repo.changed(ctx, model.AuditInsert, nil, &customer)
+	return customer.Id, nil
+}
+
+// versioned stamps c with version and the time of the write.
+func versioned(c model.Customer, version int) model.Customer {
+	updatedAt := now()
+	c.Version = version
+	c.UpdatedAt = &updatedAt
+	return c
+}
+
+func (repo *MemoryCustomerRepository) Update(ctx context.Context, customer model.Customer) (model.Customer, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	current, err := repo.current(customer.Id, customer.Version)
+	if err != nil {
+		return model.Customer{}, err
+	}
+	if repo.emailTaken(customer.Email, customer.Id) {
+		return model.Customer{}, ErrDuplicateEmail
+	}
+	customer = versioned(customer, current.Version+1)
+	repo.customers[customer.Id] = customer
+	repo.changed(ctx, model.AuditUpdate, &current, &customer)
+	return customer, nil
+}
+
+func (repo *MemoryCustomerRepository) Patch(ctx context.Context, id, version int, patch model.CustomerPatch) (model.Customer, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	before, err := repo.current(id, version)
+	if err != nil {
+		return model.Customer{}, err
+	}
+	c := before
+	applyPatch(&c, patch)
+	if repo.emailTaken(c.Email, id) {
+		return model.Customer{}, ErrDuplicateEmail
+	}
+	c = versioned(c, c.Version+1)
+	repo.customers[id] = c
+	repo.changed(ctx, model.AuditUpdate, &before, &c)
+	return c, nil
+}
+
+func (repo *MemoryCustomerRepository) Delete(ctx context.Context, id, version int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	c, err := repo.current(id, version)
+	if err != nil {
+		return err
+	}
+	deleted := versioned(c, c.Version+1)
+	deleted.DeletedAt = deleted.UpdatedAt
+	repo.customers[id] = deleted
+	repo.changed(ctx, model.AuditDelete, &c, &deleted)
+	return nil
+}
+
+// Restore leaves a customer that is not deleted as it is.
+func (repo *MemoryCustomerRepository) Restore(ctx context.Context, id int) (model.Customer, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	before, ok := repo.customers[id]
+	if !ok {
+		return model.Customer{}, ErrNotFound
+	}
+	if before.DeletedAt == nil {
+		return before, nil
+	}
+	if repo.emailTaken(before.Email, id) {
+		return model.Customer{}, ErrDuplicateEmail
+	}
+	c := versioned(before, before.Version+1)
+	c.DeletedAt = nil
+	repo.customers[id] = c
+	repo.changed(ctx, model.AuditRestore, &before, &c)
+	return c, nil
+}
+
+func (repo *MemoryCustomerRepository) Purge(ctx context.Context, deletedBefore time.Time) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	purged := 0
+	for _, c := range repo.all() {
+		if c.DeletedAt != nil && c.DeletedAt.Before(deletedBefore) {
+			delete(repo.customers, c.Id)
+			repo.changed(ctx, model.AuditPurge, &c, nil)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// current returns the customer with id if version is 0 or its version;
+// deleted customers are not found.
+func (repo *MemoryCustomerRepository) current(id, version int) (model.Customer, error) {
+	c, ok := repo.customers[id]
+	if !ok || c.DeletedAt != nil {
+		return model.Customer{}, ErrNotFound
+	}
+	if version != 0 && version != c.Version {
+		return model.Customer{}, ErrVersionMismatch
+	}
+	return c, nil
+}
+
+// emailTaken mirrors the unique index on the emails of the customers that
+// are not deleted; the customer with id exceptId is not counted.
+func (repo *MemoryCustomerRepository) emailTaken(email string, exceptId int) bool {
+	for _, c := range repo.customers {
+		if c.Email == email && c.Id != exceptId && c.DeletedAt == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// all returns every customer ordered by id, the deleted ones included.
+func (repo *MemoryCustomerRepository) all() []model.Customer {
+	customers := make([]model.Customer, 0, len(repo.customers))
+	for _, c := range repo.customers {
+		customers = append(customers, c)
+	}
+	sort.Slice(customers, func(i, j int) bool { return customers[i].Id < customers[j].Id })
+	return customers
+}
+
+// pageOf sorts customers and cuts out the page described by q, the same
+// way the SQL in SqlCustomerRepository.FindAll does.
+func pageOf(customers []model.Customer, q model.CustomerQuery) []model.Customer {
+	key := func(c model.Customer) string {
+		switch q.Sort {
+		case "name":
+			return c.Name
+		case "city":
+			return c.City
+		case "email":
+			return c.Email
+		}
+		return ""
+	}
+	desc := q.Order == "desc"
+
+	sort.Slice(customers, func(i, j int) bool {
+		a, b := customers[i], customers[j]
+		if cmp := strings.Compare(key(a), key(b)); cmp != 0 {
+			return (cmp < 0) != desc
+		}
+		return (a.Id < b.Id) != desc
+	})
+
+	start := q.Offset
+	if q.After > 0 {
+		start = sort.Search(len(customers), func(i int) bool {
+			if desc {
+				return customers[i].Id < q.After
+			}
+			return customers[i].Id > q.After
+		})
+	}
+	if start > len(customers) {
+		start = len(customers)
+	}
+	end := len(customers)
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+	}
+	return customers[start:end]
+}
+
+func (repo *MemoryCustomerRepository) Search(ctx context.Context, q model.SearchQuery) ([]model.SearchResult, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	term := strings.ToLower(strings.TrimSpace(q.Q))
+	results := []model.SearchResult{}
+	for _, c := range repo.customers {
+		if c.DeletedAt != nil {
+			continue
+		}
+		if score := relevance(c, term); score > 0 {
+			results = append(results, searchResult(c, []string{term}, float64(score)))
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Id < results[j].Id
+	})
+	if len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, nil
+}
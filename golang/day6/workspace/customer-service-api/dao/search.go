@@ -0,0 +1,98 @@
+package dao
+
+import (
+	"api/model"
+	"html"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Relevance of a customer to a search term: every field scores by how
+// well it matches, weighted by the field. SqlCustomerRepository computes
+// the same score in SQL.
+var searchFields = []struct {
+	name   string
+	column string
+	weight int
+	value  func(c model.Customer) string
+}{
+	{"name", "NAME", 3, func(c model.Customer) string { return c.Name }},
+	{"email", "EMAIL", 2, func(c model.Customer) string { return c.Email }},
+	{"city", "CITY", 1, func(c model.Customer) string { return c.City }},
+}
+
+const (
+	exactMatch     = 8
+	prefixMatch    = 4
+	substringMatch = 1
+)
+
+func matchScore(value, term string) int {
+	value = strings.ToLower(value)
+	switch {
+	case value == term:
+		return exactMatch
+	case strings.HasPrefix(value, term):
+		return prefixMatch
+	case strings.Contains(value, term):
+		return substringMatch
+	}
+	return 0
+}
+
+// relevance returns the score of c for the lower-cased term, 0 when it
+// does not match at all.
+func relevance(c model.Customer, term string) int {
+	score := 0
+	for _, f := range searchFields {
+		score += f.weight * matchScore(f.value(c), term)
+	}
+	return score
+}
+
+// searchResult adds the highlights of terms to a matching customer.
+func searchResult(c model.Customer, terms []string, score float64) model.SearchResult {
+	result := model.SearchResult{Customer: c, Score: score, Highlights: map[string]string{}}
+	for _, f := range searchFields {
+		if marked, ok := highlight(f.value(c), terms...); ok {
+			result.Highlights[f.name] = marked
+		}
+	}
+	return result
+}
+
+// highlight HTML-escapes value and wraps every case-insensitive match of
+// one of terms in <mark></mark>, preferring the longest term where
+// several match. ok is false when there is no match.
+func highlight(value string, terms ...string) (marked string, ok bool) {
+	terms = slices.Clone(terms)
+	slices.SortStableFunc(terms, func(a, b string) int {
+		return utf8.RuneCountInString(b) - utf8.RuneCountInString(a)
+	})
+	runes := []rune(value)
+	var b strings.Builder
+next:
+	for i := 0; i < len(runes); {
+		for _, term := range terms {
+			n := utf8.RuneCountInString(term)
+			if n > 0 && i+n <= len(runes) && strings.EqualFold(string(runes[i:i+n]), term) {
+				b.WriteString("<mark>" + html.EscapeString(string(runes[i:i+n])) + "</mark>")
+				i += n
+				ok = true
+				continue next
+			}
+		}
+		b.WriteString(html.EscapeString(string(runes[i])))
+		i++
+	}
+	return b.String(), ok
+}
+
+// likePattern escapes the LIKE wildcards of term with '!', the escape
+// character the search statement declares; '\' would need quoting
+// differently in MySQL and SQLite.
+func likePattern(prefix, term, suffix string) string {
+	term = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(term)
+	return prefix + term + suffix
+}
@@ -0,0 +1,113 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	customers := []model.Customer{
+		{Name: "Vinod", City: "Bangalore", Email: "vinod@vinod.co"},
+		{Name: "Shyam", City: "Chennai", Email: "shyam@xmpl.com"},
+		{Name: "Anil", City: "Bangalore", Email: "anil@xmpl.com"},
+		{Name: "Banu", City: "Mysore", Email: "banu@vinod.co"},
+		{Name: "100%_Sure", City: "Pune", Email: "sure@xmpl.com"},
+		{Name: "Émile", City: "Zürich", Email: "emile@example.ch"},
+	}
+	sqlRepo := newSqliteRepository(t)
+	memoryRepo := NewMemoryCustomerRepository()
+	for _, c := range customers {
+		sqlRepo.Save(ctx, c)
+		memoryRepo.Save(ctx, c)
+	}
+
+	subtests := []struct {
+		name  string
+		q     string
+		limit int
+		want  []int
+	}{
+		// name prefix (3*4) beats the email prefix of Vinod, then email
+		// substring (2*1) of Banu
+		{"prefix before substring", "vin", 10, []int{1, 4}},
+		{"case insensitive", "BAN", 10, []int{4, 1, 3}},
+		{"exact", "anil", 10, []int{3}},
+		{"limit", "xmpl", 2, []int{2, 3}},
+		{"wildcards are literal", "%_", 10, []int{5}},
+		// lowered alike in Go and SQL, beyond ASCII
+		{"case insensitive beyond ascii", "ÉMILE", 10, []int{6}},
+		{"no match", "zzz", 10, []int{}},
+	}
+	repos := []struct {
+		name string
+		repo CustomerRepository
+	}{{"sql", sqlRepo}, {"memory", memoryRepo}}
+	for _, rt := range repos {
+		for _, st := range subtests {
+			t.Run(rt.name+" "+st.name, func(t *testing.T) {
+				results, err := rt.repo.Search(ctx, model.SearchQuery{Q: st.q, Limit: st.limit})
+				if err != nil {
+					t.Fatalf("was not expecting an error, got %v", err)
+				}
+				got := []int{}
+				for _, r := range results {
+					got = append(got, r.Id)
+				}
+				if len(got) != len(st.want) {
+					t.Fatalf("wanted %v, got %v", st.want, got)
+				}
+				for i := range got {
+					if got[i] != st.want[i] {
+						t.Fatalf("wanted %v, got %v", st.want, got)
+					}
+				}
+			})
+		}
+	}
+
+	t.Run("same scores", func(t *testing.T) {
+		fromSql, _ := sqlRepo.Search(ctx, model.SearchQuery{Q: "vin", Limit: 10})
+		fromMemory, _ := memoryRepo.Search(ctx, model.SearchQuery{Q: "vin", Limit: 10})
+		for i := range fromSql {
+			if fromSql[i].Score != fromMemory[i].Score {
+				t.Errorf("wanted equal scores, got %v and %v", fromSql[i].Score, fromMemory[i].Score)
+			}
+		}
+	})
+}
+
+func TestHighlight(t *testing.T) {
+	subtests := []struct {
+		value, term string
+		want        string
+		ok          bool
+	}{
+		{"Vinod", "vin", "<mark>Vin</mark>od", true},
+		{"banana", "an", "b<mark>an</mark><mark>an</mark>a", true},
+		{"<b>Ann</b>", "ann", "&lt;b&gt;<mark>Ann</mark>&lt;/b&gt;", true},
+		{"Zoë", "ë", "Zo<mark>ë</mark>", true},
+		{"Vinod", "xyz", "Vinod", false},
+		{"Vinod", "", "Vinod", false},
+	}
+	for _, st := range subtests {
+		got, ok := highlight(st.value, st.term)
+		if ok != st.ok || (ok && got != st.want) {
+			t.Errorf("highlight(%q, %q): wanted %q %v, got %q %v", st.value, st.term, st.want, st.ok, got, ok)
+		}
+	}
+}
+
+func TestHighlightWords(t *testing.T) {
+	// a FULLTEXT match on some words of the query
+	got, ok := highlight("Vinod Kumar", "kumar vinod", "kumar", "vinod")
+	if want := "<mark>Vinod</mark> <mark>Kumar</mark>"; !ok || got != want {
+		t.Errorf("wanted %q, got %q", want, got)
+	}
+	// the whole query wins over its words
+	got, _ = highlight("Vinod Kumar", "vinod kumar", "vinod", "kumar")
+	if want := "<mark>Vinod Kumar</mark>"; got != want {
+		t.Errorf("wanted %q, got %q", want, got)
+	}
+}
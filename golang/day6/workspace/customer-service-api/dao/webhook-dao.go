@@ -0,0 +1,287 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// SqlWebhookRepository is the WebhookRepository backed by the
+// WEBHOOK_SUBSCRIPTIONS, WEBHOOK_DELIVERIES and WEBHOOK_ATTEMPTS tables.
+// Times are stored in UTC with second precision.
+type SqlWebhookRepository struct {
+	db *sql.DB
+}
+
+func NewSqlWebhookRepository(db *sql.DB) *SqlWebhookRepository {
+	return &SqlWebhookRepository{db: db}
+}
+
+const (
+	subscriptionColumns = "ID, URL, EVENTS, SECRET, CREATED_AT"
+	deliveryColumns     = "ID, SUBSCRIPTION_ID, EVENT_ID, EVENT_TYPE, PAYLOAD, STATUS, ATTEMPTS, NEXT_ATTEMPT_AT, LAST_ERROR, CREATED_AT, UPDATED_AT"
+)
+
+func (repo *SqlWebhookRepository) CreateSubscription(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error) {
+	sub.CreatedAt = sub.CreatedAt.UTC().Truncate(time.Second)
+	result, err := repo.db.ExecContext(ctx,
+		"INSERT INTO WEBHOOK_SUBSCRIPTIONS(URL, EVENTS, SECRET, CREATED_AT) VALUES(?, ?, ?, ?)",
+		sub.URL, strings.Join(sub.Events, " "), sub.Secret, sub.CreatedAt)
+	if err != nil {
+		return model.WebhookSubscription{}, translateError(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return model.WebhookSubscription{}, translateError(err)
+	}
+	sub.Id = int(id)
+	return sub, nil
+}
+
+func (repo *SqlWebhookRepository) FindSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	rows, err := repo.db.QueryContext(ctx, "select "+subscriptionColumns+" from WEBHOOK_SUBSCRIPTIONS order by ID")
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	subs := []model.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+	return subs, nil
+}
+
+func (repo *SqlWebhookRepository) FindSubscription(ctx context.Context, id int) (model.WebhookSubscription, error) {
+	sub, err := scanSubscription(repo.db.QueryRowContext(ctx,
+		"select "+subscriptionColumns+" from WEBHOOK_SUBSCRIPTIONS where ID=?", id))
+	if err == sql.ErrNoRows {
+		return model.WebhookSubscription{}, ErrWebhookNotFound
+	}
+	if err != nil {
+		return model.WebhookSubscription{}, translateError(err)
+	}
+	return sub, nil
+}
+
+// DeleteSubscription leaves deleting the deliveries and their attempts to
+// the foreign keys.
+func (repo *SqlWebhookRepository) DeleteSubscription(ctx context.Context, id int) error {
+	result, err := repo.db.ExecContext(ctx, "DELETE FROM WEBHOOK_SUBSCRIPTIONS WHERE ID=?", id)
+	if err != nil {
+		return translateError(err)
+	}
+	err = checkAffected(result)
+	if err == ErrNotFound {
+		return ErrWebhookNotFound
+	}
+	return err
+}
+
+func (repo *SqlWebhookRepository) AddDelivery(ctx context.Context, delivery model.WebhookDelivery) error {
+	createdAt := delivery.CreatedAt.UTC().Truncate(time.Second)
+	_, err := repo.db.ExecContext(ctx,
+		"INSERT INTO WEBHOOK_DELIVERIES(SUBSCRIPTION_ID, EVENT_ID, EVENT_TYPE, PAYLOAD, STATUS, NEXT_ATTEMPT_AT, CREATED_AT, UPDATED_AT) "+
+			"VALUES(?, ?, ?, ?, ?, ?, ?, ?)",
+		delivery.SubscriptionId, delivery.EventId, delivery.EventType, string(delivery.Payload), delivery.Status,
+		nullTime(delivery.NextAttemptAt), createdAt, createdAt)
+	if isUniqueViolation(err) {
+		return nil
+	}
+	return translateError(err)
+}
+
+// ClaimDeliveries claims each due delivery with an update that only
+// matches while it is still due, so that of two instances claiming at
+// once only one gets it.
+func (repo *SqlWebhookRepository) ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]model.WebhookDelivery, error) {
+	now = now.UTC().Truncate(time.Second)
+	due, err := repo.findDeliveries(ctx,
+		" where STATUS=? and NEXT_ATTEMPT_AT<=? order by NEXT_ATTEMPT_AT, ID limit ?", model.DeliveryPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	lease := leaseUntil.UTC().Truncate(time.Second)
+	claimed := []model.WebhookDelivery{}
+	for _, d := range due {
+		result, err := repo.db.ExecContext(ctx,
+			"UPDATE WEBHOOK_DELIVERIES SET NEXT_ATTEMPT_AT=? WHERE ID=? AND STATUS=? AND NEXT_ATTEMPT_AT<=?",
+			lease, d.Id, model.DeliveryPending, now)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		if checkAffected(result) == nil {
+			claimed = append(claimed, d)
+		}
+	}
+	return claimed, nil
+}
+
+func (repo *SqlWebhookRepository) RecordAttempt(ctx context.Context, delivery model.WebhookDelivery, attempt model.WebhookAttempt) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return translateError(err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE WEBHOOK_DELIVERIES SET STATUS=?, ATTEMPTS=?, NEXT_ATTEMPT_AT=?, LAST_ERROR=?, UPDATED_AT=? WHERE ID=?",
+		delivery.Status, delivery.AttemptCount, nullTime(delivery.NextAttemptAt), nullString(delivery.LastError),
+		delivery.UpdatedAt.UTC().Truncate(time.Second), delivery.Id)
+	if err != nil {
+		return translateError(err)
+	}
+	err = checkAffected(result)
+	if err == ErrNotFound {
+		return ErrDeliveryNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var statusCode sql.NullInt64
+	if attempt.StatusCode != 0 {
+		statusCode = sql.NullInt64{Int64: int64(attempt.StatusCode), Valid: true}
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO WEBHOOK_ATTEMPTS(DELIVERY_ID, ATTEMPT, STATUS_CODE, ERROR, DURATION_MS, ATTEMPTED_AT) VALUES(?, ?, ?, ?, ?, ?)",
+		delivery.Id, attempt.Attempt, statusCode, nullString(attempt.Error), attempt.DurationMs,
+		attempt.AttemptedAt.UTC().Truncate(time.Second))
+	if err != nil {
+		return translateError(err)
+	}
+	return translateError(tx.Commit())
+}
+
+func (repo *SqlWebhookRepository) FindDeliveries(ctx context.Context, subscriptionId int, status string, limit int) ([]model.WebhookDelivery, error) {
+	where, args := " where SUBSCRIPTION_ID=?", []any{subscriptionId}
+	if status != "" {
+		where += " and STATUS=?"
+		args = append(args, status)
+	}
+	deliveries, err := repo.findDeliveries(ctx, where+" order by ID desc limit ?", append(args, limit)...)
+	if err != nil || len(deliveries) == 0 {
+		return deliveries, err
+	}
+
+	ids := make([]any, len(deliveries))
+	index := map[int64]int{}
+	for i, d := range deliveries {
+		ids[i] = d.Id
+		index[d.Id] = i
+	}
+	rows, err := repo.db.QueryContext(ctx,
+		"select DELIVERY_ID, ATTEMPT, STATUS_CODE, ERROR, DURATION_MS, ATTEMPTED_AT from WEBHOOK_ATTEMPTS where DELIVERY_ID in (?"+
+			strings.Repeat(", ?", len(ids)-1)+") order by ID", ids...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var deliveryId int64
+		var a model.WebhookAttempt
+		var statusCode sql.NullInt64
+		var attemptErr sql.NullString
+		if err := rows.Scan(&deliveryId, &a.Attempt, &statusCode, &attemptErr, &a.DurationMs, &a.AttemptedAt); err != nil {
+			return nil, translateError(err)
+		}
+		a.StatusCode, a.Error = int(statusCode.Int64), attemptErr.String
+		d := &deliveries[index[deliveryId]]
+		d.Attempts = append(d.Attempts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+	return deliveries, nil
+}
+
+func (repo *SqlWebhookRepository) Redeliver(ctx context.Context, subscriptionId int, deliveryId int64, now time.Time) (model.WebhookDelivery, error) {
+	now = now.UTC().Truncate(time.Second)
+	result, err := repo.db.ExecContext(ctx,
+		"UPDATE WEBHOOK_DELIVERIES SET STATUS=?, ATTEMPTS=0, NEXT_ATTEMPT_AT=?, UPDATED_AT=? WHERE ID=? AND SUBSCRIPTION_ID=?",
+		model.DeliveryPending, now, now, deliveryId, subscriptionId)
+	if err != nil {
+		return model.WebhookDelivery{}, translateError(err)
+	}
+	err = checkAffected(result)
+	if err == ErrNotFound {
+		return model.WebhookDelivery{}, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+
+	deliveries, err := repo.findDeliveries(ctx, " where ID=?", deliveryId)
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+	if len(deliveries) == 0 {
+		return model.WebhookDelivery{}, ErrDeliveryNotFound
+	}
+	return deliveries[0], nil
+}
+
+// findDeliveries reads the deliveries selected by the rest of the query
+// after the table name, without their attempts.
+func (repo *SqlWebhookRepository) findDeliveries(ctx context.Context, rest string, args ...any) ([]model.WebhookDelivery, error) {
+	rows, err := repo.db.QueryContext(ctx, "select "+deliveryColumns+" from WEBHOOK_DELIVERIES"+rest, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	deliveries := []model.WebhookDelivery{}
+	for rows.Next() {
+		var d model.WebhookDelivery
+		var payload string
+		var nextAttemptAt sql.NullTime
+		var lastError sql.NullString
+		err := rows.Scan(&d.Id, &d.SubscriptionId, &d.EventId, &d.EventType, &payload, &d.Status, &d.AttemptCount,
+			&nextAttemptAt, &lastError, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		d.Payload, d.LastError = []byte(payload), lastError.String
+		if nextAttemptAt.Valid {
+			d.NextAttemptAt = &nextAttemptAt.Time
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+	return deliveries, nil
+}
+
+func scanSubscription(row scanner) (model.WebhookSubscription, error) {
+	var sub model.WebhookSubscription
+	var events string
+	if err := row.Scan(&sub.Id, &sub.URL, &events, &sub.Secret, &sub.CreatedAt); err != nil {
+		return sub, err
+	}
+	sub.Events = strings.Fields(events)
+	return sub, nil
+}
+
+// nullTime stores a nil t as NULL, in UTC with second precision otherwise.
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC().Truncate(time.Second), Valid: true}
+}
+
+// nullString stores "" as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
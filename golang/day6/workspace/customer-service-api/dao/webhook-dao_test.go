@@ -0,0 +1,97 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWebhookRepositories(t *testing.T) {
+	repos := []struct {
+		name string
+		repo WebhookRepository
+	}{
+		{"sql", NewSqlWebhookRepository(newSqliteDb(t))},
+		{"memory", NewMemoryWebhookRepository()},
+	}
+	for _, rt := range repos {
+		t.Run(rt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := rt.repo
+			now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+			sub, err := repo.CreateSubscription(ctx, model.WebhookSubscription{
+				URL: "https://partner.example/hooks", Events: []string{model.CustomerCreated}, Secret: "s3cret-s3cret-s3cret", CreatedAt: now,
+			})
+			if err != nil || sub.Id == 0 {
+				t.Fatalf("wanted a new subscription, got %+v (%v)", sub, err)
+			}
+			found, err := repo.FindSubscription(ctx, sub.Id)
+			if err != nil || found.Secret != "s3cret-s3cret-s3cret" || !found.Wants(model.CustomerCreated) || found.Wants(model.CustomerDeleted) {
+				t.Errorf("wanted the subscription, got %+v (%v)", found, err)
+			}
+			if _, err := repo.FindSubscription(ctx, 99); err != ErrWebhookNotFound {
+				t.Errorf("wanted %v, got %v", ErrWebhookNotFound, err)
+			}
+
+			for _, eventId := range []int64{1, 2, 1} {
+				err := repo.AddDelivery(ctx, model.WebhookDelivery{
+					SubscriptionId: sub.Id, EventId: eventId, EventType: model.CustomerCreated, Payload: []byte(`{}`),
+					Status: model.DeliveryPending, NextAttemptAt: &now, CreatedAt: now,
+				})
+				if err != nil {
+					t.Fatalf("was not expecting an error, got %v", err)
+				}
+			}
+
+			// claimed deliveries are not due again until the lease ends
+			lease := now.Add(time.Minute)
+			claimed, err := repo.ClaimDeliveries(ctx, now, lease, 10)
+			if err != nil || len(claimed) != 2 || string(claimed[0].Payload) != `{}` {
+				t.Fatalf("wanted the two deliveries, got %+v (%v)", claimed, err)
+			}
+			if again, _ := repo.ClaimDeliveries(ctx, now, lease, 10); len(again) != 0 {
+				t.Errorf("wanted no deliveries, got %+v", again)
+			}
+
+			d := claimed[0]
+			d.Status, d.AttemptCount, d.NextAttemptAt, d.LastError, d.UpdatedAt = model.DeliveryDead, 1, nil, "500 Internal Server Error", now
+			attempt := model.WebhookAttempt{Attempt: 1, StatusCode: 500, DurationMs: 12, AttemptedAt: now}
+			if err := repo.RecordAttempt(ctx, d, attempt); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+
+			dead, err := repo.FindDeliveries(ctx, sub.Id, model.DeliveryDead, 10)
+			if err != nil || len(dead) != 1 || dead[0].Id != d.Id || len(dead[0].Attempts) != 1 ||
+				dead[0].Attempts[0].StatusCode != 500 || dead[0].LastError != d.LastError {
+				t.Fatalf("wanted the dead delivery with its attempt, got %+v (%v)", dead, err)
+			}
+			if all, _ := repo.FindDeliveries(ctx, sub.Id, "", 10); len(all) != 2 || all[0].Id <= all[1].Id {
+				t.Errorf("wanted both deliveries, newest first, got %+v", all)
+			}
+
+			later := now.Add(time.Hour)
+			redelivered, err := repo.Redeliver(ctx, sub.Id, d.Id, later)
+			if err != nil || redelivered.Status != model.DeliveryPending || redelivered.AttemptCount != 0 {
+				t.Errorf("wanted a pending delivery, got %+v (%v)", redelivered, err)
+			}
+			if claimed, _ := repo.ClaimDeliveries(ctx, later, later.Add(time.Minute), 10); len(claimed) != 2 {
+				t.Errorf("wanted both deliveries due, got %+v", claimed)
+			}
+			if _, err := repo.Redeliver(ctx, sub.Id+1, d.Id, later); err != ErrDeliveryNotFound {
+				t.Errorf("wanted %v, got %v", ErrDeliveryNotFound, err)
+			}
+
+			if err := repo.DeleteSubscription(ctx, sub.Id); err != nil {
+				t.Fatalf("was not expecting an error, got %v", err)
+			}
+			if deliveries, _ := repo.FindDeliveries(ctx, sub.Id, "", 10); len(deliveries) != 0 {
+				t.Errorf("wanted the deliveries deleted too, got %+v", deliveries)
+			}
+			if err := repo.DeleteSubscription(ctx, sub.Id); err != ErrWebhookNotFound {
+				t.Errorf("wanted %v, got %v", ErrWebhookNotFound, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package dao
+
+import (
+	"api/model"
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook subscription not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// WebhookRepository stores webhook subscriptions and the deliveries of
+// customer events to them, with the history of their attempts.
+type WebhookRepository interface {
+	// stores sub and returns it with its new id
+	CreateSubscription(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error)
+
+	// all subscriptions, by id
+	FindSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error)
+
+	// ErrWebhookNotFound when there is no such subscription
+	FindSubscription(ctx context.Context, id int) (model.WebhookSubscription, error)
+
+	// deletes the subscription with its deliveries; ErrWebhookNotFound
+	// when there is no such subscription
+	DeleteSubscription(ctx context.Context, id int) error
+
+	// stores a pending delivery, unless there already is one of the same
+	// event to the same subscription, so that events relayed again are
+	// not delivered twice
+	AddDelivery(ctx context.Context, delivery model.WebhookDelivery) error
+
+	// returns up to limit pending deliveries due at now, oldest first,
+	// after moving their next attempt to leaseUntil so that nobody else
+	// claims them meanwhile
+	ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]model.WebhookDelivery, error)
+
+	// stores the status, attempt count, next attempt and last error of
+	// delivery, and adds attempt to its history
+	RecordAttempt(ctx context.Context, delivery model.WebhookDelivery, attempt model.WebhookAttempt) error
+
+	// up to limit deliveries to the subscription, newest first, with their
+	// attempts; only those with status unless it is ""
+	FindDeliveries(ctx context.Context, subscriptionId int, status string, limit int) ([]model.WebhookDelivery, error)
+
+	// makes a delivery to the subscription pending again with no attempts,
+	// due at now; ErrDeliveryNotFound when there is no such delivery
+	Redeliver(ctx context.Context, subscriptionId int, deliveryId int64, now time.Time) (model.WebhookDelivery, error)
+}
+
+// MemoryWebhookRepository keeps webhooks in memory, for the memory and
+// file customer stores; they do not survive a restart.
+type MemoryWebhookRepository struct {
+	mu             sync.Mutex
+	subscriptions  []model.WebhookSubscription
+	lastId         int
+	deliveries     []model.WebhookDelivery
+	lastDeliveryId int64
+	attempts       map[int64][]model.WebhookAttempt
+}
+
+func NewMemoryWebhookRepository() *MemoryWebhookRepository {
+	return &MemoryWebhookRepository{attempts: map[int64][]model.WebhookAttempt{}}
+}
+
+func (repo *MemoryWebhookRepository) CreateSubscription(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.lastId++
+	sub.Id = repo.lastId
+	sub.Events = slices.Clone(sub.Events)
+	repo.subscriptions = append(repo.subscriptions, sub)
+	return sub, nil
+}
+
+func (repo *MemoryWebhookRepository) FindSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return slices.Clone(repo.subscriptions), nil
+}
+
+func (repo *MemoryWebhookRepository) FindSubscription(ctx context.Context, id int) (model.WebhookSubscription, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	i := slices.IndexFunc(repo.subscriptions, func(s model.WebhookSubscription) bool { return s.Id == id })
+	if i < 0 {
+		return model.WebhookSubscription{}, ErrWebhookNotFound
+	}
+	return repo.subscriptions[i], nil
+}
+
+func (repo *MemoryWebhookRepository) DeleteSubscription(ctx context.Context, id int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	i := slices.IndexFunc(repo.subscriptions, func(s model.WebhookSubscription) bool { return s.Id == id })
+	if i < 0 {
+		return ErrWebhookNotFound
+	}
+	repo.subscriptions = slices.Delete(repo.subscriptions, i, i+1)
+	repo.deliveries = slices.DeleteFunc(repo.deliveries, func(d model.WebhookDelivery) bool {
+		if d.SubscriptionId == id {
+			delete(repo.attempts, d.Id)
+			return true
+		}
+		return false
+	})
+	return nil
+}
+
+func (repo *MemoryWebhookRepository) AddDelivery(ctx context.Context, delivery model.WebhookDelivery) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, d := range repo.deliveries {
+		if d.SubscriptionId == delivery.SubscriptionId && d.EventId == delivery.EventId {
+			return nil
+		}
+	}
+	repo.lastDeliveryId++
+	delivery.Id = repo.lastDeliveryId
+	repo.deliveries = append(repo.deliveries, delivery)
+	return nil
+}
+
+func (repo *MemoryWebhookRepository) ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]model.WebhookDelivery, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	due := []int{}
+	for i, d := range repo.deliveries {
+		if d.Status == model.DeliveryPending && d.NextAttemptAt != nil && !d.NextAttemptAt.After(now) {
+			due = append(due, i)
+		}
+	}
+	sort.SliceStable(due, func(a, b int) bool {
+		return repo.deliveries[due[a]].NextAttemptAt.Before(*repo.deliveries[due[b]].NextAttemptAt)
+	})
+
+	claimed := []model.WebhookDelivery{}
+	for _, i := range due[:min(limit, len(due))] {
+		claimed = append(claimed, repo.deliveries[i])
+		repo.deliveries[i].NextAttemptAt = &leaseUntil
+	}
+	return claimed, nil
+}
+
+func (repo *MemoryWebhookRepository) RecordAttempt(ctx context.Context, delivery model.WebhookDelivery, attempt model.WebhookAttempt) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	i := slices.IndexFunc(repo.deliveries, func(d model.WebhookDelivery) bool { return d.Id == delivery.Id })
+	if i < 0 {
+		return ErrDeliveryNotFound
+	}
+	d := &repo.deliveries[i]
+	d.Status, d.AttemptCount, d.NextAttemptAt = delivery.Status, delivery.AttemptCount, delivery.NextAttemptAt
+	d.LastError, d.UpdatedAt = delivery.LastError, delivery.UpdatedAt
+	repo.attempts[d.Id] = append(repo.attempts[d.Id], attempt)
+	return nil
+}
+
+func (repo *MemoryWebhookRepository) FindDeliveries(ctx context.Context, subscriptionId int, status string, limit int) ([]model.WebhookDelivery, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	deliveries := []model.WebhookDelivery{}
+	for i := len(repo.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		d := repo.deliveries[i]
+		if d.SubscriptionId == subscriptionId && (status == "" || d.Status == status) {
+			d.Attempts = slices.Clone(repo.attempts[d.Id])
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, nil
+}
+
+func (repo *MemoryWebhookRepository) Redeliver(ctx context.Context, subscriptionId int, deliveryId int64, now time.Time) (model.WebhookDelivery, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	i := slices.IndexFunc(repo.deliveries, func(d model.WebhookDelivery) bool {
+		return d.Id == deliveryId && d.SubscriptionId == subscriptionId
+	})
+	if i < 0 {
+		return model.WebhookDelivery{}, ErrDeliveryNotFound
+	}
+	d := &repo.deliveries[i]
+	d.Status, d.AttemptCount, d.NextAttemptAt, d.UpdatedAt = model.DeliveryPending, 0, &now, now
+	return *d, nil
+}
@@ -0,0 +1,39 @@
+// Package logging carries the request ID through a request's context and
+// hands out loggers tagged with it.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+)
+
+type requestIdKey struct{}
+
+// WithRequestId returns a copy of ctx carrying id.
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// RequestId returns the request ID in ctx, or "" outside a request.
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// NewRequestId returns a random 128-bit ID in hex.
+func NewRequestId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Logger returns the default logger, tagged with the request ID of ctx
+// when there is one.
+func Logger(ctx context.Context) *slog.Logger {
+	if id := RequestId(ctx); id != "" {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}
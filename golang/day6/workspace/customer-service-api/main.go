@@ -2,36 +2,167 @@ package main
 
 import (
 	"api/controllers"
+	"api/metrics"
 	"api/middlewares"
+	"api/outbox"
+	"api/purge"
+	"api/settings"
+	"api/validation"
+	"api/webhooks"
+	"appconfig"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log"
+	"log/slog"
 	"net/http"
 	"os"
-
-	"github.com/gorilla/mux"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func main() {
-	r := mux.NewRouter()
-	r.Use(middlewares.LogRequestMiddleware)
-	r.Use(middlewares.ErrorHandlerMiddleware)
-	r.Use(middlewares.CorsMiddleware)
+	storeName := flag.String("store", "db", "customer store: db (the db.driver database), memory or file")
+	dataFile := flag.String("file", "customers.json", "JSON file used when -store=file")
+	migrate := flag.Bool("migrate", false, "apply pending schema migrations on startup (db store only)")
+	citiesFile := flag.String("cities", "cities.json", "JSON array of accepted cities; checked only if the file exists")
+	settings.RegisterFlags(flag.CommandLine)
+	flag.Parse()
 
-	api := r.PathPrefix("/api").Subrouter()
-	api.Use(middlewares.RejectNonJsonRequest)
-	api.Use(middlewares.JsonResponseMiddleware)
+	// access logs and everything written with the log package come out as
+	// JSON lines
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
 
-	r.HandleFunc("/", controllers.Home)
+	config, err := settings.Load(appconfig.Options{File: "config.json", Flags: flag.CommandLine})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("configuration:\n%s", config)
 
-	api.HandleFunc("/customers", controllers.HandleGetAllCustomers).Methods("GET")
-	api.HandleFunc("/customers/{id}", controllers.HandleGetOneCustomer).Methods("GET")
+	store, err := openStore(*storeName, *dataFile, config.DB, *migrate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+	validator, err := validation.LoadCustomerValidator(*citiesFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if store.db != nil {
+		metrics.RegisterDBStats(store.db)
+	}
+	health := controllers.NewHealthHandler(store)
+	if !config.Auth.Enabled {
+		log.Println("auth.enabled is false: the customer API accepts anonymous requests")
+	}
+	r, err := newRouter(config, store, validator, health)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	api.HandleFunc("/customers", controllers.HandlePostOneCustomer).Methods("POST")
+	server := &http.Server{
+		Addr:              config.Server.Addr(),
+		Handler:           middlewares.Metrics(middlewares.Cors(config.CORS)(r)),
+		ReadHeaderTimeout: config.Server.ReadHeaderTimeout,
+		ReadTimeout:       config.Server.ReadTimeout,
+		WriteTimeout:      config.Server.WriteTimeout,
+		IdleTimeout:       config.Server.IdleTimeout,
+	}
+
+	stopJobs, err := startJobs(config, store)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	port := os.Getenv("SERVER_PORT")
-	if port == "" {
-		port = "7788"
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		fmt.Printf("server running in port %v\n", config.Server.Port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		// the deferred store.Close() would not run after log.Fatal
+		store.Close()
+		log.Fatal(err)
+	case <-ctx.Done():
 	}
 
-	fmt.Printf("server running in port %v\n", port)
-	http.ListenAndServe("0.0.0.0:"+port, r)
+	// a second signal stops the server at once
+	stop()
+	log.Printf("draining for %v", config.Server.DrainPeriod)
+	health.Drain()
+	time.Sleep(config.Server.DrainPeriod)
+	log.Printf("shutting down; waiting up to %v for in-flight requests", config.Server.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Server.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+	if err := <-serverErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("server: %v", err)
+	}
+	// before the store closes; events not yet published go out on the
+	// next start
+	stopJobs()
+	log.Println("server stopped")
+}
+
+// startJobs runs an outbox relay per configured sink, plus the webhook
+// fan-out and deliveries when webhooks are enabled and the purge job when
+// it is, until the returned function is called; it returns once they have
+// stopped.
+func startJobs(config *settings.Config, store *customerStore) (func(), error) {
+	var sinks []outbox.Sink
+	if config.Outbox.Enabled {
+		var err error
+		if sinks, err = outbox.NewSinks(config.Outbox); err != nil {
+			return nil, err
+		}
+	} else {
+		log.Println("outbox.enabled is false: customer events are kept but not published")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	run := func(f func(ctx context.Context)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f(ctx)
+		}()
+	}
+	if config.Webhooks.Enabled {
+		sinks = append(sinks, webhooks.NewFanout(store.webhooks))
+		run(webhooks.NewDeliverer(store.webhooks, config.Webhooks).Run)
+	}
+	for _, sink := range sinks {
+		run(outbox.NewRelay(store.events, sink, config.Outbox).Run)
+	}
+	if config.Purge.Enabled {
+		run(purge.NewJob(metrics.ObserveRepository(store.repo), config.Purge).Run)
+	} else {
+		log.Println("purge.enabled is false: deleted customers are kept until an admin purges them")
+	}
+	return func() {
+		cancel()
+		wg.Wait()
+	}, nil
+}
+
+// relayedSinks names the sinks the outbox may be relayed to, whose offsets
+// admins can see and set.
+func relayedSinks(config *settings.Config) []string {
+	sinks := slices.Clone(config.Outbox.Sinks)
+	if config.Webhooks.Enabled {
+		sinks = append(sinks, webhooks.SinkName)
+	}
+	return sinks
 }
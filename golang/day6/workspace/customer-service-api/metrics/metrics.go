@@ -0,0 +1,327 @@
+// Package metrics keeps counters, gauges and histograms in memory and
+// serves them in the Prometheus text exposition format (version 0.0.4).
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are latency buckets in seconds, from 5ms to 10s.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds metric families in registration order.
+type Registry struct {
+	mu       sync.Mutex
+	families []family
+	names    map[string]bool
+}
+
+type family interface {
+	writeTo(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+func (r *Registry) register(name string, f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic("metrics: " + name + " registered twice")
+	}
+	r.names[name] = true
+	r.families = append(r.families, f)
+}
+
+// Handler serves every registered metric.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		r.mu.Lock()
+		families := append([]family(nil), r.families...)
+		r.mu.Unlock()
+		for _, f := range families {
+			f.writeTo(bw)
+		}
+		bw.Flush()
+	})
+}
+
+// vec holds the series of one family by their label values.
+type vec[S any] struct {
+	name, help, kind string
+	labels           []string
+	newSeries        func() *S
+
+	mu     sync.Mutex
+	series map[string]*S
+	values map[string][]string
+}
+
+func newVec[S any](name, help, kind string, labels []string, newSeries func() *S) *vec[S] {
+	return &vec[S]{name: name, help: help, kind: kind, labels: labels, newSeries: newSeries,
+		series: map[string]*S{}, values: map[string][]string{}}
+}
+
+func (v *vec[S]) with(values []string) *S {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = v.newSeries()
+		v.series[key] = s
+		v.values[key] = append([]string(nil), values...)
+	}
+	return s
+}
+
+// each calls f for every series, ordered by label values so that the
+// output is stable.
+func (v *vec[S]) each(w *bufio.Writer, f func(labels string, s *S)) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	series := make([]*S, len(keys))
+	labels := make([]string, len(keys))
+	for i, key := range keys {
+		series[i] = v.series[key]
+		labels[i] = formatLabels(v.labels, v.values[key])
+	}
+	v.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, escapeHelp(v.help), v.name, v.kind)
+	for i := range series {
+		f(labels[i], series[i])
+	}
+}
+
+// Counter only goes up.
+type Counter struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (c *Counter) Inc() { c.Add(1) }
+
+// Add panics when delta is negative.
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("metrics: counter decreased")
+	}
+	c.mu.Lock()
+	c.value += delta
+	c.mu.Unlock()
+}
+
+func (c *Counter) get() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+type CounterVec struct{ *vec[Counter] }
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) CounterVec {
+	v := CounterVec{newVec(name, help, "counter", labels, func() *Counter { return &Counter{} })}
+	r.register(name, v)
+	return v
+}
+
+// NewCounter registers a counter without labels.
+func (r *Registry) NewCounter(name, help string) *Counter {
+	return r.NewCounterVec(name, help).With()
+}
+
+// With returns the counter for the label values, in label order.
+func (v CounterVec) With(values ...string) *Counter { return v.with(values) }
+
+func (v CounterVec) writeTo(w *bufio.Writer) {
+	v.each(w, func(labels string, c *Counter) {
+		writeSample(w, v.name, labels, c.get())
+	})
+}
+
+// Gauge goes up and down.
+type Gauge struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (g *Gauge) Inc() { g.Add(1) }
+func (g *Gauge) Dec() { g.Add(-1) }
+
+func (g *Gauge) Add(delta float64) {
+	g.mu.Lock()
+	g.value += delta
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Set(value float64) {
+	g.mu.Lock()
+	g.value = value
+	g.mu.Unlock()
+}
+
+func (g *Gauge) get() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+type GaugeVec struct{ *vec[Gauge] }
+
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) GaugeVec {
+	v := GaugeVec{newVec(name, help, "gauge", labels, func() *Gauge { return &Gauge{} })}
+	r.register(name, v)
+	return v
+}
+
+// NewGauge registers a gauge without labels.
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	return r.NewGaugeVec(name, help).With()
+}
+
+func (v GaugeVec) With(values ...string) *Gauge { return v.with(values) }
+
+func (v GaugeVec) writeTo(w *bufio.Writer) {
+	v.each(w, func(labels string, g *Gauge) {
+		writeSample(w, v.name, labels, g.get())
+	})
+}
+
+// funcFamily reads its single value when scraped.
+type funcFamily struct {
+	name, help, kind string
+	value            func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is read from f on every
+// scrape.
+func (r *Registry) NewGaugeFunc(name, help string, f func() float64) {
+	r.register(name, funcFamily{name, help, "gauge", f})
+}
+
+// NewCounterFunc registers a counter whose value is read from f on every
+// scrape; f must never return less than before.
+func (r *Registry) NewCounterFunc(name, help string, f func() float64) {
+	r.register(name, funcFamily{name, help, "counter", f})
+}
+
+func (f funcFamily) writeTo(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+	writeSample(w, f.name, "", f.value())
+}
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	upperBounds []float64
+
+	mu     sync.Mutex
+	counts []uint64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+}
+
+func (h *Histogram) Observe(value float64) {
+	i := sort.SearchFloat64s(h.upperBounds, value)
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += value
+	h.mu.Unlock()
+}
+
+type HistogramVec struct{ *vec[Histogram] }
+
+// NewHistogramVec registers a histogram with the given, sorted, bucket
+// upper bounds; the +Inf bucket is implied.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) HistogramVec {
+	upperBounds := append([]float64(nil), buckets...)
+	if !sort.Float64sAreSorted(upperBounds) {
+		panic("metrics: " + name + " buckets are not sorted")
+	}
+	v := HistogramVec{newVec(name, help, "histogram", labels, func() *Histogram {
+		return &Histogram{upperBounds: upperBounds, counts: make([]uint64, len(upperBounds)+1)}
+	})}
+	r.register(name, v)
+	return v
+}
+
+func (v HistogramVec) With(values ...string) *Histogram { return v.with(values) }
+
+func (v HistogramVec) writeTo(w *bufio.Writer) {
+	v.each(w, func(labels string, h *Histogram) {
+		h.mu.Lock()
+		counts := append([]uint64(nil), h.counts...)
+		sum := h.sum
+		h.mu.Unlock()
+
+		var cumulative uint64
+		for i, count := range counts {
+			cumulative += count
+			le := math.Inf(1)
+			if i < len(h.upperBounds) {
+				le = h.upperBounds[i]
+			}
+			writeSample(w, v.name+"_bucket", joinLabels(labels, `le="`+formatFloat(le)+`"`), float64(cumulative))
+		}
+		writeSample(w, v.name+"_sum", labels, sum)
+		writeSample(w, v.name+"_count", labels, float64(cumulative))
+	})
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	w.WriteString(name)
+	if labels != "" {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+func formatLabels(names, values []string) string {
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabel(values[i]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+func joinLabels(labels, extra string) string {
+	if labels == "" {
+		return extra
+	}
+	return labels + "," + extra
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
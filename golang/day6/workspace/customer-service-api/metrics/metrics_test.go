@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryHandler(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("requests_total", "Requests.", "route", "status")
+	inFlight := r.NewGauge("in_flight", "In flight.")
+	latency := r.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	r.NewGaugeFunc("answer", "The answer.", func() float64 { return 42 })
+
+	requests.With("/api/customers/{id}", "200").Inc()
+	requests.With("/api/customers/{id}", "200").Add(2)
+	requests.With(`say "hi"`+"\n", "404").Inc()
+	inFlight.Inc()
+	inFlight.Inc()
+	inFlight.Dec()
+	latency.With("/a").Observe(0.05)
+	latency.With("/a").Observe(0.1)
+	latency.With("/a").Observe(3)
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("wanted the text exposition content type, got %v", ct)
+	}
+	want := `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{route="/api/customers/{id}",status="200"} 3
+requests_total{route="say \"hi\"\n",status="404"} 1
+# HELP in_flight In flight.
+# TYPE in_flight gauge
+in_flight 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a",le="0.1"} 2
+latency_seconds_bucket{route="/a",le="1"} 2
+latency_seconds_bucket{route="/a",le="+Inf"} 3
+latency_seconds_sum{route="/a"} 3.15
+latency_seconds_count{route="/a"} 3
+# HELP answer The answer.
+# TYPE answer gauge
+answer 42
+`
+	if got := w.Body.String(); got != want {
+		t.Errorf("wanted\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestCounterDecrease(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("was expecting a panic; did not get one")
+		}
+	}()
+	NewRegistry().NewCounter("c", "C.").Add(-1)
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"api/dao"
+	"api/model"
+	"context"
+	"errors"
+	"time"
+)
+
+// observedRepository times every call to the CustomerRepository it wraps.
+type observedRepository struct {
+	next dao.CustomerRepository
+}
+
+// ObserveRepository returns repo with its operations recorded in
+// QueryDuration and QueryErrors.
+func ObserveRepository(repo dao.CustomerRepository) dao.CustomerRepository {
+	return observedRepository{next: repo}
+}
+
+func observe(operation string, start time.Time, err error) {
+	QueryDuration.With(operation).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, dao.ErrNotFound) && !errors.Is(err, dao.ErrVersionMismatch) {
+		QueryErrors.With(operation).Inc()
+	}
+}
+
+func (o observedRepository) FindAll(ctx context.Context, q model.CustomerQuery) ([]model.Customer, int, error) {
+	start := time.Now()
+	customers, total, err := o.next.FindAll(ctx, q)
+	observe("find_all", start, err)
+	return customers, total, err
+}
+
+func (o observedRepository) FindById(ctx context.Context, id int) (model.Customer, error) {
+	start := time.Now()
+	c, err := o.next.FindById(ctx, id)
+	observe("find_by_id", start, err)
+	return c, err
+}
+
+func (o observedRepository) Save(ctx context.Context, customer model.Customer) (int, error) {
+	start := time.Now()
+	id, err := o.next.Save(ctx, customer)
+	observe("save", start, err)
+	return id, err
+}
+
+func (o observedRepository) Update(ctx context.Context, customer model.Customer) (model.Customer, error) {
+	start := time.Now()
+	c, err := o.next.Update(ctx, customer)
+	observe("update", start, err)
+	return c, err
+}
+
+func (o observedRepository) Patch(ctx context.Context, id, version int, patch model.CustomerPatch) (model.Customer, error) {
+	start := time.Now()
+	c, err := o.next.Patch(ctx, id, version, patch)
+	observe("patch", start, err)
+	return c, err
+}
+
+func (o observedRepository) Delete(ctx context.Context, id, version int) error {
+	start := time.Now()
+	err := o.next.Delete(ctx, id, version)
+	observe("delete", start, err)
+	return err
+}
+
+func (o observedRepository) Restore(ctx context.Context, id int) (model.Customer, error) {
+	start := time.Now()
+	c, err := o.next.Restore(ctx, id)
+	observe("restore", start, err)
+	return c, err
+}
+
+func (o observedRepository) Purge(ctx context.Context, deletedBefore time.Time) (int, error) {
+	start := time.Now()
+	purged, err := o.next.Purge(ctx, deletedBefore)
+	observe("purge", start, err)
+	return purged, err
+}
+
+func (o observedRepository) Search(ctx context.Context, q model.SearchQuery) ([]model.SearchResult, error) {
+	start := time.Now()
+	results, err := o.next.Search(ctx, q)
+	observe("search", start, err)
+	return results, err
+}
+
+func (o observedRepository) BeginImport(ctx context.Context) (dao.CustomerImport, error) {
+	start := time.Now()
+	imp, err := o.next.BeginImport(ctx)
+	observe("begin_import", start, err)
+	return imp, err
+}
+
+func (o observedRepository) Export(ctx context.Context, fn func(model.Customer) error) error {
+	start := time.Now()
+	err := o.next.Export(ctx, fn)
+	observe("export", start, err)
+	return err
+}
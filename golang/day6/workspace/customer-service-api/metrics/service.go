@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"database/sql"
+	"runtime"
+	"time"
+)
+
+// Default is the registry served at /metrics; the metrics of the service
+// below are registered with it.
+var Default = NewRegistry()
+
+var (
+	HTTPRequests = Default.NewCounterVec("http_requests_total",
+		"Requests served, by method, route template and status.", "method", "route", "status")
+	HTTPDuration = Default.NewHistogramVec("http_request_duration_seconds",
+		"Time to serve a request, by method, route template and status.", DefBuckets, "method", "route", "status")
+	HTTPInFlight = Default.NewGauge("http_requests_in_flight",
+		"Requests being served.")
+	PanicsRecovered = Default.NewCounter("http_panics_recovered_total",
+		"Handler panics recovered by ErrorHandlerMiddleware.")
+
+	QueryDuration = Default.NewHistogramVec("dao_query_duration_seconds",
+		"Time taken by customer repository operations.", DefBuckets, "operation")
+	QueryErrors = Default.NewCounterVec("dao_query_errors_total",
+		"Customer repository operations that failed, not counting not-found.", "operation")
+
+	OutboxPublished = Default.NewCounterVec("outbox_events_published_total",
+		"Customer events delivered, by sink.", "sink")
+	OutboxFailures = Default.NewCounterVec("outbox_publish_failures_total",
+		"Failed attempts to deliver a customer event, by sink.", "sink")
+
+	CustomersPurged = Default.NewCounter("customers_purged_total",
+		"Deleted customers removed for good by the purge job.")
+)
+
+var startTime = float64(time.Now().Unix())
+
+func init() {
+	Default.NewGaugeFunc("process_start_time_seconds", "Start time of the process since the epoch, in seconds.",
+		func() float64 { return startTime })
+	Default.NewGaugeFunc("go_goroutines", "Number of goroutines that currently exist.",
+		func() float64 { return float64(runtime.NumGoroutine()) })
+}
+
+// RegisterDBStats exports the sql.DB.Stats of the connection pool with
+// Default. It may be called once.
+func RegisterDBStats(db *sql.DB) {
+	stat := func(f func(s sql.DBStats) float64) func() float64 {
+		return func() float64 { return f(db.Stats()) }
+	}
+	Default.NewGaugeFunc("db_max_open_connections", "Maximum number of open connections to the database.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }))
+	Default.NewGaugeFunc("db_open_connections", "Established connections, in use or idle.",
+		stat(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }))
+	Default.NewGaugeFunc("db_in_use_connections", "Connections currently in use.",
+		stat(func(s sql.DBStats) float64 { return float64(s.InUse) }))
+	Default.NewGaugeFunc("db_idle_connections", "Idle connections.",
+		stat(func(s sql.DBStats) float64 { return float64(s.Idle) }))
+	Default.NewCounterFunc("db_wait_count_total", "Connections waited for.",
+		stat(func(s sql.DBStats) float64 { return float64(s.WaitCount) }))
+	Default.NewCounterFunc("db_wait_duration_seconds_total", "Time blocked waiting for a connection.",
+		stat(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }))
+	Default.NewCounterFunc("db_max_idle_closed_total", "Connections closed due to SetMaxIdleConns.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }))
+	Default.NewCounterFunc("db_max_idle_time_closed_total", "Connections closed due to SetConnMaxIdleTime.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }))
+	Default.NewCounterFunc("db_max_lifetime_closed_total", "Connections closed due to SetConnMaxLifetime.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }))
+}
@@ -0,0 +1,96 @@
+package middlewares
+
+import (
+	"api/auth"
+	"api/dao"
+	"api/logging"
+	"api/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Authenticate requires either an active API key in X-API-Key, looked up
+// in keys, or a valid "Authorization: Bearer" JWT, and puts the caller's
+// claims on the request context. Failures get a 401 with a
+// WWW-Authenticate challenge. verifier may be nil when only API keys are
+// accepted.
+func Authenticate(verifier *auth.Verifier, keys dao.APIKeyRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := r.Header.Get("X-API-Key"); key != "" {
+				claims, err := apiKeyClaims(r.Context(), keys, key)
+				switch {
+				case errors.Is(err, dao.ErrUnavailable):
+					logging.Logger(r.Context()).Warn("api key store unavailable", "error", err)
+					w.Header().Set("Retry-After", "5")
+					writeErrorMessage(w, http.StatusServiceUnavailable, "unavailable", dao.ErrUnavailable.Error())
+				case err != nil:
+					logging.Logger(r.Context()).Info("rejected api key", "error", err)
+					writeErrorMessage(w, http.StatusUnauthorized, "unauthorized", "The API key is invalid, expired or revoked.")
+				default:
+					next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+				}
+				return
+			}
+
+			scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+			if verifier == nil || !strings.EqualFold(scheme, "Bearer") || token == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="customers"`)
+				writeErrorMessage(w, http.StatusUnauthorized, "unauthorized", "A bearer token is required.")
+				return
+			}
+
+			claims, err := verifier.Verify(strings.TrimSpace(token))
+			if err != nil {
+				logging.Logger(r.Context()).Info("rejected bearer token", "error", err)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="customers", error="invalid_token"`)
+				writeErrorMessage(w, http.StatusUnauthorized, "unauthorized", "The bearer token is invalid or expired.")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+var errInactiveKey = errors.New("api key revoked or expired")
+
+func apiKeyClaims(ctx context.Context, keys dao.APIKeyRepository, key string) (*auth.Claims, error) {
+	k, err := keys.FindByHash(ctx, auth.HashAPIKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if !k.Active(time.Now()) {
+		return nil, errInactiveKey
+	}
+	return &auth.Claims{Subject: k.Owner, Roles: k.Scopes, APIKey: &k}, nil
+}
+
+// RequireRole lets a request through only when its claims grant role. It
+// must run behind Authenticate; a request without claims gets a 401.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := auth.ClaimsFrom(r.Context())
+			switch {
+			case claims == nil:
+				w.Header().Set("WWW-Authenticate", `Bearer realm="customers"`)
+				writeErrorMessage(w, http.StatusUnauthorized, "unauthorized", "A bearer token is required.")
+			case !claims.HasRole(role):
+				w.Header().Set("WWW-Authenticate", `Bearer realm="customers", error="insufficient_scope", scope="`+role+`"`)
+				writeErrorMessage(w, http.StatusForbidden, "forbidden", "The "+role+" role is required.")
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+func writeErrorMessage(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(model.ErrorMessage{Code: code, Message: message})
+}
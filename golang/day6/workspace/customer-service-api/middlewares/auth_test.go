@@ -0,0 +1,94 @@
+package middlewares
+
+import (
+	"api/auth"
+	"api/dao"
+	"api/model"
+	"api/settings"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuthenticate(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	verifier, err := auth.NewVerifier(settings.Auth{HMACSecret: string(secret)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := func(roles ...string) string {
+		tk, _ := auth.SignHS256(map[string]any{"sub": "alice", "exp": time.Now().Add(time.Minute).Unix(), "roles": roles}, secret)
+		return "Bearer " + tk
+	}
+
+	keys := dao.NewMemoryAPIKeyRepository()
+	apiKey := func(scopes []string, revoked bool) string {
+		key, prefix, hash := auth.NewAPIKey()
+		k, _ := keys.Create(context.Background(), model.APIKey{Prefix: prefix, Owner: "alice", Scopes: scopes}, hash)
+		if revoked {
+			keys.Revoke(context.Background(), k.Id, time.Now())
+		}
+		return key
+	}
+	writeKey := apiKey([]string{auth.RoleCustomersWrite}, false)
+	readKey := apiKey([]string{auth.RoleCustomersRead}, false)
+	revokedKey := apiKey([]string{auth.RoleCustomersWrite}, true)
+
+	var seen *auth.Claims
+	handler := Authenticate(verifier, keys)(RequireRole(auth.RoleCustomersWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = auth.ClaimsFrom(r.Context())
+	})))
+
+	subtests := []struct {
+		name          string
+		authorization string
+		apiKey        string
+		want          int
+		code          string
+	}{
+		{"no token", "", "", http.StatusUnauthorized, "unauthorized"},
+		{"basic auth", "Basic YWxpY2U6c2VjcmV0", "", http.StatusUnauthorized, "unauthorized"},
+		{"bad token", "Bearer abc.def.ghi", "", http.StatusUnauthorized, "unauthorized"},
+		{"missing role", token(auth.RoleCustomersRead), "", http.StatusForbidden, "forbidden"},
+		{"granted", token(auth.RoleCustomersWrite), "", http.StatusOK, ""},
+		{"unknown api key", "", "csk_00000000_nope", http.StatusUnauthorized, "unauthorized"},
+		{"revoked api key", "", revokedKey, http.StatusUnauthorized, "unauthorized"},
+		{"api key missing scope", "", readKey, http.StatusForbidden, "forbidden"},
+		{"api key granted", "", writeKey, http.StatusOK, ""},
+	}
+	for _, st := range subtests {
+		t.Run(st.name, func(t *testing.T) {
+			seen = nil
+			req := httptest.NewRequest("POST", "/api/customers", nil)
+			if st.authorization != "" {
+				req.Header.Set("Authorization", st.authorization)
+			}
+			if st.apiKey != "" {
+				req.Header.Set("X-API-Key", st.apiKey)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != st.want {
+				t.Fatalf("wanted %v, got %v", st.want, w.Code)
+			}
+			if st.code == "" {
+				if seen == nil || seen.Subject != "alice" {
+					t.Errorf("wanted claims for alice in the context, got %+v", seen)
+				}
+				return
+			}
+			var msg model.ErrorMessage
+			json.NewDecoder(w.Body).Decode(&msg)
+			if msg.Code != st.code {
+				t.Errorf("wanted %v, got %v", st.code, msg.Code)
+			}
+			if st.apiKey == "" && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("was expecting a WWW-Authenticate header; did not get one")
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package middlewares
+
+import (
+	"api/settings"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Cors applies policy to every request. It must wrap the router rather
+// than be added with Use: mux runs middlewares only for matched routes,
+// and no route matches an OPTIONS preflight.
+//
+// Preflight requests are answered here with 204 and never reach the
+// router; the Access-Control-Allow-* headers are only added when the
+// origin, method and headers asked for are all allowed.
+func Cors(policy settings.CORS) func(http.Handler) http.Handler {
+	allowedMethods := strings.Join(policy.AllowedMethods, ", ")
+	allowedHeaders := strings.Join(policy.AllowedHeaders, ", ")
+	exposedHeaders := strings.Join(policy.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(policy.MaxAge.Seconds()))
+	anyOrigin := slices.Contains(policy.AllowedOrigins, "*")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			if !anyOrigin {
+				w.Header().Add("Vary", "Origin")
+			}
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed := originAllowed(policy.AllowedOrigins, origin)
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+				if allowed &&
+					containsFold(policy.AllowedMethods, r.Header.Get("Access-Control-Request-Method")) &&
+					headersAllowed(policy.AllowedHeaders, r.Header.Get("Access-Control-Request-Headers")) {
+					setAllowOrigin(w, origin, anyOrigin, policy.AllowCredentials)
+					w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+					if allowedHeaders != "" {
+						w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+					}
+					if policy.MaxAge > 0 {
+						w.Header().Set("Access-Control-Max-Age", maxAge)
+					}
+				}
+				w.WriteHeader(http.StatusNoContent) // 204
+				return
+			}
+
+			if allowed {
+				setAllowOrigin(w, origin, anyOrigin, policy.AllowCredentials)
+				if exposedHeaders != "" {
+					w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func setAllowOrigin(w http.ResponseWriter, origin string, anyOrigin, credentials bool) {
+	if anyOrigin && !credentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// originAllowed matches origin against exact origins, "*" and subdomain
+// patterns such as "https://*.redbus.in" (which does not match
+// "https://redbus.in" itself).
+func originAllowed(patterns []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if pattern == "*" || pattern == origin {
+			return true
+		}
+		if scheme, host, ok := strings.Cut(pattern, "://*."); ok {
+			prefix := scheme + "://"
+			if strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, "."+host) &&
+				len(origin) > len(prefix)+len(host)+1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// headersAllowed checks a comma separated Access-Control-Request-Headers
+// value against the allowed list.
+func headersAllowed(allowed []string, requested string) bool {
+	if slices.Contains(allowed, "*") {
+		return true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		if header = strings.TrimSpace(header); header != "" && !containsFold(allowed, header) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
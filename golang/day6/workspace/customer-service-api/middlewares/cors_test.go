@@ -0,0 +1,90 @@
+package middlewares
+
+import (
+	"api/settings"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCors(t *testing.T) {
+	policy := settings.CORS{
+		AllowedOrigins:   []string{"https://www.redbus.in", "https://*.redbus.co"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Accept", "Content-Type"},
+		ExposedHeaders:   []string{"Link"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	reached := false
+	handler := Cors(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	subtests := []struct {
+		name          string
+		method        string
+		origin        string
+		requestMethod string
+		requestHeader string
+		wantOrigin    string
+		wantReached   bool
+	}{
+		{"same origin request", "GET", "", "", "", "", true},
+		{"allowed origin", "GET", "https://www.redbus.in", "", "", "https://www.redbus.in", true},
+		{"subdomain pattern", "GET", "https://partners.redbus.co", "", "", "https://partners.redbus.co", true},
+		{"pattern does not match the bare domain", "GET", "https://redbus.co", "", "", "", true},
+		{"unknown origin", "GET", "https://evil.example", "", "", "", true},
+		{"preflight", "OPTIONS", "https://www.redbus.in", "POST", "content-type", "https://www.redbus.in", false},
+		{"preflight for a method not allowed", "OPTIONS", "https://www.redbus.in", "DELETE", "", "", false},
+		{"preflight for a header not allowed", "OPTIONS", "https://www.redbus.in", "POST", "X-Secret", "", false},
+	}
+	for _, st := range subtests {
+		t.Run(st.name, func(t *testing.T) {
+			reached = false
+			req := httptest.NewRequest(st.method, "/api/customers", nil)
+			if st.origin != "" {
+				req.Header.Set("Origin", st.origin)
+			}
+			if st.requestMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", st.requestMethod)
+			}
+			if st.requestHeader != "" {
+				req.Header.Set("Access-Control-Request-Headers", st.requestHeader)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != st.wantOrigin {
+				t.Errorf("wanted Access-Control-Allow-Origin %q, got %q", st.wantOrigin, got)
+			}
+			if reached != st.wantReached {
+				t.Errorf("wanted handler reached %v, got %v", st.wantReached, reached)
+			}
+			if st.method == "OPTIONS" && w.Code != http.StatusNoContent {
+				t.Errorf("wanted %v, got %v", http.StatusNoContent, w.Code)
+			}
+		})
+	}
+
+	t.Run("preflight headers", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/api/customers", nil)
+		req.Header.Set("Origin", "https://www.redbus.in")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		want := map[string]string{
+			"Access-Control-Allow-Methods":     "GET, POST",
+			"Access-Control-Allow-Headers":     "Accept, Content-Type",
+			"Access-Control-Allow-Credentials": "true",
+			"Access-Control-Max-Age":           "600",
+		}
+		for header, value := range want {
+			if got := w.Header().Get(header); got != value {
+				t.Errorf("wanted %v %q, got %q", header, value, got)
+			}
+		}
+	})
+}
@@ -0,0 +1,213 @@
+package middlewares
+
+import (
+	"api/auth"
+	"api/dao"
+	"api/logging"
+	"api/model"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	maxIdempotencyKeyLength = 255
+
+	// well above what the routes using Idempotency accept; they turn
+	// bigger bodies away themselves
+	maxIdempotentBody = 1 << 20
+
+	// how often expired requests are deleted, at most
+	idempotencyPurgeInterval = 10 * time.Minute
+)
+
+// replayedHeaders are the response headers stored with a response and sent
+// again with its replays.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// Idempotency makes a POST safe to retry: the response to the first
+// request with an Idempotency-Key is stored, with a fingerprint of the
+// request, for the configured TTL, and retries with the same key get that
+// response again, marked with Idempotent-Replayed. Responses with a 5xx
+// status are not kept, so that the request can be retried for real.
+type Idempotency struct {
+	store dao.IdempotencyRepository
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu        sync.Mutex
+	lastPurge time.Time
+}
+
+func NewIdempotency(store dao.IdempotencyRepository, ttl time.Duration) *Idempotency {
+	return &Idempotency{store: store, ttl: ttl, now: time.Now}
+}
+
+// Middleware must run after Authenticate; keys are only unique per API key
+// or token subject.
+func (i *Idempotency) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !visibleASCII(key, maxIdempotencyKeyLength) {
+			writeErrorMessage(w, http.StatusBadRequest, "bad_request",
+				"Idempotency-Key must be 1 to "+strconv.Itoa(maxIdempotencyKeyLength)+" visible ASCII characters.")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBody))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				writeErrorMessage(w, http.StatusRequestEntityTooLarge, "too_large", "The request body is too large.")
+			} else {
+				writeErrorMessage(w, http.StatusBadRequest, "bad_request", "The request body could not be read.")
+			}
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := i.now()
+		i.purge(r.Context(), now)
+		req := model.IdempotentRequest{
+			Scope:       idempotencyScope(r),
+			Key:         key,
+			Fingerprint: fingerprint(r, body),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(i.ttl),
+		}
+		err = i.store.Reserve(r.Context(), req)
+		if errors.Is(err, dao.ErrIdempotencyKeyTaken) {
+			i.replay(w, r, req)
+			return
+		}
+		if err != nil {
+			writeIdempotencyUnavailable(w, r, err)
+			return
+		}
+
+		// the outcome is recorded even when the client has gone away
+		ctx := context.WithoutCancel(r.Context())
+		completed := false
+		defer func() {
+			if !completed {
+				if err := i.store.Release(ctx, req.Scope, req.Key); err != nil {
+					logging.Logger(ctx).Warn("releasing idempotency key", "error", err)
+				}
+			}
+		}()
+
+		rec := &capturingRecorder{responseRecorder: responseRecorder{ResponseWriter: w, status: http.StatusOK}}
+		next.ServeHTTP(rec, r)
+		if rec.status >= 500 {
+			return
+		}
+		header := map[string]string{}
+		for _, name := range replayedHeaders {
+			if value := w.Header().Get(name); value != "" {
+				header[name] = value
+			}
+		}
+		if err := i.store.Complete(ctx, req.Scope, req.Key, rec.status, header, rec.body.Bytes()); err != nil {
+			logging.Logger(ctx).Warn("storing idempotent response", "error", err)
+			return
+		}
+		completed = true
+	})
+}
+
+// replay answers a request whose key is taken with the stored response,
+// or with why it cannot.
+func (i *Idempotency) replay(w http.ResponseWriter, r *http.Request, req model.IdempotentRequest) {
+	stored, err := i.store.Find(r.Context(), req.Scope, req.Key)
+	if errors.Is(err, dao.ErrIdempotencyKeyNotFound) {
+		// released just now by a first request that failed; it is as
+		// good as in progress
+		stored, err = model.IdempotentRequest{Fingerprint: req.Fingerprint}, nil
+	}
+	switch {
+	case err != nil:
+		writeIdempotencyUnavailable(w, r, err)
+	case stored.Fingerprint != req.Fingerprint:
+		writeErrorMessage(w, http.StatusUnprocessableEntity, "idempotency_key_reused",
+			"This Idempotency-Key was already used for a different request.")
+	case !stored.Completed():
+		w.Header().Set("Retry-After", "1")
+		writeErrorMessage(w, http.StatusConflict, "request_in_progress",
+			"A request with this Idempotency-Key is still being served; retry later.")
+	default:
+		for name, value := range stored.Header {
+			w.Header().Set(name, value)
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(stored.Status)
+		w.Write(stored.Body)
+	}
+}
+
+// purge deletes expired requests once per idempotencyPurgeInterval.
+func (i *Idempotency) purge(ctx context.Context, now time.Time) {
+	i.mu.Lock()
+	due := now.Sub(i.lastPurge) >= idempotencyPurgeInterval
+	if due {
+		i.lastPurge = now
+	}
+	i.mu.Unlock()
+
+	if due {
+		if _, err := i.store.DeleteExpired(ctx, now); err != nil {
+			logging.Logger(ctx).Warn("deleting expired idempotency keys", "error", err)
+		}
+	}
+}
+
+func writeIdempotencyUnavailable(w http.ResponseWriter, r *http.Request, err error) {
+	logging.Logger(r.Context()).Warn("idempotency store unavailable", "error", err)
+	w.Header().Set("Retry-After", "5")
+	writeErrorMessage(w, http.StatusServiceUnavailable, "unavailable", "Idempotency keys cannot be checked right now.")
+}
+
+// idempotencyScope is who sent r: its API key or token subject, or
+// nobody in particular when authentication is off. Subjects, which have
+// no limit of their own, are kept as their SHA-256 so that they fit.
+func idempotencyScope(r *http.Request) string {
+	claims := auth.ClaimsFrom(r.Context())
+	switch {
+	case claims == nil:
+		return ""
+	case claims.APIKey != nil:
+		return auth.Actor(r.Context())
+	}
+	sum := sha256.Sum256([]byte(claims.Subject))
+	return "sub:" + hex.EncodeToString(sum[:])
+}
+
+// fingerprint tells requests apart by method, path and body.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// capturingRecorder passes a response through while keeping a copy of its
+// body.
+type capturingRecorder struct {
+	responseRecorder
+	body bytes.Buffer
+}
+
+func (rec *capturingRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.responseRecorder.Write(b)
+}
@@ -0,0 +1,156 @@
+package middlewares
+
+import (
+	"api/auth"
+	"api/dao"
+	"api/model"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotency(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	idempotency := NewIdempotency(dao.NewMemoryIdempotencyRepository(), time.Hour)
+	idempotency.now = func() time.Time { return now }
+
+	created, status := 0, http.StatusCreated
+	handler := idempotency.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		created++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", fmt.Sprintf("/api/customers/%d", created))
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"id":%d,"body":%s}`, created, body)
+	}))
+
+	serve := func(key, body string, apiKey *model.APIKey) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/customers", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		if apiKey != nil {
+			req = req.WithContext(auth.WithClaims(req.Context(), &auth.Claims{APIKey: apiKey}))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		first := serve("k-1", `{"name":"Ravi"}`, nil)
+		again := serve("k-1", `{"name":"Ravi"}`, nil)
+		if created != 1 {
+			t.Fatalf("wanted the handler to run once, ran %d times", created)
+		}
+		if again.Code != first.Code || again.Body.String() != first.Body.String() ||
+			again.Header().Get("Location") != "/api/customers/1" {
+			t.Errorf("wanted %v %q, got %v %q", first.Code, first.Body, again.Code, again.Body)
+		}
+		if again.Header().Get("Idempotent-Replayed") != "true" || first.Header().Get("Idempotent-Replayed") != "" {
+			t.Error("wanted only the replay marked with Idempotent-Replayed")
+		}
+	})
+
+	t.Run("different body", func(t *testing.T) {
+		w := serve("k-1", `{"name":"Sita"}`, nil)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("wanted %v, got %v", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+
+	t.Run("keys are per caller", func(t *testing.T) {
+		before := created
+		serve("k-1", `{"name":"Ravi"}`, &model.APIKey{Id: 7})
+		if created != before+1 {
+			t.Error("wanted another caller's key to be a new request")
+		}
+	})
+
+	t.Run("without a key", func(t *testing.T) {
+		before := created
+		serve("", `{"name":"Ravi"}`, nil)
+		serve("", `{"name":"Ravi"}`, nil)
+		if created != before+2 {
+			t.Errorf("wanted 2 requests served, got %d", created-before)
+		}
+	})
+
+	t.Run("server errors are not kept", func(t *testing.T) {
+		status = http.StatusServiceUnavailable
+		serve("k-2", `{}`, nil)
+		status = http.StatusCreated
+		before := created
+		if w := serve("k-2", `{}`, nil); w.Code != http.StatusCreated || created != before+1 {
+			t.Errorf("wanted the retry served for real, got %v", w.Code)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		before := created
+		if w := serve("k-1", `{"name":"Sita"}`, nil); w.Code != http.StatusCreated || created != before+1 {
+			t.Errorf("wanted an expired key to be usable again, got %v", w.Code)
+		}
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		if w := serve(strings.Repeat("k", 256), `{}`, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("wanted %v, got %v", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	repo := dao.NewMemoryIdempotencyRepository()
+	idempotency := NewIdempotency(repo, time.Hour)
+	handler := idempotency.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a retry arrives while the first request is still being served
+		retry := httptest.NewRequest("POST", "/api/customers", strings.NewReader(`{}`))
+		retry.Header.Set("Idempotency-Key", "k-1")
+		rw := httptest.NewRecorder()
+		idempotency.Middleware(http.NotFoundHandler()).ServeHTTP(rw, retry)
+		if rw.Code != http.StatusConflict || rw.Header().Get("Retry-After") == "" {
+			t.Errorf("wanted %v with Retry-After, got %v", http.StatusConflict, rw.Code)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	req := httptest.NewRequest("POST", "/api/customers", strings.NewReader(`{}`))
+	req.Header.Set("Idempotency-Key", "k-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	subtests := []struct {
+		name   string
+		claims *auth.Claims
+		scope  string
+	}{
+		{"no authentication", nil, ""},
+		{"api key", &auth.Claims{APIKey: &model.APIKey{Id: 7}}, "key:7"},
+		{"subject", &auth.Claims{Subject: "vinod"}, "sub:f7e264dda4f4f8863c67cc20950a76e06f9920bf61562ed65effb205266623c8"},
+	}
+	for _, st := range subtests {
+		t.Run(st.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/customers", nil)
+			if st.claims != nil {
+				req = req.WithContext(auth.WithClaims(req.Context(), st.claims))
+			}
+			if scope := idempotencyScope(req); scope != st.scope {
+				t.Errorf("wanted %v, got %v", st.scope, scope)
+			}
+		})
+	}
+
+	// however long the subject, the scope fits SCOPE varchar(100)
+	req := httptest.NewRequest("POST", "/api/customers", nil)
+	req = req.WithContext(auth.WithClaims(req.Context(), &auth.Claims{Subject: strings.Repeat("s", 1000)}))
+	if scope := idempotencyScope(req); len(scope) > 100 {
+		t.Errorf("wanted at most 100 characters, got %d", len(scope))
+	}
+}
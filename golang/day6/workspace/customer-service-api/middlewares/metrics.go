@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"api/metrics"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// unmatchedRoute labels the requests matching no route, 404s and 405s.
+const unmatchedRoute = "unmatched"
+
+type routeKey struct{}
+
+// Metrics counts and times requests by method, route template and status.
+// Using the template rather than the path keeps one series per route. It
+// wraps the whole router, as middlewares the router uses only ever see
+// matched requests; MetricsRoute, used by the router, tells it the
+// template of the route matched, if any.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		metrics.HTTPInFlight.Inc()
+		defer metrics.HTTPInFlight.Dec()
+
+		route := unmatchedRoute
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), routeKey{}, &route)))
+
+		status := strconv.Itoa(rec.status)
+		metrics.HTTPRequests.With(r.Method, route, status).Inc()
+		metrics.HTTPDuration.With(r.Method, route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// MetricsRoute records the template of the route the router matched for
+// Metrics, without matching the request a second time.
+func MetricsRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					*route = template
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,35 +1,41 @@
 package middlewares
 
 import (
+	"api/logging"
+	"api/metrics"
 	"api/model"
+	"api/render"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
-)
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
 
-func CorsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "*")
-		next.ServeHTTP(w, r)
-	})
-}
+	"github.com/gorilla/mux"
+)
 
-func JsonResponseMiddleware(next http.Handler) http.Handler {
+func AuthoredByMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Authored-By", "Vinod (vinod@vinod.co)")
-		w.Header().Set("Content-Type", "application/json")
 		next.ServeHTTP(w, r)
 	})
 }
 
+// ErrorHandlerMiddleware is the last-resort safety net for handlers that
+// panic. The panic is logged with its stack trace; the client only gets a
+// generic message, never the panic value.
 func ErrorHandlerMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
-			if r := recover(); r != nil {
+			if p := recover(); p != nil {
+				metrics.PanicsRecovered.Inc()
+				logging.Logger(r.Context()).Error("panic serving request",
+					"method", r.Method, "path", r.URL.Path, "panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusInternalServerError) // 500
-				err := model.ErrorMessage{Message: fmt.Sprint(r)}
+				err := model.ErrorMessage{Code: "internal", Message: "An unexpected error occurred."}
 				json.NewEncoder(w).Encode(err)
 			}
 		}()
@@ -37,19 +43,115 @@ func ErrorHandlerMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func RejectNonJsonRequest(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Header["Accept"][0] != "application/json" {
-			w.WriteHeader(http.StatusNotAcceptable)
-		} else {
-			next.ServeHTTP(w, r)
-		}
-	})
+// NegotiateContentType picks the response media type from the Accept
+// header among render.Supported and records it on the request; a request
+// accepting none of them gets a 406 listing the supported types. Handlers
+// set Content-Type themselves, since errors are always JSON.
+func NegotiateContentType(next http.Handler) http.Handler {
+	return Produces(render.Supported...)(next)
 }
+
+// Produces is NegotiateContentType for routes responding with only some
+// media types, e.g. just JSON, picking among mediaTypes instead.
+func Produces(mediaTypes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !slices.Contains(w.Header().Values("Vary"), "Accept") {
+				w.Header().Add("Vary", "Accept")
+			}
+
+			mediaType, ok := render.Negotiate(r.Header.Get("Accept"), mediaTypes)
+			if !ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotAcceptable) // 406
+				json.NewEncoder(w).Encode(model.ErrorMessage{
+					Code:    "not_acceptable",
+					Message: "Supported media types are " + strings.Join(mediaTypes, ", ") + ".",
+				})
+				return
+			}
+			next.ServeHTTP(w, render.WithMediaType(r, mediaType))
+		})
+	}
+}
+
+// LogRequestMiddleware writes one structured access log entry per request
+// once it has been served. It takes the request ID from X-Request-ID when
+// the caller sent a sane one, or generates one, and echoes it back.
 func LogRequestMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s (%s)", r.Method, r.URL, r.RemoteAddr)
+		start := time.Now()
 
-		next.ServeHTTP(w, r)
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestId(id) {
+			id = logging.NewRequestId()
+		}
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(logging.WithRequestId(r.Context(), id))
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		route := ""
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+		logging.Logger(r.Context()).Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", route,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration_ms", float64(time.Since(start).Microseconds())/1000,
+			"remote_addr", r.RemoteAddr,
+			"user_agent", r.UserAgent(),
+		)
 	})
 }
+
+// validRequestId accepts caller supplied IDs of up to 128 visible ASCII
+// characters, so they cannot break the log format.
+func validRequestId(id string) bool {
+	return visibleASCII(id, 128)
+}
+
+// visibleASCII reports whether s is 1 to max visible ASCII characters.
+func visibleASCII(s string, max int) bool {
+	if s == "" || len(s) > max {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] <= ' ' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// responseRecorder remembers the status and size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,135 @@
+package middlewares
+
+import (
+	"api/logging"
+	"api/metrics"
+	"api/render"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestLogRequestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	var seenId string
+	r := mux.NewRouter()
+	r.Use(LogRequestMiddleware)
+	r.HandleFunc("/api/customers/{id}", func(w http.ResponseWriter, r *http.Request) {
+		seenId = logging.RequestId(r.Context())
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not here"))
+	})
+
+	t.Run("propagated request id", func(t *testing.T) {
+		buf.Reset()
+		req := httptest.NewRequest("GET", "/api/customers/7", nil)
+		req.Header.Set("X-Request-ID", "abc-123")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if seenId != "abc-123" || w.Header().Get("X-Request-ID") != "abc-123" {
+			t.Errorf("wanted request id abc-123 in context and response, got %q and %q", seenId, w.Header().Get("X-Request-ID"))
+		}
+
+		var entry map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("access log is not JSON: %v", err)
+		}
+		want := map[string]any{
+			"request_id": "abc-123",
+			"route":      "/api/customers/{id}",
+			"status":     float64(404),
+			"bytes":      float64(8),
+		}
+		for k, v := range want {
+			if entry[k] != v {
+				t.Errorf("wanted %v %v, got %v", k, v, entry[k])
+			}
+		}
+	})
+
+	t.Run("generated request id", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/customers/7", nil)
+		req.Header.Set("X-Request-ID", "has spaces\nand newlines")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if len(seenId) != 32 || w.Header().Get("X-Request-ID") != seenId {
+			t.Errorf("wanted a generated 32 character id, got %q", seenId)
+		}
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(MetricsRoute)
+	r.Use(ErrorHandlerMiddleware)
+	r.HandleFunc("/api/metrics-test/{id}", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}).Methods("GET")
+	handler := Metrics(r)
+
+	for _, path := range []string{"/api/metrics-test/1", "/api/metrics-test/2", "/api/metrics-test-missing"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/api/metrics-test/1", nil))
+
+	w := httptest.NewRecorder()
+	metrics.Default.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range []string{
+		`http_requests_total{method="GET",route="/api/metrics-test/{id}",status="500"} 2`,
+		`http_request_duration_seconds_count{method="GET",route="/api/metrics-test/{id}",status="500"} 2`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_requests_total{method="DELETE",route="unmatched",status="405"} 1`,
+		`http_panics_recovered_total 2`,
+		`http_requests_in_flight 0`,
+	} {
+		if !strings.Contains(w.Body.String(), line+"\n") {
+			t.Errorf("wanted %q in\n%s", line, w.Body.String())
+		}
+	}
+}
+
+func TestProduces(t *testing.T) {
+	var seen string
+	handler := NegotiateContentType(Produces(render.JSON)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = render.MediaType(r)
+	})))
+
+	subtests := []struct {
+		name      string
+		accept    string
+		status    int
+		mediaType string
+	}{
+		{"json", "application/json", http.StatusOK, render.JSON},
+		{"anything", "*/*", http.StatusOK, render.JSON},
+		{"csv preferred over json", "text/csv, application/json;q=0.5", http.StatusOK, render.JSON},
+		{"csv", "text/csv", http.StatusNotAcceptable, ""},
+		{"xml", "application/xml", http.StatusNotAcceptable, ""},
+	}
+	for _, st := range subtests {
+		t.Run(st.name, func(t *testing.T) {
+			seen = ""
+			req := httptest.NewRequest("GET", "/api/customers/1/history", nil)
+			req.Header.Set("Accept", st.accept)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != st.status || seen != st.mediaType {
+				t.Errorf("wanted %v with %q, got %v with %q", st.status, st.mediaType, w.Code, seen)
+			}
+			if vary := w.Header().Values("Vary"); len(vary) != 1 {
+				t.Errorf("wanted Vary: Accept once, got %v", vary)
+			}
+		})
+	}
+}
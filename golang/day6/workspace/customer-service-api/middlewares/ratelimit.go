@@ -0,0 +1,199 @@
+package middlewares
+
+import (
+	"api/auth"
+	"api/settings"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter hands out a token bucket per client IP, and one per API key
+// or token subject to the callers that authenticate. Buckets refill
+// continuously at Requests per Period; idle, full buckets are dropped.
+type RateLimiter struct {
+	requests int
+	period   time.Duration
+	burst    int
+	now      func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens    float64
+	last      time.Time
+	burst     float64
+	perSecond float64
+}
+
+func NewRateLimiter(config settings.RateLimit) *RateLimiter {
+	return &RateLimiter{
+		requests:  config.Requests,
+		period:    config.Period,
+		burst:     config.Burst,
+		now:       time.Now,
+		buckets:   map[string]*bucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+// quota is the outcome of one take from a bucket.
+type quota struct {
+	allowed    bool
+	limit      int
+	remaining  int
+	reset      time.Duration // until the bucket is full again
+	retryAfter time.Duration // until the next token, when not allowed
+}
+
+// rate is the burst size and refill rate of a bucket. requests overrides
+// the configured rate when non-zero.
+func (l *RateLimiter) rate(requests int) (burst int, perSecond float64) {
+	burst = l.burst
+	if requests == 0 {
+		requests = l.requests
+	} else {
+		// a key's own rate comes without a separate burst size
+		burst = requests
+	}
+	if burst == 0 {
+		burst = requests
+	}
+	return burst, float64(requests) / l.period.Seconds()
+}
+
+// take removes a token from the bucket of key. requests overrides the
+// configured rate when non-zero.
+func (l *RateLimiter) take(key string, requests int) quota {
+	burst, perSecond := l.rate(requests)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		l.buckets[key] = b
+	}
+	b.burst, b.perSecond = float64(burst), perSecond
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+
+	q := quota{limit: burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		q.allowed = true
+	} else {
+		q.retryAfter = seconds((1 - b.tokens) / perSecond)
+	}
+	q.remaining = int(b.tokens)
+	q.reset = seconds((float64(burst) - b.tokens) / perSecond)
+	return q
+}
+
+// giveBack returns the token just taken from the bucket of key.
+func (l *RateLimiter) giveBack(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = math.Min(b.burst, b.tokens+1)
+	}
+}
+
+// sweep drops the buckets that have had time to fill up again, at most
+// once per period. A bucket with a burst above its rate takes longer
+// than a period to fill, and dropping it earlier would hand its client a
+// full burst.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.period {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.perSecond >= b.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Middleware limits every request by its client IP, so that it must run
+// before Authenticate for callers failing to authenticate to be limited
+// too. It sets the RateLimit-Limit, -Remaining and -Reset headers on every
+// response and answers 429 with Retry-After once the bucket is empty.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.limit(w, "ip:"+clientIp(r), 0) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// CallerMiddleware moves the requests of authenticated callers from the
+// bucket of their IP to that of their API key, which may have a rate of
+// its own, or token subject. Tokens without a subject stay on the bucket
+// of their IP. It must run after Authenticate, which must run after
+// Middleware.
+func (l *RateLimiter) CallerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := auth.ClaimsFrom(r.Context())
+		if claims == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key, requests := "", 0
+		switch {
+		case claims.APIKey != nil:
+			key, requests = "key:"+strconv.Itoa(claims.APIKey.Id), claims.APIKey.RateLimit
+		case claims.Subject != "":
+			key = "sub:" + claims.Subject
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+		l.giveBack("ip:" + clientIp(r))
+		if l.limit(w, key, requests) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// limit takes a token from the bucket of key and reports whether the
+// request may go on; when not, it has answered 429.
+func (l *RateLimiter) limit(w http.ResponseWriter, key string, requests int) bool {
+	q := l.take(key, requests)
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(q.limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(q.remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(q.reset)))
+	if !q.allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(q.retryAfter)))
+		writeErrorMessage(w, http.StatusTooManyRequests, "rate_limited", "Too many requests; retry later.")
+	}
+	return q.allowed
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// clientIp is the host part of RemoteAddr. X-Forwarded-For is ignored, as
+// any client can set it.
+func clientIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
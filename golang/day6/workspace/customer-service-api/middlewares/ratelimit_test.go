@@ -0,0 +1,146 @@
+package middlewares
+
+import (
+	"api/auth"
+	"api/model"
+	"api/settings"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter := NewRateLimiter(settings.RateLimit{Requests: 2, Period: time.Minute})
+	limiter.now = func() time.Time { return now }
+	// who authenticates, in between the two limiters, if anyone
+	var claims *auth.Claims
+	authenticate := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if claims != nil {
+				r = r.WithContext(auth.WithClaims(r.Context(), claims))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	handler := limiter.Middleware(authenticate(limiter.CallerMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))))
+
+	serveAs := func(remoteAddr string, caller *auth.Claims) *httptest.ResponseRecorder {
+		claims = caller
+		req := httptest.NewRequest("GET", "/api/customers", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	serve := func(remoteAddr string, key *model.APIKey) *httptest.ResponseRecorder {
+		if key == nil {
+			return serveAs(remoteAddr, nil)
+		}
+		return serveAs(remoteAddr, &auth.Claims{APIKey: key})
+	}
+
+	t.Run("per client ip", func(t *testing.T) {
+		for i, want := range []string{"1", "0"} {
+			w := serve("10.0.0.1:1234", nil)
+			if w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != want {
+				t.Fatalf("request %d: wanted 200 with %v remaining, got %v with %v", i, want, w.Code, w.Header().Get("RateLimit-Remaining"))
+			}
+		}
+		w := serve("10.0.0.1:5678", nil)
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("wanted %v, got %v", http.StatusTooManyRequests, w.Code)
+		}
+		// one token comes back every 30s
+		if got := w.Header().Get("Retry-After"); got != "30" {
+			t.Errorf("wanted Retry-After 30, got %v", got)
+		}
+		if got := w.Header().Get("RateLimit-Reset"); got != "60" {
+			t.Errorf("wanted RateLimit-Reset 60, got %v", got)
+		}
+
+		if w := serve("10.0.0.2:1234", nil); w.Code != http.StatusOK {
+			t.Errorf("wanted another client to get %v, got %v", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("refill", func(t *testing.T) {
+		now = now.Add(30 * time.Second)
+		if w := serve("10.0.0.1:1234", nil); w.Code != http.StatusOK {
+			t.Errorf("wanted %v, got %v", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("per api key", func(t *testing.T) {
+		key := &model.APIKey{Id: 7, RateLimit: 1}
+		if w := serve("10.0.0.2:1234", key); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" {
+			t.Fatalf("wanted 200 with limit 1, got %v with %v", w.Code, w.Header().Get("RateLimit-Limit"))
+		}
+		if w := serve("10.0.0.3:1234", key); w.Code != http.StatusTooManyRequests {
+			t.Errorf("wanted the key to be limited from any address, got %v", w.Code)
+		}
+	})
+
+	t.Run("per token subject", func(t *testing.T) {
+		vinod := &auth.Claims{Subject: "vinod"}
+		for i := 0; i < 2; i++ {
+			serveAs("10.0.0.4:1234", vinod)
+		}
+		// which leaves the bucket of the ip full
+		if w := serve("10.0.0.4:1234", nil); w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != "1" {
+			t.Errorf("wanted 200 with 1 remaining, got %v with %v", w.Code, w.Header().Get("RateLimit-Remaining"))
+		}
+		if w := serveAs("10.0.0.5:1234", vinod); w.Code != http.StatusTooManyRequests {
+			t.Errorf("wanted the subject to be limited from any address, got %v", w.Code)
+		}
+	})
+
+	t.Run("token without subject", func(t *testing.T) {
+		// stays on the bucket of its ip rather than sharing one with every
+		// other token without a subject
+		for i := 0; i < 2; i++ {
+			serveAs("10.0.0.7:1234", &auth.Claims{})
+		}
+		if w := serve("10.0.0.7:1234", nil); w.Code != http.StatusTooManyRequests {
+			t.Errorf("wanted %v, got %v", http.StatusTooManyRequests, w.Code)
+		}
+		if w := serveAs("10.0.0.8:1234", &auth.Claims{}); w.Code != http.StatusOK {
+			t.Errorf("wanted %v, got %v", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("before authenticating", func(t *testing.T) {
+		// callers failing to authenticate are limited by their ip
+		for i := 0; i < 2; i++ {
+			serve("10.0.0.6:1234", nil)
+		}
+		if w := serveAs("10.0.0.6:1234", &auth.Claims{Subject: "shyam"}); w.Code != http.StatusTooManyRequests {
+			t.Errorf("wanted %v, got %v", http.StatusTooManyRequests, w.Code)
+		}
+	})
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter := NewRateLimiter(settings.RateLimit{Requests: 2, Period: time.Minute, Burst: 4})
+	limiter.now = func() time.Time { return now }
+	limiter.lastSweep = now
+
+	for i := 0; i < 4; i++ {
+		limiter.take("ip:10.0.0.1", 0)
+	}
+	limiter.take("ip:10.0.0.2", 0)
+
+	// a period later 10.0.0.1 has 2 of its 4 tokens back, and 10.0.0.2
+	// all of them
+	now = now.Add(time.Minute)
+	limiter.sweep(now)
+	if _, ok := limiter.buckets["ip:10.0.0.2"]; ok {
+		t.Errorf("wanted the full bucket dropped")
+	}
+	if q := limiter.take("ip:10.0.0.1", 0); !q.allowed || q.remaining != 1 {
+		t.Errorf("wanted 1 remaining, got %+v", q)
+	}
+}
@@ -0,0 +1,339 @@
+// Package migrations applies the versioned schema changes in sql/<dialect>
+// and records them in the schema_migrations table.
+//
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql;
+// versions are applied in ascending order and every up file needs a
+// matching down file.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql
+var files embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration together with when it was applied; AppliedAt is
+// nil for pending migrations.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+var filenamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in dir of fsys, ordered by version.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		m := filenamePattern.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("%s: not a migration file name", entry.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("version %d is used by both %q and %q", version, migration.Name, m[2])
+		}
+		if m[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies migrations to one database.
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+// lockName is the MySQL named lock Up holds, and lockTimeout how long it
+// waits for another process to finish migrating, in seconds.
+const (
+	lockName    = "schema_migrations"
+	lockTimeout = 300
+)
+
+// querier is what reading and changing schema_migrations needs of a
+// connection or transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// New returns a Migrator for the bundled migrations of dialect (the
+// database/sql driver name, e.g. "mysql").
+func New(db *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := Load(files, path.Join("sql", dialect))
+	if err != nil {
+		return nil, fmt.Errorf("migrations for %s: %w", dialect, err)
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+func (m *Migrator) ensureTable() error {
+	_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name varchar(100) NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	return err
+}
+
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	return readApplied(context.Background(), m.db)
+}
+
+func readApplied(ctx context.Context, q querier) (map[int]time.Time, error) {
+	rows, err := q.QueryContext(ctx, "select version, applied_at from schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	statuses := []Status{}
+	for _, migration := range m.migrations {
+		s := Status{Migration: migration}
+		if at, ok := applied[migration.Version]; ok {
+			s.AppliedAt = &at
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in order and returns those applied.
+// Processes starting together take turns, so that no migration is applied
+// twice: on MySQL, Up holds a named lock from before it reads
+// schema_migrations until it is done; on SQLite, every migration is
+// applied in a transaction begun with BEGIN IMMEDIATE, which takes the
+// write lock of the database, and schema_migrations is read again in it.
+func (m *Migrator) Up() ([]Migration, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if m.dialect == "mysql" {
+		var locked sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, lockTimeout).Scan(&locked); err != nil {
+			return nil, err
+		}
+		if locked.Int64 != 1 {
+			return nil, fmt.Errorf("another process has been migrating for %d seconds", lockTimeout)
+		}
+		defer conn.ExecContext(ctx, "DO RELEASE_LOCK(?)", lockName)
+	}
+
+	done := []Migration{}
+	for {
+		var next *Migration
+		err := m.transaction(ctx, conn, func(q querier) error {
+			applied, err := readApplied(ctx, q)
+			if err != nil {
+				return err
+			}
+			for i := range m.migrations {
+				if _, ok := applied[m.migrations[i].Version]; !ok {
+					next = &m.migrations[i]
+					break
+				}
+			}
+			if next == nil {
+				return nil
+			}
+			if err := execScript(ctx, q, *next, next.Up); err != nil {
+				return err
+			}
+			_, err = q.ExecContext(ctx, "INSERT INTO schema_migrations(version, name, applied_at) VALUES(?, ?, ?)",
+				next.Version, next.Name, time.Now().UTC())
+			return err
+		})
+		if err != nil || next == nil {
+			return done, err
+		}
+		done = append(done, *next)
+	}
+}
+
+// transaction runs fn in a transaction on conn; on SQLite one begun with
+// BEGIN IMMEDIATE, which database/sql has no option for.
+func (m *Migrator) transaction(ctx context.Context, conn *sql.Conn, fn func(querier) error) error {
+	if m.dialect != "sqlite" {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return err
+	}
+	if err := fn(conn); err != nil {
+		conn.ExecContext(ctx, "ROLLBACK")
+		return err
+	}
+	_, err := conn.ExecContext(ctx, "COMMIT")
+	return err
+}
+
+// Down rolls back the most recently applied migration; it returns nil when
+// nothing is applied.
+func (m *Migrator) Down() (*Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		err := m.run(migration, migration.Down, func(tx *sql.Tx) error {
+			_, err := tx.Exec("DELETE FROM schema_migrations WHERE version=?", migration.Version)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &migration, nil
+	}
+	return nil, nil
+}
+
+// Redo rolls back the most recently applied migration and applies it again.
+func (m *Migrator) Redo() (*Migration, error) {
+	migration, err := m.Down()
+	if err != nil || migration == nil {
+		return migration, err
+	}
+	_, err = m.Up()
+	return migration, err
+}
+
+// run executes the statements of script and then record in one
+// transaction. MySQL commits DDL implicitly, so there a failing script can
+// leave earlier statements of the same file applied.
+func (m *Migrator) run(migration Migration, script string, record func(*sql.Tx) error) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := execScript(context.Background(), tx, migration, script); err != nil {
+		return err
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// execScript executes the statements of script, a script of migration.
+func execScript(ctx context.Context, q querier, migration Migration, script string) error {
+	for _, stmt := range statements(script) {
+		if _, err := q.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+	return nil
+}
+
+// statements splits a script on semicolons that end a line, dropping
+// comment-only lines.
+func statements(script string) []string {
+	var result []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			result = append(result, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if s := strings.TrimSpace(current.String()); s != "" {
+		result = append(result, s)
+	}
+	return result
+}
@@ -0,0 +1,180 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/glebarez/go-sqlite"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("bundled mysql migrations", func(t *testing.T) {
+		migrations, err := Load(files, "sql/mysql")
+		if err != nil {
+			t.Fatalf("was not expecting an error, got %v", err)
+		}
+		if len(migrations) == 0 || migrations[0].Version != 1 {
+			t.Errorf("wanted migrations starting at version 1, got %v", migrations)
+		}
+		for i := 1; i < len(migrations); i++ {
+			if migrations[i].Version <= migrations[i-1].Version {
+				t.Errorf("migrations are not ordered: %d after %d", migrations[i].Version, migrations[i-1].Version)
+			}
+		}
+	})
+
+	negativeTests := []struct {
+		name   string
+		fsys   fstest.MapFS
+		errmsg string
+	}{
+		{"missing down", fstest.MapFS{
+			"m/0001_a.up.sql": {Data: []byte("select 1;")},
+		}, "needs both an up and a down file"},
+		{"bad file name", fstest.MapFS{
+			"m/create.sql": {Data: []byte("select 1;")},
+		}, "not a migration file name"},
+		{"version used twice", fstest.MapFS{
+			"m/0001_a.up.sql":   {Data: []byte("select 1;")},
+			"m/0001_b.down.sql": {Data: []byte("select 1;")},
+		}, "version 1 is used by both"},
+	}
+	for _, nt := range negativeTests {
+		t.Run(nt.name, func(t *testing.T) {
+			_, err := Load(nt.fsys, "m")
+			if err == nil {
+				t.Fatal("was expecting an error; did not get one")
+			}
+			if !strings.Contains(err.Error(), nt.errmsg) {
+				t.Errorf("wanted error containing '%v', got '%v'", nt.errmsg, err.Error())
+			}
+		})
+	}
+}
+
+func TestStatements(t *testing.T) {
+	script := "-- a comment\nCREATE TABLE A (\n  ID INTEGER\n);\n\nCREATE INDEX I ON A(ID);\n"
+	got := statements(script)
+	if len(got) != 2 {
+		t.Fatalf("wanted 2 statements, got %q", got)
+	}
+	if !strings.HasPrefix(got[0], "CREATE TABLE A (") || got[1] != "CREATE INDEX I ON A(ID)" {
+		t.Errorf("statements were not split correctly: %q", got)
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	migrator, err := New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := migrator.Up()
+	if err != nil || len(applied) != len(migrator.migrations) {
+		t.Fatalf("wanted all %d migrations applied, got %d (%v)", len(migrator.migrations), len(applied), err)
+	}
+	if applied, _ := migrator.Up(); len(applied) != 0 {
+		t.Errorf("wanted nothing applied the second time, got %v", applied)
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("was not expecting an error, got %v", err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			t.Errorf("migration %d should be applied", s.Version)
+		}
+	}
+
+	// rebuilding CUSTOMERS must not hand out the ids of removed rows again
+	db.Exec("INSERT INTO CUSTOMERS(NAME, EMAIL) VALUES('A', 'a@xmpl.com'), ('B', 'b@xmpl.com')")
+	db.Exec("DELETE FROM CUSTOMERS WHERE ID=2")
+
+	last := migrator.migrations[len(migrator.migrations)-1]
+	redone, err := migrator.Redo()
+	if err != nil || redone == nil || redone.Version != last.Version {
+		t.Errorf("wanted migration %d redone, got %v (%v)", last.Version, redone, err)
+	}
+
+	result, err := db.Exec("INSERT INTO CUSTOMERS(NAME, EMAIL) VALUES('C', 'c@xmpl.com')")
+	if err != nil {
+		t.Fatalf("was not expecting an error, got %v", err)
+	}
+	if id, _ := result.LastInsertId(); id != 3 {
+		t.Errorf("wanted id 3, got %d", id)
+	}
+
+	for range migrator.migrations {
+		if _, err := migrator.Down(); err != nil {
+			t.Fatalf("was not expecting an error, got %v", err)
+		}
+	}
+	if m, _ := migrator.Down(); m != nil {
+		t.Errorf("wanted nothing to roll back, got %v", m)
+	}
+}
+
+func TestConcurrentUp(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "customers.db") + "?_pragma=busy_timeout(5000)"
+	open := func() *Migrator {
+		db, err := sql.Open("sqlite", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		migrator, _ := New(db, "sqlite")
+		if err := migrator.ensureTable(); err != nil {
+			t.Fatal(err)
+		}
+		return migrator
+	}
+	first, second := open(), open()
+
+	// the first migrator takes its time applying everything, while the
+	// second one starts up
+	type result struct {
+		applied []Migration
+		err     error
+	}
+	secondDone := make(chan result, 1)
+	ctx := context.Background()
+	conn, _ := first.db.Conn(ctx)
+	defer conn.Close()
+	err := first.transaction(ctx, conn, func(q querier) error {
+		go func() {
+			applied, err := second.Up()
+			secondDone <- result{applied, err}
+		}()
+		time.Sleep(100 * time.Millisecond)
+		for _, m := range first.migrations {
+			if err := execScript(ctx, q, m, m.Up); err != nil {
+				return err
+			}
+			if _, err := q.ExecContext(ctx, "INSERT INTO schema_migrations(version, name, applied_at) VALUES(?, ?, ?)",
+				m.Version, m.Name, time.Now().UTC()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("was not expecting an error, got %v", err)
+	}
+
+	if r := <-secondDone; r.err != nil || len(r.applied) != 0 {
+		t.Errorf("wanted nothing left to apply, got %v (%v)", r.applied, r.err)
+	}
+}
@@ -0,0 +1,8 @@
+-- IF NOT EXISTS lets databases created before migrations existed adopt
+-- this version without losing data
+CREATE TABLE IF NOT EXISTS CUSTOMERS (
+    ID INTEGER PRIMARY KEY AUTO_INCREMENT,
+    NAME varchar(50) NOT NULL,
+    EMAIL varchar(50) UNIQUE,
+    CITY varchar(50)
+);
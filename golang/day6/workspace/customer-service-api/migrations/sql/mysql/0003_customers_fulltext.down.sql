@@ -0,0 +1 @@
+ALTER TABLE CUSTOMERS DROP INDEX CUSTOMERS_SEARCH;
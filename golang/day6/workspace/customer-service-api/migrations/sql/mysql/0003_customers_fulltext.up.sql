@@ -0,0 +1,3 @@
+-- used by SqlCustomerRepository.Search for word matches and ranking; the
+-- columns must stay in this order to match its MATCH() clause
+ALTER TABLE CUSTOMERS ADD FULLTEXT INDEX CUSTOMERS_SEARCH (NAME, EMAIL, CITY);
@@ -0,0 +1,16 @@
+-- the first response to a request with an Idempotency-Key, replayed to
+-- retries of it until EXPIRES_AT. Keys are per caller (SCOPE). STATUS is
+-- NULL while the first request is still being served; HEADERS is a JSON
+-- object and FINGERPRINT the SHA-256 in hex of method, path and body.
+CREATE TABLE IDEMPOTENCY_KEYS (
+    SCOPE varchar(100) NOT NULL,
+    IDEMPOTENCY_KEY varchar(255) NOT NULL,
+    FINGERPRINT char(64) NOT NULL,
+    STATUS INTEGER NULL,
+    HEADERS TEXT NULL,
+    BODY MEDIUMBLOB NULL,
+    CREATED_AT DATETIME NOT NULL,
+    EXPIRES_AT DATETIME NOT NULL,
+    PRIMARY KEY (SCOPE, IDEMPOTENCY_KEY)
+);
+CREATE INDEX IDEMPOTENCY_KEYS_EXPIRES_AT ON IDEMPOTENCY_KEYS (EXPIRES_AT);
@@ -0,0 +1,2 @@
+DROP TABLE OUTBOX_OFFSETS;
+DROP TABLE CUSTOMER_EVENTS;
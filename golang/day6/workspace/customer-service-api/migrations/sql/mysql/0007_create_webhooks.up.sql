@@ -0,0 +1,39 @@
+-- partners subscribed to customer events. EVENTS are space separated
+-- event types; SECRET signs the deliveries, so it is kept as given.
+CREATE TABLE WEBHOOK_SUBSCRIPTIONS (
+    ID INTEGER PRIMARY KEY AUTO_INCREMENT,
+    URL varchar(2000) NOT NULL,
+    EVENTS varchar(255) NOT NULL,
+    SECRET varchar(255) NOT NULL,
+    CREATED_AT DATETIME NOT NULL
+);
+-- one row per event and subscription, made when the event is relayed from
+-- the outbox. STATUS is pending, succeeded or dead; a pending delivery is
+-- tried at NEXT_ATTEMPT_AT, a dead one gave up after too many failures.
+CREATE TABLE WEBHOOK_DELIVERIES (
+    ID BIGINT PRIMARY KEY AUTO_INCREMENT,
+    SUBSCRIPTION_ID INTEGER NOT NULL,
+    EVENT_ID BIGINT NOT NULL,
+    EVENT_TYPE varchar(30) NOT NULL,
+    PAYLOAD TEXT NOT NULL,
+    STATUS varchar(20) NOT NULL,
+    ATTEMPTS INTEGER NOT NULL DEFAULT 0,
+    NEXT_ATTEMPT_AT DATETIME NULL,
+    LAST_ERROR varchar(500) NULL,
+    CREATED_AT DATETIME NOT NULL,
+    UPDATED_AT DATETIME NOT NULL,
+    UNIQUE (SUBSCRIPTION_ID, EVENT_ID),
+    FOREIGN KEY (SUBSCRIPTION_ID) REFERENCES WEBHOOK_SUBSCRIPTIONS (ID) ON DELETE CASCADE
+);
+CREATE INDEX WEBHOOK_DELIVERIES_DUE ON WEBHOOK_DELIVERIES (STATUS, NEXT_ATTEMPT_AT);
+-- every POST of a delivery, with the status it got or why it got none
+CREATE TABLE WEBHOOK_ATTEMPTS (
+    ID BIGINT PRIMARY KEY AUTO_INCREMENT,
+    DELIVERY_ID BIGINT NOT NULL,
+    ATTEMPT INTEGER NOT NULL,
+    STATUS_CODE INTEGER NULL,
+    ERROR varchar(500) NULL,
+    DURATION_MS INTEGER NOT NULL,
+    ATTEMPTED_AT DATETIME NOT NULL,
+    FOREIGN KEY (DELIVERY_ID) REFERENCES WEBHOOK_DELIVERIES (ID) ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+DROP TABLE CUSTOMER_AUDIT;
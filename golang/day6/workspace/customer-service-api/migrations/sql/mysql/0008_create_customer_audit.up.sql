@@ -0,0 +1,15 @@
+-- the audit trail of CUSTOMERS: every insert, update, delete, restore and
+-- purge adds a row in the same transaction, and rows are never changed or
+-- removed. There is no foreign key, so that the history outlives the
+-- customer. CHANGES maps each field that changed to its value before and
+-- after, as JSON.
+CREATE TABLE CUSTOMER_AUDIT (
+    ID BIGINT PRIMARY KEY AUTO_INCREMENT,
+    CUSTOMER_ID INTEGER NOT NULL,
+    OPERATION varchar(10) NOT NULL,
+    ACTOR varchar(255) NOT NULL,
+    REQUEST_ID varchar(128) NOT NULL,
+    CHANGES TEXT NOT NULL,
+    CREATED_AT DATETIME NOT NULL
+);
+CREATE INDEX CUSTOMER_AUDIT_CUSTOMER ON CUSTOMER_AUDIT (CUSTOMER_ID, ID);
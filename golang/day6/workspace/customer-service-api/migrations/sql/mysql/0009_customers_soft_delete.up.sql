@@ -0,0 +1,5 @@
+-- deleting a customer sets DELETED_AT, in UTC, instead of removing the
+-- row; the purge job removes rows deleted for longer than the retention
+-- period. A deleted customer keeps its email until then.
+ALTER TABLE CUSTOMERS ADD COLUMN DELETED_AT DATETIME NULL;
+CREATE INDEX CUSTOMERS_DELETED ON CUSTOMERS (DELETED_AT);
@@ -0,0 +1,6 @@
+-- fails while a deleted customer shares its email with another customer;
+-- purge or rename one of them first
+ALTER TABLE CUSTOMERS
+    ADD UNIQUE INDEX EMAIL (EMAIL),
+    DROP INDEX CUSTOMERS_LIVE_EMAIL,
+    DROP COLUMN LIVE_EMAIL;
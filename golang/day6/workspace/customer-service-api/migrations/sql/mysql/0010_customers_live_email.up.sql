@@ -0,0 +1,8 @@
+-- only customers that are not deleted need distinct emails, so that a
+-- deleted customer can be created again before it is purged. LIVE_EMAIL is
+-- the email of a customer that is not deleted and NULL otherwise; a UNIQUE
+-- index allows any number of NULLs.
+ALTER TABLE CUSTOMERS
+    ADD COLUMN LIVE_EMAIL varchar(50) AS (IF(DELETED_AT IS NULL, EMAIL, NULL)) STORED,
+    ADD UNIQUE INDEX CUSTOMERS_LIVE_EMAIL (LIVE_EMAIL),
+    DROP INDEX EMAIL;
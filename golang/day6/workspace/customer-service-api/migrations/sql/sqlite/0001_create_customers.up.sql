@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS CUSTOMERS (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    NAME varchar(50) NOT NULL,
+    EMAIL varchar(50) UNIQUE,
+    CITY varchar(50)
+);
@@ -0,0 +1,13 @@
+-- KEY_HASH is the SHA-256 of the whole key in hex; the key itself is only
+-- shown once, when it is created. SCOPES are space separated.
+CREATE TABLE API_KEYS (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    PREFIX varchar(16) NOT NULL,
+    KEY_HASH char(64) NOT NULL UNIQUE,
+    OWNER varchar(100) NOT NULL,
+    SCOPES varchar(255) NOT NULL,
+    RATE_LIMIT INTEGER NOT NULL DEFAULT 0,
+    CREATED_AT DATETIME NOT NULL,
+    EXPIRES_AT DATETIME NULL,
+    REVOKED_AT DATETIME NULL
+);
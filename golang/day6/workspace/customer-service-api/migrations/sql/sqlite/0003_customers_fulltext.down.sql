@@ -0,0 +1,2 @@
+-- SQLite has no FULLTEXT indexes; search falls back to LIKE alone. This
+-- version exists to keep the numbering in step with MySQL.
@@ -0,0 +1,2 @@
+ALTER TABLE CUSTOMERS DROP COLUMN UPDATED_AT;
+ALTER TABLE CUSTOMERS DROP COLUMN VERSION;
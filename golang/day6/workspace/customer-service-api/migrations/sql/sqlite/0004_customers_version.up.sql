@@ -0,0 +1,5 @@
+-- VERSION starts at 1 and goes up by one with every write; the API hands
+-- it out as the ETag of a customer. UPDATED_AT is in UTC; SQLite cannot
+-- add a column defaulting to CURRENT_TIMESTAMP, so it is set on writes.
+ALTER TABLE CUSTOMERS ADD COLUMN VERSION INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE CUSTOMERS ADD COLUMN UPDATED_AT DATETIME NULL;
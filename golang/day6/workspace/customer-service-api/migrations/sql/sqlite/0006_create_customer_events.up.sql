@@ -0,0 +1,16 @@
+-- the transactional outbox: every change to CUSTOMERS adds a row here in
+-- the same transaction. ID is the offset of the event; PAYLOAD is the
+-- customer as JSON, after the change or, for deletions, as it was.
+CREATE TABLE CUSTOMER_EVENTS (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    TYPE varchar(30) NOT NULL,
+    CUSTOMER_ID INTEGER NOT NULL,
+    PAYLOAD TEXT NOT NULL,
+    CREATED_AT DATETIME NOT NULL
+);
+-- how far the relay has delivered the events to each sink
+CREATE TABLE OUTBOX_OFFSETS (
+    SINK varchar(100) PRIMARY KEY,
+    EVENT_ID BIGINT NOT NULL,
+    UPDATED_AT DATETIME NOT NULL
+);
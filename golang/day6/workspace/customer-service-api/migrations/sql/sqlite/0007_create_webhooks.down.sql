@@ -0,0 +1,3 @@
+DROP TABLE WEBHOOK_ATTEMPTS;
+DROP TABLE WEBHOOK_DELIVERIES;
+DROP TABLE WEBHOOK_SUBSCRIPTIONS;
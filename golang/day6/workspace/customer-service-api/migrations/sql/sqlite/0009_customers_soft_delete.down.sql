@@ -0,0 +1,3 @@
+-- customers deleted but not yet purged become visible again
+DROP INDEX CUSTOMERS_DELETED;
+ALTER TABLE CUSTOMERS DROP COLUMN DELETED_AT;
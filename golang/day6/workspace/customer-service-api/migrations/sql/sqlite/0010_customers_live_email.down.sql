@@ -0,0 +1,18 @@
+-- fails while a deleted customer shares its email with another customer;
+-- purge or rename one of them first
+CREATE TABLE CUSTOMERS_OLD (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    NAME varchar(50) NOT NULL,
+    EMAIL varchar(50) UNIQUE,
+    CITY varchar(50),
+    VERSION INTEGER NOT NULL DEFAULT 1,
+    UPDATED_AT DATETIME NULL,
+    DELETED_AT DATETIME NULL
+);
+INSERT INTO CUSTOMERS_OLD (ID, NAME, EMAIL, CITY, VERSION, UPDATED_AT, DELETED_AT)
+    SELECT ID, NAME, EMAIL, CITY, VERSION, UPDATED_AT, DELETED_AT FROM CUSTOMERS;
+DELETE FROM sqlite_sequence WHERE name = 'CUSTOMERS_OLD';
+UPDATE sqlite_sequence SET name = 'CUSTOMERS_OLD' WHERE name = 'CUSTOMERS';
+DROP TABLE CUSTOMERS;
+ALTER TABLE CUSTOMERS_OLD RENAME TO CUSTOMERS;
+CREATE INDEX CUSTOMERS_DELETED ON CUSTOMERS (DELETED_AT);
@@ -0,0 +1,22 @@
+-- only customers that are not deleted need distinct emails, so that a
+-- deleted customer can be created again before it is purged. The UNIQUE
+-- of the EMAIL column can not be dropped in SQLite, so the table is built
+-- again, keeping its AUTOINCREMENT sequence, with a partial unique index
+-- instead.
+CREATE TABLE CUSTOMERS_NEW (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    NAME varchar(50) NOT NULL,
+    EMAIL varchar(50),
+    CITY varchar(50),
+    VERSION INTEGER NOT NULL DEFAULT 1,
+    UPDATED_AT DATETIME NULL,
+    DELETED_AT DATETIME NULL
+);
+INSERT INTO CUSTOMERS_NEW (ID, NAME, EMAIL, CITY, VERSION, UPDATED_AT, DELETED_AT)
+    SELECT ID, NAME, EMAIL, CITY, VERSION, UPDATED_AT, DELETED_AT FROM CUSTOMERS;
+DELETE FROM sqlite_sequence WHERE name = 'CUSTOMERS_NEW';
+UPDATE sqlite_sequence SET name = 'CUSTOMERS_NEW' WHERE name = 'CUSTOMERS';
+DROP TABLE CUSTOMERS;
+ALTER TABLE CUSTOMERS_NEW RENAME TO CUSTOMERS;
+CREATE INDEX CUSTOMERS_DELETED ON CUSTOMERS (DELETED_AT);
+CREATE UNIQUE INDEX CUSTOMERS_LIVE_EMAIL ON CUSTOMERS (EMAIL) WHERE DELETED_AT IS NULL;
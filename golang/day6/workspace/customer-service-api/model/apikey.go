@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// APIKey describes an issued API key. The key itself is never stored;
+// Prefix is its first characters, enough to tell keys apart in listings.
+// RateLimit overrides the configured requests per period when non-zero.
+type APIKey struct {
+	Id        int        `json:"id"`
+	Prefix    string     `json:"prefix"`
+	Owner     string     `json:"owner"`
+	Scopes    []string   `json:"scopes"`
+	RateLimit int        `json:"rateLimit,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Active reports whether the key may be used at now.
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// NewAPIKey is the body of a request to issue an API key.
+type NewAPIKey struct {
+	Owner     string     `json:"owner"`
+	Scopes    []string   `json:"scopes"`
+	RateLimit int        `json:"rateLimit"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// IssuedAPIKey is the response to issuing a key, the only time Key is
+// ever returned.
+type IssuedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type APIKeyList struct {
+	Data []APIKey `json:"data"`
+}
@@ -0,0 +1,68 @@
+package model
+
+import "time"
+
+// Operations of AuditEntry
+const (
+	AuditInsert  = "insert"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+// FieldChange is the value of a field before and after a change; Before
+// is null for an insert and After for a purge.
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditEntry is one write to a customer, as recorded in its history.
+// Actor is who made it, as "key:<id>", "sub:<subject>" or "anonymous";
+// RequestId is the request that made it. Changes holds the fields whose
+// value changed, by their JSON name.
+type AuditEntry struct {
+	Id         int64                  `json:"id"`
+	CustomerId int                    `json:"customerId"`
+	Operation  string                 `json:"operation"`
+	Actor      string                 `json:"actor"`
+	RequestId  string                 `json:"requestId,omitempty"`
+	Changes    map[string]FieldChange `json:"changes"`
+	At         time.Time              `json:"at"`
+}
+
+// CustomerHistory is a page of the history of a customer, oldest first.
+type CustomerHistory struct {
+	Data []AuditEntry `json:"data"`
+}
+
+// Diff returns the fields of a customer that differ between before and
+// after; a nil customer has no fields, so every field counts as changed.
+// The id and UpdatedAt, which every write sets, are left out.
+func Diff(before, after *Customer) map[string]FieldChange {
+	changes := map[string]FieldChange{}
+	field := func(name string, value func(Customer) any) {
+		var b, a any
+		if before != nil {
+			b = value(*before)
+		}
+		if after != nil {
+			a = value(*after)
+		}
+		if b != a {
+			changes[name] = FieldChange{Before: b, After: a}
+		}
+	}
+	field("name", func(c Customer) any { return c.Name })
+	field("city", func(c Customer) any { return c.City })
+	field("email", func(c Customer) any { return c.Email })
+	field("version", func(c Customer) any { return c.Version })
+	field("deletedAt", func(c Customer) any {
+		if c.DeletedAt == nil {
+			return nil
+		}
+		return c.DeletedAt.UTC().Format(time.RFC3339)
+	})
+	return changes
+}
@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// Types of CustomerEvent
+const (
+	CustomerCreated  = "customer.created"
+	CustomerUpdated  = "customer.updated"
+	CustomerDeleted  = "customer.deleted"
+	CustomerRestored = "customer.restored"
+)
+
+// CustomerEvent is one change to a customer, as recorded in the outbox.
+// Id is its offset in the outbox; ids go up over time, though concurrent
+// writes can commit out of id order. Customer is the customer after the
+// change or, when it was deleted, as it was.
+type CustomerEvent struct {
+	Id         int64     `json:"id"`
+	Type       string    `json:"type"`
+	CustomerId int       `json:"customerId"`
+	Customer   Customer  `json:"customer"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+// CustomerEventList is a page of the outbox, as listed to admins.
+type CustomerEventList struct {
+	Data []CustomerEvent `json:"data"`
+}
+
+// OutboxOffset is how far the relay has delivered the outbox to a sink:
+// the id of the last event it published.
+type OutboxOffset struct {
+	Sink   string `json:"sink"`
+	Offset int64  `json:"offset"`
+}
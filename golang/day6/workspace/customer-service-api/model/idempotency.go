@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// IdempotentRequest is a request sent with an Idempotency-Key, together
+// with the response it got. Keys are only unique within Scope, the caller
+// that sent them. Status is 0 while the first request is being served;
+// Header holds the response headers worth replaying.
+type IdempotentRequest struct {
+	Scope       string
+	Key         string
+	Fingerprint string
+	Status      int
+	Header      map[string]string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// Completed reports whether the response has been stored.
+func (r IdempotentRequest) Completed() bool {
+	return r.Status != 0
+}
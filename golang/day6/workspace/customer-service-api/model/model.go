@@ -1,12 +1,117 @@
 package model
 
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Customer is one row of CUSTOMERS. Version starts at 1 and goes up with
+// every change; it is what the ETag of a customer is made of, and it and
+// UpdatedAt are set by the repository, never by the client. DeletedAt is
+// set on customers that were deleted and can still be restored; the API
+// does not show them otherwise.
 type Customer struct {
-	Id    int    `json:"id"`
-	Name  string `json:"name"`
-	City  string `json:"city"`
-	Email string `json:"email"`
+	Id        int        `json:"id" xml:"id,attr"`
+	Name      string     `json:"name" xml:"name"`
+	City      string     `json:"city" xml:"city"`
+	Email     string     `json:"email" xml:"email"`
+	Version   int        `json:"version" xml:"version,attr"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty" xml:"updatedAt,omitempty"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty" xml:"deletedAt,omitempty"`
+}
+
+// CustomerPatch carries the fields of a JSON merge-patch; a nil field
+// means "leave unchanged".
+type CustomerPatch struct {
+	Name  *string `json:"name"`
+	City  *string `json:"city"`
+	Email *string `json:"email"`
 }
 
+// ErrorMessage is the body of every error response. Code is a stable,
+// machine readable identifier such as "not_found" or "duplicate_email";
+// Errors lists the offending fields when Code is "validation_failed".
 type ErrorMessage struct {
+	Code    string       `json:"code,omitempty"`
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// CustomerQuery describes the filtering, sorting and paging applied when
+// listing customers. After, when non-zero, selects keyset (cursor) paging
+// on ID instead of Offset.
+type CustomerQuery struct {
+	City   string
+	Sort   string
+	Order  string
+	Limit  int
+	Offset int
+	After  int
+}
+
+// CustomerPage is the response envelope for a listing of customers.
+type CustomerPage struct {
+	XMLName xml.Name   `json:"-" xml:"customers"`
+	Data    []Customer `json:"data" xml:"customer"`
+	Total   int        `json:"total" xml:"total,attr"`
+	Limit   int        `json:"limit" xml:"limit,attr"`
+	Offset  int        `json:"offset,omitempty" xml:"offset,attr,omitempty"`
+	Links   PageLinks  `json:"links" xml:"links"`
+}
+
+type PageLinks struct {
+	Self string `json:"self" xml:"self"`
+	Next string `json:"next,omitempty" xml:"next,omitempty"`
+	Prev string `json:"prev,omitempty" xml:"prev,omitempty"`
+}
+
+// SearchQuery is a free text search for customers. Q is matched, case
+// insensitively, as a substring of name, email and city.
+type SearchQuery struct {
+	Q     string
+	Limit int
+}
+
+// SearchResult is a matching customer with its relevance score, higher
+// being better, and its matching fields with every match of the query
+// wrapped in <mark></mark>. Highlighted values are HTML-escaped.
+type SearchResult struct {
+	Customer
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+type SearchResults struct {
+	Query string         `json:"query"`
+	Data  []SearchResult `json:"data"`
+}
+
+// ImportReport is the outcome of a bulk import. Rows are numbered from 1
+// in the order received, not counting a CSV header. Errors is capped;
+// Failed counts every rejected row.
+type ImportReport struct {
+	Mode      string        `json:"mode"`
+	Received  int           `json:"received"`
+	Imported  int           `json:"imported"`
+	Failed    int           `json:"failed"`
+	Errors    []ImportError `json:"errors"`
+	Truncated bool          `json:"truncated,omitempty"`
+}
+
+type ImportError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
 	Message string `json:"message"`
 }
+
+// PurgeReport is the outcome of a run of the purge job: how many
+// customers deleted before DeletedBefore were removed for good.
+type PurgeReport struct {
+	Purged        int       `json:"purged"`
+	DeletedBefore time.Time `json:"deletedBefore"`
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// Statuses of a WebhookDelivery
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+)
+
+// CustomerEventTypes are the types of CustomerEvent, in the order they are
+// listed to clients.
+var CustomerEventTypes = []string{CustomerCreated, CustomerUpdated, CustomerDeleted, CustomerRestored}
+
+// WebhookSubscription is a URL that customer events of the listed types
+// are POSTed to. The secret signing them is never returned.
+type WebhookSubscription struct {
+	Id        int       `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Wants reports whether events of eventType go to the subscription.
+func (s WebhookSubscription) Wants(eventType string) bool {
+	return slices.Contains(s.Events, eventType)
+}
+
+// NewWebhookSubscription is the body of a request to subscribe to events.
+type NewWebhookSubscription struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+type WebhookSubscriptionList struct {
+	Data []WebhookSubscription `json:"data"`
+}
+
+// WebhookDelivery is one event on its way to one subscription. A pending
+// delivery is tried at NextAttemptAt; after too many failed attempts it is
+// dead, until it is redelivered. Attempts are only filled in for the
+// delivery history.
+type WebhookDelivery struct {
+	Id             int64            `json:"id"`
+	SubscriptionId int              `json:"subscriptionId"`
+	EventId        int64            `json:"eventId"`
+	EventType      string           `json:"eventType"`
+	Payload        []byte           `json:"-"`
+	Status         string           `json:"status"`
+	AttemptCount   int              `json:"attemptCount"`
+	NextAttemptAt  *time.Time       `json:"nextAttemptAt,omitempty"`
+	LastError      string           `json:"lastError,omitempty"`
+	CreatedAt      time.Time        `json:"createdAt"`
+	UpdatedAt      time.Time        `json:"updatedAt"`
+	Attempts       []WebhookAttempt `json:"attempts,omitempty"`
+}
+
+// WebhookAttempt is one POST of a delivery: the status the receiver
+// answered with, or the error when it did not answer.
+type WebhookAttempt struct {
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"statusCode,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int64     `json:"durationMs"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+}
+
+type WebhookDeliveryList struct {
+	Data []WebhookDelivery `json:"data"`
+}
@@ -0,0 +1,39 @@
+// Package openapi serves the OpenAPI 3.1 description of the service and a
+// Swagger UI page to browse it.
+package openapi
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// Spec returns the OpenAPI document.
+func Spec() []byte {
+	return spec
+}
+
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	})
+}
+
+//go:embed docs.html
+var docsPage string
+
+var docsTemplate = template.Must(template.New("docs").Parse(docsPage))
+
+// DocsHandler serves Swagger UI pointed at the document at specUrl. The
+// page is embedded in the binary; the Swagger UI scripts and styles it
+// loads come from the swagger-ui-dist package on unpkg.
+func DocsHandler(specUrl string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		docsTemplate.Execute(w, struct{ SpecUrl string }{specUrl})
+	})
+}
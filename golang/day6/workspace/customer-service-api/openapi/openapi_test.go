@@ -0,0 +1,15 @@
+package openapi
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDocsHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	DocsHandler("/api/openapi.json").ServeHTTP(w, httptest.NewRequest("GET", "/docs", nil))
+	if !strings.Contains(w.Body.String(), `url: "/api/openapi.json"`) {
+		t.Errorf("wanted the page to load /api/openapi.json, got\n%s", w.Body.String())
+	}
+}
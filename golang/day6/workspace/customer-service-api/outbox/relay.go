@@ -0,0 +1,209 @@
+package outbox
+
+import (
+	"api/dao"
+	"api/metrics"
+	"api/model"
+	"api/settings"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// the longest a failing sink waits between attempts
+const maxBackoff = time.Minute
+
+// Relay delivers the events of the outbox to one sink, in id order, and
+// stores how far it got as the offset of the sink. The offset is stored
+// after the events are published, so a crash in between publishes them
+// again. Setting the offset of the sink back, while the relay runs or not,
+// makes it publish the events after that offset again.
+//
+// Ids are taken when a write starts and become visible when it commits,
+// so for a while after a slow write a later event can be there without
+// the earlier one; a write that is rolled back leaves its id missing for
+// good. The relay holds the events after a missing id back for up to
+// GapTimeout, then publishes them and keeps looking for the missing id,
+// publishing its event late if it shows up within GapRetention. The
+// stored offset stays below the ids still looked for, so that a restart
+// publishes the events after them again rather than lose theirs.
+type Relay struct {
+	events       dao.CustomerEventRepository
+	sink         Sink
+	pollInterval time.Duration
+	batchSize    int
+	gapTimeout   time.Duration
+	gapRetention time.Duration
+	now          func() time.Time
+
+	// the offset as last read or stored; a different one in the store has
+	// been set by an admin
+	stored int64
+	loaded bool
+	// the id of the last event published in order
+	position int64
+	// the ids found missing, with when they were
+	missing map[int64]time.Time
+}
+
+func NewRelay(events dao.CustomerEventRepository, sink Sink, config settings.Outbox) *Relay {
+	return &Relay{
+		events:       events,
+		sink:         sink,
+		pollInterval: config.PollInterval,
+		batchSize:    config.BatchSize,
+		gapTimeout:   config.GapTimeout,
+		gapRetention: config.GapRetention,
+		now:          time.Now,
+		missing:      map[int64]time.Time{},
+	}
+}
+
+// Run delivers events until ctx is done. A failure is retried with the
+// poll interval doubling up to maxBackoff.
+func (r *Relay) Run(ctx context.Context) {
+	logger := slog.Default().With("sink", r.sink.Name())
+	backoff := r.pollInterval
+	for {
+		delivered, err := r.deliver(ctx)
+		wait := r.pollInterval
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			logger.Warn("publishing customer events", "error", err, "retry_in", backoff.String())
+			wait = backoff
+			backoff = min(2*backoff, maxBackoff)
+		case delivered == r.batchSize:
+			// there may be more waiting
+			wait = 0
+			backoff = r.pollInterval
+		default:
+			backoff = r.pollInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// deliver publishes the events that turned up late and the next batch,
+// up to the first failure, and moves the offset past the ones published,
+// returning how many were.
+func (r *Relay) deliver(ctx context.Context) (int, error) {
+	name := r.sink.Name()
+	offset, err := r.events.Offset(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	if !r.loaded || offset != r.stored {
+		r.loaded, r.stored, r.position = true, offset, offset
+		clear(r.missing)
+	}
+
+	delivered, err := r.deliverLate(ctx)
+	if err == nil {
+		var n int
+		n, err = r.deliverNext(ctx)
+		delivered += n
+	}
+
+	offset = r.position
+	for id := range r.missing {
+		offset = min(offset, id-1)
+	}
+	if offset != r.stored {
+		if setErr := r.events.SetOffset(context.WithoutCancel(ctx), name, offset); setErr != nil {
+			return delivered, errors.Join(err, setErr)
+		}
+		r.stored = offset
+	}
+	return delivered, err
+}
+
+// deliverLate publishes the events of the missing ids that have shown up
+// since, and gives up on those missing for longer than gapRetention.
+func (r *Relay) deliverLate(ctx context.Context) (int, error) {
+	ids := make([]int64, 0, len(r.missing))
+	for id := range r.missing {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	delivered := 0
+	for _, id := range ids {
+		if id > r.position {
+			break // not passed yet
+		}
+		events, err := r.events.EventsAfter(ctx, id-1, 1)
+		if err != nil {
+			return delivered, err
+		}
+		if len(events) == 0 || events[0].Id != id {
+			if r.now().Sub(r.missing[id]) >= r.gapRetention {
+				slog.Warn("giving up on a missing customer event", "sink", r.sink.Name(), "id", id)
+				delete(r.missing, id)
+			}
+			continue
+		}
+		if err := r.publish(ctx, events[0]); err != nil {
+			return delivered, err
+		}
+		delete(r.missing, id)
+		delivered++
+	}
+	return delivered, nil
+}
+
+// deliverNext publishes the events after the position, waiting on the ids
+// missing in between for up to gapTimeout.
+func (r *Relay) deliverNext(ctx context.Context) (int, error) {
+	events, err := r.events.EventsAfter(ctx, r.position, r.batchSize)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, event := range events {
+		if !r.passGap(event.Id) {
+			break
+		}
+		if err := r.publish(ctx, event); err != nil {
+			return delivered, err
+		}
+		r.position = event.Id
+		delete(r.missing, event.Id) // when it was waited on
+		delivered++
+	}
+	return delivered, nil
+}
+
+// passGap records the ids between the position and next as missing and
+// reports whether they have all been for gapTimeout, if any.
+func (r *Relay) passGap(next int64) bool {
+	now := r.now()
+	pass := true
+	for id := r.position + 1; id < next; id++ {
+		since, ok := r.missing[id]
+		if !ok {
+			since = now
+			r.missing[id] = now
+		}
+		if now.Sub(since) < r.gapTimeout {
+			pass = false
+		}
+	}
+	return pass
+}
+
+func (r *Relay) publish(ctx context.Context, event model.CustomerEvent) error {
+	if err := r.sink.Publish(ctx, event); err != nil {
+		metrics.OutboxFailures.With(r.sink.Name()).Inc()
+		return err
+	}
+	metrics.OutboxPublished.With(r.sink.Name()).Inc()
+	return nil
+}
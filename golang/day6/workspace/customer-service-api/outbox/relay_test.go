@@ -0,0 +1,212 @@
+package outbox
+
+import (
+	"api/dao"
+	"api/model"
+	"api/settings"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// failingSink fails to publish the events in fail, once each.
+type failingSink struct {
+	*ChannelSink
+	fail map[int64]bool
+}
+
+func (s failingSink) Publish(ctx context.Context, event model.CustomerEvent) error {
+	if s.fail[event.Id] {
+		delete(s.fail, event.Id)
+		return errors.New("sink unavailable")
+	}
+	return s.ChannelSink.Publish(ctx, event)
+}
+
+// received drains the events published to sink so far, by id.
+func received(sink *ChannelSink) []int64 {
+	ids := []int64{}
+	for {
+		select {
+		case e := <-sink.Events():
+			ids = append(ids, e.Id)
+		default:
+			return ids
+		}
+	}
+}
+
+func equalIds(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func newCustomers(t *testing.T, count int) *dao.MemoryCustomerRepository {
+	repo := dao.NewMemoryCustomerRepository()
+	for i := 0; i < count; i++ {
+		if _, err := repo.Save(context.Background(), model.Customer{Name: "Vinod", Email: string(rune('a'+i)) + "@xmpl.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+	events := newCustomers(t, 3).Events()
+	sink := failingSink{NewChannelSink("test", 10), map[int64]bool{2: true}}
+	relay := NewRelay(events, sink, settings.Outbox{PollInterval: time.Millisecond, BatchSize: 10})
+
+	steps := []struct {
+		name      string
+		delivered []int64
+		offset    int64
+		fails     bool
+	}{
+		// stops at the failure, keeping what was published before it
+		{"failure", []int64{1}, 1, true},
+		{"retry", []int64{2, 3}, 3, false},
+		{"nothing new", []int64{}, 3, false},
+	}
+	for _, step := range steps {
+		_, err := relay.deliver(ctx)
+		if (err != nil) != step.fails {
+			t.Errorf("%s: wanted failing %v, got %v", step.name, step.fails, err)
+		}
+		if ids := received(sink.ChannelSink); !equalIds(ids, step.delivered) {
+			t.Errorf("%s: wanted %v, got %v", step.name, step.delivered, ids)
+		}
+		if offset, _ := events.Offset(ctx, "test"); offset != step.offset {
+			t.Errorf("%s: wanted offset %v, got %v", step.name, step.offset, offset)
+		}
+	}
+
+	// replay from an offset
+	events.SetOffset(ctx, "test", 1)
+	if delivered, err := relay.deliver(ctx); err != nil || delivered != 2 {
+		t.Errorf("wanted 2 delivered, got %v (%v)", delivered, err)
+	}
+	if ids := received(sink.ChannelSink); !equalIds(ids, []int64{2, 3}) {
+		t.Errorf("wanted [2 3], got %v", ids)
+	}
+}
+
+// gappyEvents is an outbox whose events with the ids in missing have not
+// been committed.
+type gappyEvents struct {
+	dao.CustomerEventRepository
+	missing map[int64]bool
+}
+
+func (g gappyEvents) EventsAfter(ctx context.Context, after int64, limit int) ([]model.CustomerEvent, error) {
+	all, err := g.CustomerEventRepository.EventsAfter(ctx, after, 100)
+	events := []model.CustomerEvent{}
+	for _, e := range all {
+		if !g.missing[e.Id] && len(events) < limit {
+			events = append(events, e)
+		}
+	}
+	return events, err
+}
+
+func TestRelayGaps(t *testing.T) {
+	ctx := context.Background()
+	events := gappyEvents{newCustomers(t, 5).Events(), map[int64]bool{2: true, 4: true}}
+	sink := NewChannelSink("test", 10)
+	relay := NewRelay(events, sink, settings.Outbox{
+		PollInterval: time.Millisecond, BatchSize: 10, GapTimeout: 5 * time.Second, GapRetention: time.Hour,
+	})
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	relay.now = func() time.Time { return now }
+
+	steps := []struct {
+		name      string
+		after     time.Duration
+		commit    int64
+		delivered []int64
+		offset    int64
+	}{
+		{"waits on 2", 0, 0, []int64{1}, 1},
+		{"still waiting", 4 * time.Second, 0, []int64{}, 1},
+		// both gaps are old enough by the time 4 is found missing
+		{"moves past 2", time.Second, 0, []int64{3}, 1},
+		{"moves past 4", 5 * time.Second, 0, []int64{5}, 1},
+		// a slow write commits after its id was passed
+		{"2 commits late", time.Minute, 2, []int64{2}, 3},
+		{"nothing new", time.Minute, 0, []int64{}, 3},
+		// a rolled back write is given up on
+		{"4 given up", time.Hour, 0, []int64{}, 5},
+		{"too late for 4", time.Minute, 4, []int64{}, 5},
+	}
+	for _, step := range steps {
+		now = now.Add(step.after)
+		delete(events.missing, step.commit)
+		if _, err := relay.deliver(ctx); err != nil {
+			t.Fatalf("%s: was not expecting an error, got %v", step.name, err)
+		}
+		if ids := received(sink); !equalIds(ids, step.delivered) {
+			t.Errorf("%s: wanted %v, got %v", step.name, step.delivered, ids)
+		}
+		if offset, _ := events.Offset(ctx, "test"); offset != step.offset {
+			t.Errorf("%s: wanted offset %v, got %v", step.name, step.offset, offset)
+		}
+	}
+}
+
+func TestRelayRestartsBelowGaps(t *testing.T) {
+	ctx := context.Background()
+	events := gappyEvents{newCustomers(t, 3).Events(), map[int64]bool{2: true}}
+	sink := NewChannelSink("test", 10)
+	config := settings.Outbox{PollInterval: time.Millisecond, BatchSize: 10, GapRetention: time.Hour}
+	NewRelay(events, sink, config).deliver(ctx)
+	if ids := received(sink); !equalIds(ids, []int64{1, 3}) {
+		t.Errorf("wanted [1 3], got %v", ids)
+	}
+
+	// 2 commits while no relay runs: the next one publishes it, and 3 again
+	delete(events.missing, 2)
+	NewRelay(events, sink, config).deliver(ctx)
+	if ids := received(sink); !equalIds(ids, []int64{2, 3}) {
+		t.Errorf("wanted [2 3], got %v", ids)
+	}
+	if offset, _ := events.Offset(ctx, "test"); offset != 3 {
+		t.Errorf("wanted offset 3, got %v", offset)
+	}
+}
+
+func TestRelayRun(t *testing.T) {
+	repo := newCustomers(t, 0)
+	sink := NewChannelSink("test", 0)
+	relay := NewRelay(repo.Events(), sink, settings.Outbox{PollInterval: time.Millisecond, BatchSize: 10})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+
+	id, _ := repo.Save(ctx, model.Customer{Name: "Vinod", Email: "vinod@vinod.co"})
+	select {
+	case e := <-sink.Events():
+		if e.Type != model.CustomerCreated || e.CustomerId != id {
+			t.Errorf("wanted the created customer %d, got %+v", id, e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event was published")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
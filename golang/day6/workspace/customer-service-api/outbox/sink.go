@@ -0,0 +1,147 @@
+// Package outbox relays the customer events of the outbox to sinks, the
+// places downstream services learn about customer changes from. Delivery
+// is at least once: an event may reach a sink again after a failure or a
+// restart, so consumers should ignore event ids they have already seen.
+package outbox
+
+import (
+	"api/model"
+	"api/settings"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Sink is somewhere events are published to. Publish returns once the
+// event is safely delivered; an error makes the relay try it again.
+type Sink interface {
+	// names the sink in the stored offsets, so it must stay the same
+	// across restarts
+	Name() string
+
+	Publish(ctx context.Context, event model.CustomerEvent) error
+}
+
+// NewSinks returns the sinks listed in config.Sinks.
+func NewSinks(config settings.Outbox) ([]Sink, error) {
+	sinks := []Sink{}
+	for _, name := range config.Sinks {
+		switch name {
+		case "log":
+			sinks = append(sinks, LogSink{})
+		case "file":
+			sinks = append(sinks, FileSink{Filename: config.File})
+		case "webhook":
+			sinks = append(sinks, NewWebhookSink(config.WebhookURL))
+		default:
+			return nil, fmt.Errorf("outbox: unknown sink %q", name)
+		}
+	}
+	return sinks, nil
+}
+
+// LogSink writes events to the application log.
+type LogSink struct{}
+
+func (LogSink) Name() string { return "log" }
+
+func (LogSink) Publish(ctx context.Context, event model.CustomerEvent) error {
+	slog.Info("customer event", "event_id", event.Id, "type", event.Type, "customer_id", event.CustomerId)
+	return nil
+}
+
+// FileSink appends events to Filename as JSON lines.
+type FileSink struct {
+	Filename string
+}
+
+func (FileSink) Name() string { return "file" }
+
+func (s FileSink) Publish(ctx context.Context, event model.CustomerEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.Filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WebhookSink POSTs every event as JSON to URL; any status but 2xx is a
+// failure. The X-Event-Id header lets the receiver drop redeliveries.
+type WebhookSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookSink(url string) WebhookSink {
+	return WebhookSink{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (WebhookSink) Name() string { return "webhook" }
+
+func (s WebhookSink) Publish(ctx context.Context, event model.CustomerEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Id", strconv.FormatInt(event.Id, 10))
+	req.Header.Set("X-Event-Type", event.Type)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("outbox: webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// ChannelSink hands events to a consumer in the same process. Publish
+// waits for the consumer to take the event, so a slow consumer holds the
+// relay of this sink back rather than losing events.
+type ChannelSink struct {
+	name   string
+	events chan model.CustomerEvent
+}
+
+// NewChannelSink returns a sink named name whose channel holds up to
+// buffer events.
+func NewChannelSink(name string, buffer int) *ChannelSink {
+	return &ChannelSink{name: name, events: make(chan model.CustomerEvent, buffer)}
+}
+
+func (s *ChannelSink) Name() string { return s.name }
+
+// Events is where the events arrive.
+func (s *ChannelSink) Events() <-chan model.CustomerEvent { return s.events }
+
+func (s *ChannelSink) Publish(ctx context.Context, event model.CustomerEvent) error {
+	select {
+	case s.events <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
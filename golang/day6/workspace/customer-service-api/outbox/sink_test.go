@@ -0,0 +1,67 @@
+package outbox
+
+import (
+	"api/model"
+	"api/settings"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSink(t *testing.T) {
+	sink := FileSink{Filename: filepath.Join(t.TempDir(), "events.ndjson")}
+	for id := int64(1); id <= 2; id++ {
+		if err := sink.Publish(context.Background(), model.CustomerEvent{Id: id, Type: model.CustomerCreated}); err != nil {
+			t.Fatalf("was not expecting an error, got %v", err)
+		}
+	}
+	b, err := os.ReadFile(sink.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], `{"id":2,"type":"customer.created"`) {
+		t.Errorf("wanted two JSON lines, got %q", b)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	status := http.StatusNoContent
+	var got model.CustomerEvent
+	var eventId string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		eventId = r.Header.Get("X-Event-Id")
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL)
+	event := model.CustomerEvent{Id: 7, Type: model.CustomerUpdated, CustomerId: 3}
+	if err := sink.Publish(context.Background(), event); err != nil {
+		t.Fatalf("was not expecting an error, got %v", err)
+	}
+	if eventId != "7" || got.Type != model.CustomerUpdated || got.CustomerId != 3 {
+		t.Errorf("wanted event 7, got %v %+v", eventId, got)
+	}
+
+	status = http.StatusInternalServerError
+	if err := sink.Publish(context.Background(), event); err == nil {
+		t.Error("was expecting an error; did not get one")
+	}
+}
+
+func TestNewSinks(t *testing.T) {
+	sinks, err := NewSinks(settings.Outbox{Sinks: []string{"log", "file", "webhook"}, File: "e.ndjson", WebhookURL: "http://x"})
+	if err != nil || len(sinks) != 3 || sinks[2].Name() != "webhook" {
+		t.Errorf("wanted three sinks, got %v (%v)", sinks, err)
+	}
+	if _, err := NewSinks(settings.Outbox{Sinks: []string{"kafka"}}); err == nil {
+		t.Error("was expecting an error; did not get one")
+	}
+}
@@ -0,0 +1,66 @@
+// Package purge removes for good the customers that have been deleted for
+// longer than the retention period.
+package purge
+
+import (
+	"api/auth"
+	"api/dao"
+	"api/metrics"
+	"api/model"
+	"api/settings"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Actor is who the audit trail records the scheduled purges as being
+// made by.
+const Actor = "system:purge"
+
+// Job purges the customers of a repository that were deleted more than
+// the retention period ago.
+type Job struct {
+	repo      dao.CustomerRepository
+	retention time.Duration
+	interval  time.Duration
+	now       func() time.Time
+}
+
+func NewJob(repo dao.CustomerRepository, config settings.Purge) *Job {
+	return &Job{repo: repo, retention: config.Retention, interval: config.Interval, now: time.Now}
+}
+
+// Run purges every interval until ctx is done; a failed run is tried
+// again at the next interval.
+func (j *Job) Run(ctx context.Context) {
+	ctx = auth.WithActor(ctx, Actor)
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		report, err := j.Purge(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			slog.Warn("purging deleted customers", "error", err)
+		case report.Purged > 0:
+			slog.Info("purged deleted customers", "purged", report.Purged, "deleted_before", report.DeletedBefore)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge removes the customers deleted before the retention period now.
+// The audit trail records the caller in ctx as having purged them.
+func (j *Job) Purge(ctx context.Context) (model.PurgeReport, error) {
+	report := model.PurgeReport{DeletedBefore: j.now().UTC().Add(-j.retention).Truncate(time.Second)}
+	purged, err := j.repo.Purge(ctx, report.DeletedBefore)
+	report.Purged = purged
+	metrics.CustomersPurged.Add(float64(purged))
+	return report, err
+}
@@ -0,0 +1,60 @@
+package purge
+
+import (
+	"api/dao"
+	"api/model"
+	"api/settings"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestJob(t *testing.T) {
+	ctx := context.Background()
+	repo := dao.NewMemoryCustomerRepository()
+	for _, email := range []string{"vinod@vinod.co", "shyam@xmpl.com", "anil@xmpl.com"} {
+		repo.Save(ctx, model.Customer{Name: "Vinod", Email: email})
+	}
+	repo.Delete(ctx, 1, 0)
+	repo.Delete(ctx, 2, 0)
+	job := NewJob(repo, settings.Purge{Retention: 24 * time.Hour, Interval: time.Millisecond})
+
+	// still within the retention period
+	report, err := job.Purge(ctx)
+	if err != nil || report.Purged != 0 {
+		t.Errorf("wanted nothing purged, got %+v (%v)", report, err)
+	}
+	if _, err := repo.Restore(ctx, 2); err != nil {
+		t.Fatalf("was not expecting an error, got %v", err)
+	}
+
+	job.now = func() time.Time { return time.Now().Add(25 * time.Hour) }
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		job.Run(runCtx)
+		close(done)
+	}()
+	purged := func() bool {
+		history, _ := repo.Audit().History(ctx, 1, 0, 10)
+		return history[len(history)-1].Operation == model.AuditPurge
+	}
+	for deadline := time.Now().Add(time.Second); !purged() && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	for _, id := range []int{2, 3} {
+		if _, err := repo.FindById(ctx, id); err != nil {
+			t.Errorf("customer %d: was not expecting an error, got %v", id, err)
+		}
+	}
+	if _, err := repo.Restore(ctx, 1); err != dao.ErrNotFound {
+		t.Errorf("wanted %v, got %v", dao.ErrNotFound, err)
+	}
+	history, _ := repo.Audit().History(ctx, 1, 0, 10)
+	if last := history[len(history)-1]; last.Operation != model.AuditPurge || last.Actor != Actor {
+		t.Errorf("wanted a purge by %v, got %+v", Actor, last)
+	}
+}
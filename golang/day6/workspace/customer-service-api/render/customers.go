@@ -0,0 +1,102 @@
+package render
+
+import (
+	"api/model"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+)
+
+var csvHeader = []string{"id", "name", "city", "email"}
+
+func csvRecord(c model.Customer) []string {
+	return []string{strconv.Itoa(c.Id), c.Name, c.City, c.Email}
+}
+
+// Customer writes one customer with the given status in the media type
+// negotiated for r.
+func Customer(w http.ResponseWriter, r *http.Request, status int, c model.Customer) {
+	mediaType := MediaType(r)
+	w.Header().Set("Content-Type", ContentType(mediaType))
+	w.WriteHeader(status)
+
+	switch mediaType {
+	case CSV:
+		cw := csv.NewWriter(w)
+		cw.Write(csvHeader)
+		cw.Write(csvRecord(c))
+		cw.Flush()
+	case XML:
+		w.Write([]byte(xml.Header))
+		xml.NewEncoder(w).EncodeElement(c, xml.StartElement{Name: xml.Name{Local: "customer"}})
+	default: // JSON and NDJSON look the same for a single value
+		json.NewEncoder(w).Encode(c)
+	}
+}
+
+// CustomerPage writes a page of customers in the media type negotiated for
+// r. JSON and XML carry the paging information in the body; CSV and NDJSON
+// only hold customers, so it goes into X-Total-Count and Link headers.
+func CustomerPage(w http.ResponseWriter, r *http.Request, page model.CustomerPage) {
+	mediaType := MediaType(r)
+	w.Header().Set("Content-Type", ContentType(mediaType))
+
+	switch mediaType {
+	case CSV:
+		setPageHeaders(w, page)
+		cw := csv.NewWriter(w)
+		cw.Write(csvHeader)
+		for _, c := range page.Data {
+			cw.Write(csvRecord(c))
+		}
+		cw.Flush()
+	case NDJSON:
+		setPageHeaders(w, page)
+		encoder := json.NewEncoder(w)
+		for _, c := range page.Data {
+			encoder.Encode(c)
+		}
+	case XML:
+		w.Write([]byte(xml.Header))
+		xml.NewEncoder(w).Encode(page)
+	default:
+		json.NewEncoder(w).Encode(page)
+	}
+}
+
+func setPageHeaders(w http.ResponseWriter, page model.CustomerPage) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.Links.Next != "" {
+		w.Header().Add("Link", `<`+page.Links.Next+`>; rel="next"`)
+	}
+	if page.Links.Prev != "" {
+		w.Header().Add("Link", `<`+page.Links.Prev+`>; rel="prev"`)
+	}
+}
+
+// ContentType is the Content-Type header for a negotiated media type.
+func ContentType(mediaType string) string {
+	switch mediaType {
+	case CSV, XML, JSON:
+		return mediaType + "; charset=utf-8"
+	}
+	return mediaType
+}
+
+// SearchResults writes search results as JSON or, one result per line, as
+// NDJSON; callers answer 406 for the other media types.
+func SearchResults(w http.ResponseWriter, r *http.Request, results model.SearchResults) {
+	mediaType := MediaType(r)
+	w.Header().Set("Content-Type", ContentType(mediaType))
+
+	if mediaType == NDJSON {
+		encoder := json.NewEncoder(w)
+		for _, result := range results.Data {
+			encoder.Encode(result)
+		}
+		return
+	}
+	json.NewEncoder(w).Encode(results)
+}
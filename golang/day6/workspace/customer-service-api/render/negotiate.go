@@ -0,0 +1,122 @@
+// Package render picks the representation of a response from the Accept
+// header and writes customers in it.
+package render
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	JSON   = "application/json"
+	CSV    = "text/csv"
+	XML    = "application/xml"
+	NDJSON = "application/x-ndjson"
+)
+
+// Supported lists the media types customers can be rendered as, in order
+// of preference when the client likes several equally.
+var Supported = []string{JSON, CSV, XML, NDJSON}
+
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept parses an Accept header (RFC 7231, section 5.3.2). Ranges
+// with a malformed media type or q-value are skipped. An empty header
+// accepts anything.
+func parseAccept(header string) []mediaRange {
+	if strings.TrimSpace(header) == "" {
+		return []mediaRange{{"*", "*", 1}}
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+		if !ok || typ == "" || subtype == "" || typ == "*" && subtype != "*" {
+			continue
+		}
+
+		r := mediaRange{typ: typ, subtype: subtype, q: 1}
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.ToLower(strings.TrimSpace(name)) != "q" {
+				continue // e.g. charset=utf-8 does not affect the choice
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				ok = false
+				break
+			}
+			r.q = q
+		}
+		if ok {
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges
+}
+
+// Negotiate returns the media type in supported that the Accept header
+// prefers, or false when none is acceptable. For each candidate the most
+// specific matching range decides its q-value; ties go to the earlier
+// entry of supported.
+func Negotiate(accept string, supported []string) (string, bool) {
+	ranges := parseAccept(accept)
+
+	type candidate struct {
+		mediaType string
+		q         float64
+		index     int
+	}
+	var candidates []candidate
+	for i, mediaType := range supported {
+		typ, subtype, _ := strings.Cut(mediaType, "/")
+		specificity, q := -1, 0.0
+		for _, r := range ranges {
+			s := -1
+			switch {
+			case r.typ == typ && r.subtype == subtype:
+				s = 2
+			case r.typ == typ && r.subtype == "*":
+				s = 1
+			case r.typ == "*":
+				s = 0
+			}
+			if s > specificity {
+				specificity, q = s, r.q
+			}
+		}
+		if specificity >= 0 && q > 0 {
+			candidates = append(candidates, candidate{mediaType, q, i})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	return candidates[0].mediaType, true
+}
+
+type contextKey struct{}
+
+// WithMediaType records the negotiated media type on the request.
+func WithMediaType(r *http.Request, mediaType string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), contextKey{}, mediaType))
+}
+
+// MediaType returns the media type negotiated for r, JSON if none was.
+func MediaType(r *http.Request) string {
+	if mediaType, ok := r.Context().Value(contextKey{}).(string); ok {
+		return mediaType
+	}
+	return JSON
+}
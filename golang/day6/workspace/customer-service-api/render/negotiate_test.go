@@ -0,0 +1,35 @@
+package render
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	subtests := []struct {
+		name   string
+		accept string
+		want   string
+		ok     bool
+	}{
+		{"no header", "", JSON, true},
+		{"exact json", "application/json", JSON, true},
+		{"json with charset", "application/json; charset=utf-8", JSON, true},
+		{"anything", "*/*", JSON, true},
+		{"csv", "text/csv", CSV, true},
+		{"type wildcard", "text/*", CSV, true},
+		{"q-values", "application/json;q=0.5, application/xml;q=0.9", XML, true},
+		{"browser default", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", XML, true},
+		{"most specific range wins", "*/*;q=0.1, application/json;q=0", CSV, true},
+		{"ndjson", "application/x-ndjson", NDJSON, true},
+		{"case insensitive", "Application/JSON", JSON, true},
+		{"malformed q skipped", "text/csv;q=abc, application/xml", XML, true},
+		{"not acceptable", "text/html", "", false},
+		{"explicitly refused", "application/json;q=0", "", false},
+	}
+	for _, st := range subtests {
+		t.Run(st.name, func(t *testing.T) {
+			got, ok := Negotiate(st.accept, Supported)
+			if ok != st.ok || got != st.want {
+				t.Errorf("wanted (%v, %v), got (%v, %v)", st.want, st.ok, got, ok)
+			}
+		})
+	}
+}
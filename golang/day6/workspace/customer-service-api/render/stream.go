@@ -0,0 +1,119 @@
+package render
+
+import (
+	"api/model"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+)
+
+// CustomerStream writes a list of customers one at a time, for lists too
+// long to hold in memory: a JSON array, NDJSON, CSV with a header row or
+// an XML <customers> document.
+type CustomerStream struct {
+	w         io.Writer
+	mediaType string
+	count     int
+	csv       *csv.Writer
+	json      *json.Encoder
+	xml       *xml.Encoder
+}
+
+func NewCustomerStream(w io.Writer, mediaType string) *CustomerStream {
+	return &CustomerStream{w: w, mediaType: mediaType}
+}
+
+// Extension is the file name extension for the stream's media type.
+func (s *CustomerStream) Extension() string {
+	return Extension(s.mediaType)
+}
+
+// Extension is the file name extension of mediaType, one of Supported.
+func Extension(mediaType string) string {
+	switch mediaType {
+	case CSV:
+		return ".csv"
+	case NDJSON:
+		return ".ndjson"
+	case XML:
+		return ".xml"
+	}
+	return ".json"
+}
+
+func (s *CustomerStream) Write(c model.Customer) error {
+	if s.count == 0 {
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+	s.count++
+
+	switch s.mediaType {
+	case CSV:
+		return s.csv.Write(csvRecord(c))
+	case NDJSON:
+		return s.json.Encode(c)
+	case XML:
+		return s.xml.EncodeElement(c, xml.StartElement{Name: xml.Name{Local: "customer"}})
+	}
+	if s.count > 1 {
+		if _, err := io.WriteString(s.w, ","); err != nil {
+			return err
+		}
+	}
+	return s.json.Encode(c)
+}
+
+func (s *CustomerStream) start() error {
+	var err error
+	switch s.mediaType {
+	case CSV:
+		s.csv = csv.NewWriter(s.w)
+		err = s.csv.Write(csvHeader)
+	case NDJSON:
+		s.json = json.NewEncoder(s.w)
+	case XML:
+		_, err = io.WriteString(s.w, xml.Header+"<customers>")
+		s.xml = xml.NewEncoder(s.w)
+	default:
+		_, err = io.WriteString(s.w, "[")
+		s.json = json.NewEncoder(s.w)
+	}
+	return err
+}
+
+// Flush hands what the CSV and XML encoders buffer to the writer.
+func (s *CustomerStream) Flush() error {
+	switch {
+	case s.csv != nil:
+		s.csv.Flush()
+		return s.csv.Error()
+	case s.xml != nil:
+		return s.xml.Flush()
+	}
+	return nil
+}
+
+// Close finishes the document, which is valid even when no customer was
+// written.
+func (s *CustomerStream) Close() error {
+	if s.count == 0 {
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	var err error
+	switch s.mediaType {
+	case XML:
+		_, err = io.WriteString(s.w, "</customers>\n")
+	case CSV, NDJSON:
+	default:
+		_, err = io.WriteString(s.w, "]\n")
+	}
+	return err
+}
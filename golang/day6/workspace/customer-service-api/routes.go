@@ -0,0 +1,118 @@
+package main
+
+import (
+	"api/auth"
+	"api/controllers"
+	"api/metrics"
+	"api/middlewares"
+	"api/openapi"
+	"api/purge"
+	"api/render"
+	"api/settings"
+	"api/validation"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// newRouter registers every route of the service. openapi.json must
+// describe exactly these routes; TestRoutesMatchOpenAPI checks it does.
+func newRouter(config *settings.Config, store *customerStore, validator validation.CustomerValidator,
+	health *controllers.HealthHandler) (*mux.Router, error) {
+	repo := metrics.ObserveRepository(store.repo)
+	h := controllers.NewCustomerHandler(repo, validator)
+
+	r := mux.NewRouter()
+	r.Use(middlewares.MetricsRoute)
+	r.Use(middlewares.LogRequestMiddleware)
+	r.Use(middlewares.ErrorHandlerMiddleware)
+
+	// the document and the UI stay readable without credentials
+	r.Handle("/api/openapi.json", openapi.Handler()).Methods("GET")
+	r.Handle("/docs", openapi.DocsHandler("/api/openapi.json")).Methods("GET")
+
+	api := r.PathPrefix("/api").Subrouter()
+	// by client IP before authenticating, so that failed attempts count
+	// too, and by caller after
+	limit, limitCaller := passThrough, passThrough
+	if config.RateLimit.Enabled {
+		limiter := middlewares.NewRateLimiter(config.RateLimit)
+		limit, limitCaller = limiter.Middleware, limiter.CallerMiddleware
+	}
+	api.Use(limit)
+	read, write := passThrough, passThrough
+	if config.Auth.Enabled {
+		var verifier *auth.Verifier
+		if config.Auth.HasJWTKeys() {
+			var err error
+			if verifier, err = auth.NewVerifier(config.Auth); err != nil {
+				return nil, err
+			}
+		}
+		api.Use(middlewares.Authenticate(verifier, store.keys))
+		read = middlewares.RequireRole(auth.RoleCustomersRead)
+		write = middlewares.RequireRole(auth.RoleCustomersWrite)
+	}
+	api.Use(limitCaller)
+	api.Use(middlewares.NegotiateContentType)
+	api.Use(middlewares.AuthoredByMiddleware)
+	idempotent := middlewares.NewIdempotency(store.idempotency, config.Idempotency.TTL).Middleware
+	// for the routes that answer with JSON whatever the media type of
+	// customers negotiated
+	jsonOnly := middlewares.Produces(render.JSON)
+
+	r.HandleFunc("/", controllers.Home)
+	r.HandleFunc("/healthz", health.HandleHealthz).Methods("GET")
+	r.HandleFunc("/readyz", health.HandleReadyz).Methods("GET")
+	r.Handle("/metrics", metrics.Default.Handler()).Methods("GET")
+
+	api.Handle("/customers", read(http.HandlerFunc(h.HandleGetAllCustomers))).Methods("GET")
+	api.Handle("/customers:export", read(http.HandlerFunc(h.HandleExportCustomers))).Methods("GET")
+	// before /customers/{id}, which would take "search" for an id
+	api.Handle("/customers/search", read(http.HandlerFunc(h.HandleSearchCustomers))).Methods("GET")
+	api.Handle("/customers/{id}", read(http.HandlerFunc(h.HandleGetOneCustomer))).Methods("GET")
+	history := controllers.NewHistoryHandler(repo, store.audit)
+	api.Handle("/customers/{id}/history", read(jsonOnly(http.HandlerFunc(history.HandleGetHistory)))).Methods("GET")
+
+	api.Handle("/customers", write(idempotent(http.HandlerFunc(h.HandlePostOneCustomer)))).Methods("POST")
+	api.Handle("/customers:import", write(jsonOnly(http.HandlerFunc(h.HandleImportCustomers)))).Methods("POST")
+	api.Handle("/customers/{id}", write(http.HandlerFunc(h.HandlePutOneCustomer))).Methods("PUT")
+	api.Handle("/customers/{id}", write(http.HandlerFunc(h.HandlePatchOneCustomer))).Methods("PATCH")
+	api.Handle("/customers/{id}", write(http.HandlerFunc(h.HandleDeleteOneCustomer))).Methods("DELETE")
+	api.Handle("/customers/{id}:restore", write(http.HandlerFunc(h.HandleRestoreCustomer))).Methods("POST")
+
+	// keys, the outbox, webhooks and purges are managed by admins holding a
+	// bearer token, so these routes only exist when authentication is on
+	if config.Auth.Enabled {
+		keys := controllers.NewAPIKeyHandler(store.keys)
+		requireAdmin := middlewares.RequireRole(auth.RoleAdmin)
+		admin := func(next http.Handler) http.Handler {
+			return requireAdmin(jsonOnly(next))
+		}
+		api.Handle("/admin/api-keys", admin(http.HandlerFunc(keys.HandleListAPIKeys))).Methods("GET")
+		api.Handle("/admin/api-keys", admin(http.HandlerFunc(keys.HandleCreateAPIKey))).Methods("POST")
+		api.Handle("/admin/api-keys/{id}", admin(http.HandlerFunc(keys.HandleRevokeAPIKey))).Methods("DELETE")
+
+		events := controllers.NewEventHandler(store.events, relayedSinks(config))
+		api.Handle("/admin/events", admin(http.HandlerFunc(events.HandleListEvents))).Methods("GET")
+		api.Handle("/admin/outbox/{sink}", admin(http.HandlerFunc(events.HandleGetOffset))).Methods("GET")
+		api.Handle("/admin/outbox/{sink}", admin(http.HandlerFunc(events.HandleSetOffset))).Methods("PUT")
+
+		hooks := controllers.NewWebhookHandler(store.webhooks)
+		api.Handle("/admin/webhooks", admin(http.HandlerFunc(hooks.HandleListWebhooks))).Methods("GET")
+		api.Handle("/admin/webhooks", admin(http.HandlerFunc(hooks.HandleCreateWebhook))).Methods("POST")
+		api.Handle("/admin/webhooks/{id}", admin(http.HandlerFunc(hooks.HandleGetWebhook))).Methods("GET")
+		api.Handle("/admin/webhooks/{id}", admin(http.HandlerFunc(hooks.HandleDeleteWebhook))).Methods("DELETE")
+		api.Handle("/admin/webhooks/{id}/deliveries", admin(http.HandlerFunc(hooks.HandleListDeliveries))).Methods("GET")
+		api.Handle("/admin/webhooks/{id}/deliveries/{delivery}:redeliver", admin(http.HandlerFunc(hooks.HandleRedeliver))).Methods("POST")
+
+		purges := controllers.NewPurgeHandler(purge.NewJob(repo, config.Purge))
+		api.Handle("/admin/customers:purge", admin(http.HandlerFunc(purges.HandlePurge))).Methods("POST")
+	}
+	return r, nil
+}
+
+// passThrough stands in for a role check when authentication is off.
+func passThrough(next http.Handler) http.Handler {
+	return next
+}
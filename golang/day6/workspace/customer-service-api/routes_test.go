@@ -0,0 +1,85 @@
+package main
+
+import (
+	"api/controllers"
+	"api/openapi"
+	"api/settings"
+	"api/validation"
+	"appconfig"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestRoutesMatchOpenAPI fails when a route is added, removed or changes
+// method without openapi.json following suit, or the other way round.
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	store, err := openStore("memory", "", appconfig.DB{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// with auth on, so that the admin routes are registered too
+	config := &settings.Config{Sections: settings.Sections{Auth: settings.Auth{Enabled: true, HMACSecret: strings.Repeat("s", 32)}}}
+	r, err := newRouter(config, store, validation.CustomerValidator{}, controllers.NewHealthHandler(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes := []string{}
+	err = r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil // the /api subrouter itself
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{"GET"} // routes without a method restriction
+		}
+		for _, method := range methods {
+			routes = append(routes, method+" "+path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openapi.Spec(), &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	if spec.OpenAPI != "3.1.0" {
+		t.Errorf("wanted openapi 3.1.0, got %v", spec.OpenAPI)
+	}
+	operations := []string{}
+	for path, item := range spec.Paths {
+		for method := range item {
+			switch method {
+			case "get", "put", "post", "delete", "patch", "head", "options", "trace":
+				operations = append(operations, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+
+	slices.Sort(routes)
+	slices.Sort(operations)
+	for _, route := range routes {
+		if !slices.Contains(operations, route) {
+			t.Errorf("route %s is missing from openapi.json", route)
+		}
+	}
+	for _, operation := range operations {
+		if !slices.Contains(routes, operation) {
+			t.Errorf("openapi.json describes %s, which is not a route", operation)
+		}
+	}
+}
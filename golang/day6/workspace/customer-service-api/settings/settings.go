@@ -0,0 +1,179 @@
+// Package settings holds the settings of the customer service that the
+// other customer modules have no use for. They are loaded with appconfig,
+// along with the shared database and server settings.
+package settings
+
+import (
+	"appconfig"
+	"flag"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of the customer service.
+type Config struct {
+	*appconfig.Config
+	Sections
+}
+
+// Sections are the settings of the customer service on top of those of
+// appconfig.Config.
+type Sections struct {
+	CORS        CORS        `config:"cors"`
+	Auth        Auth        `config:"auth"`
+	RateLimit   RateLimit   `config:"rateLimit"`
+	Idempotency Idempotency `config:"idempotency"`
+	Outbox      Outbox      `config:"outbox"`
+	Webhooks    Webhooks    `config:"webhooks"`
+	Purge       Purge       `config:"purge"`
+}
+
+// CORS is the cross-origin policy of the HTTP API. Origins are exact
+// ("https://www.redbus.in"), a subdomain wildcard ("https://*.redbus.in")
+// or "*" for any. Lists are comma separated in env and flags.
+type CORS struct {
+	AllowedOrigins   []string      `config:"allowedOrigins" env:"CORS_ALLOWED_ORIGINS" default:"*"`
+	AllowedMethods   []string      `config:"allowedMethods" env:"CORS_ALLOWED_METHODS" default:"GET,POST,PUT,PATCH,DELETE"`
+	AllowedHeaders   []string      `config:"allowedHeaders" env:"CORS_ALLOWED_HEADERS" default:"Accept,Content-Type,Authorization,X-API-Key,If-Match,If-None-Match,Idempotency-Key"`
+	ExposedHeaders   []string      `config:"exposedHeaders" env:"CORS_EXPOSED_HEADERS" default:"Link,X-Total-Count,X-Request-ID,ETag,Idempotent-Replayed,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,Retry-After"`
+	AllowCredentials bool          `config:"allowCredentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           time.Duration `config:"maxAge" env:"CORS_MAX_AGE" default:"10m"`
+}
+
+// Auth configures authentication of the HTTP API with API keys and JWT
+// bearer tokens. HS256 tokens are checked with HMACSecret, RS256 tokens
+// with the RSA public key in PublicKeyFile (PEM) or the keys of a local
+// JWKSFile; with none of them set only API keys are accepted.
+type Auth struct {
+	Enabled       bool          `config:"enabled" env:"AUTH_ENABLED"`
+	HMACSecret    string        `config:"hmacSecret" env:"AUTH_HMAC_SECRET" secret:"true"`
+	PublicKeyFile string        `config:"publicKeyFile" env:"AUTH_PUBLIC_KEY_FILE"`
+	JWKSFile      string        `config:"jwksFile" env:"AUTH_JWKS_FILE"`
+	Issuer        string        `config:"issuer" env:"AUTH_ISSUER"`
+	Audience      string        `config:"audience" env:"AUTH_AUDIENCE"`
+	Leeway        time.Duration `config:"leeway" env:"AUTH_LEEWAY" default:"30s"`
+}
+
+// RateLimit configures the token bucket every API key, token subject, or
+// client IP for callers without either, draws from: Requests per Period,
+// with bursts of up to Burst requests (Requests when zero). An API key may
+// set its own Requests.
+type RateLimit struct {
+	Enabled  bool          `config:"enabled" env:"RATE_LIMIT_ENABLED" default:"true"`
+	Requests int           `config:"requests" env:"RATE_LIMIT_REQUESTS" default:"120"`
+	Period   time.Duration `config:"period" env:"RATE_LIMIT_PERIOD" default:"1m"`
+	Burst    int           `config:"burst" env:"RATE_LIMIT_BURST"`
+}
+
+// Idempotency configures how long the response to a request carrying an
+// Idempotency-Key is kept for replay.
+type Idempotency struct {
+	TTL time.Duration `config:"ttl" env:"IDEMPOTENCY_TTL" default:"24h"`
+}
+
+// Outbox configures the relay that publishes customer change events to
+// Sinks, some of: log (the application log), file (appended to File as
+// NDJSON) and webhook (POSTed to WebhookURL). Every PollInterval it
+// delivers up to BatchSize events to each sink. Events after an id that
+// is missing, as that of a write yet to commit or rolled back, are held
+// back for GapTimeout; the event of the id is still published, late, if
+// it shows up within GapRetention.
+type Outbox struct {
+	Enabled      bool          `config:"enabled" env:"OUTBOX_ENABLED" default:"true"`
+	Sinks        []string      `config:"sinks" env:"OUTBOX_SINKS" default:"log"`
+	File         string        `config:"file" env:"OUTBOX_FILE"`
+	WebhookURL   string        `config:"webhookUrl" env:"OUTBOX_WEBHOOK_URL"`
+	PollInterval time.Duration `config:"pollInterval" env:"OUTBOX_POLL_INTERVAL" default:"1s"`
+	BatchSize    int           `config:"batchSize" env:"OUTBOX_BATCH_SIZE" default:"100"`
+	GapTimeout   time.Duration `config:"gapTimeout" env:"OUTBOX_GAP_TIMEOUT" default:"5s"`
+	GapRetention time.Duration `config:"gapRetention" env:"OUTBOX_GAP_RETENTION" default:"1h"`
+}
+
+// Webhooks configures delivery of customer events to webhook
+// subscriptions. Workers POST the deliveries that are due, each waiting up
+// to Timeout for an answer. A failed delivery is tried again after a
+// backoff starting at InitialBackoff and doubling up to MaxBackoff, with
+// jitter, until MaxAttempts have failed and it is dead-lettered.
+type Webhooks struct {
+	Enabled        bool          `config:"enabled" env:"WEBHOOKS_ENABLED" default:"true"`
+	Workers        int           `config:"workers" env:"WEBHOOKS_WORKERS" default:"4"`
+	Timeout        time.Duration `config:"timeout" env:"WEBHOOKS_TIMEOUT" default:"10s"`
+	MaxAttempts    int           `config:"maxAttempts" env:"WEBHOOKS_MAX_ATTEMPTS" default:"8"`
+	InitialBackoff time.Duration `config:"initialBackoff" env:"WEBHOOKS_INITIAL_BACKOFF" default:"10s"`
+	MaxBackoff     time.Duration `config:"maxBackoff" env:"WEBHOOKS_MAX_BACKOFF" default:"1h"`
+	PollInterval   time.Duration `config:"pollInterval" env:"WEBHOOKS_POLL_INTERVAL" default:"1s"`
+}
+
+// Purge configures the job that hard-deletes customers once they have
+// been soft-deleted for longer than Retention. When Enabled it runs every
+// Interval; admins can also run it on demand. It is off by default, as
+// what it deletes can not be restored.
+type Purge struct {
+	Enabled   bool          `config:"enabled" env:"PURGE_ENABLED"`
+	Retention time.Duration `config:"retention" env:"PURGE_RETENTION" default:"720h"`
+	Interval  time.Duration `config:"interval" env:"PURGE_INTERVAL" default:"1h"`
+}
+
+// OutboxSinks lists the supported values of Outbox.Sinks.
+var OutboxSinks = []string{"log", "file", "webhook"}
+
+// HasJWTKeys reports whether any key to verify bearer tokens with is
+// configured.
+func (a Auth) HasJWTKeys() bool {
+	return a.HMACSecret != "" || a.PublicKeyFile != "" || a.JWKSFile != ""
+}
+
+// RegisterFlags adds the flags of appconfig.RegisterFlags and those of
+// Sections to fs.
+func RegisterFlags(fs *flag.FlagSet) {
+	appconfig.RegisterFlags(fs, &Sections{})
+}
+
+// Load loads the configuration like appconfig.Load does, Sections
+// included.
+func Load(opts appconfig.Options) (*Config, error) {
+	c := &Config{}
+	opts.Sections = &c.Sections
+	shared, err := appconfig.Load(opts)
+	if err != nil {
+		return nil, err
+	}
+	c.Config = shared
+	return c, nil
+}
+
+// Validate checks the values of s; see appconfig.Validator.
+func (s *Sections) Validate(check appconfig.Check) {
+	check(!s.CORS.AllowCredentials || !slices.Contains(s.CORS.AllowedOrigins, "*"),
+		"cors.allowCredentials", "cannot be combined with allowedOrigins \"*\"; list the origins instead")
+	check(s.CORS.MaxAge >= 0, "cors.maxAge", "must not be negative")
+	check(s.Auth.HMACSecret == "" || len(s.Auth.HMACSecret) >= 32,
+		"auth.hmacSecret", "must be at least 32 characters")
+	check(s.Auth.Leeway >= 0, "auth.leeway", "must not be negative")
+	check(s.RateLimit.Requests > 0, "rateLimit.requests", "must be positive")
+	check(s.RateLimit.Period > 0, "rateLimit.period", "must be positive")
+	check(s.RateLimit.Burst >= 0, "rateLimit.burst", "must not be negative")
+	check(s.Idempotency.TTL > 0, "idempotency.ttl", "must be positive")
+	for _, sink := range s.Outbox.Sinks {
+		check(slices.Contains(OutboxSinks, sink), "outbox.sinks", "unsupported sink %q; use some of %v", sink, OutboxSinks)
+	}
+	check(!slices.Contains(s.Outbox.Sinks, "file") || s.Outbox.File != "",
+		"outbox.file", "must be set for the file sink")
+	check(!slices.Contains(s.Outbox.Sinks, "webhook") || strings.HasPrefix(s.Outbox.WebhookURL, "http://") ||
+		strings.HasPrefix(s.Outbox.WebhookURL, "https://"), "outbox.webhookUrl", "must be an http or https URL for the webhook sink")
+	check(s.Outbox.PollInterval > 0, "outbox.pollInterval", "must be positive")
+	check(s.Outbox.BatchSize > 0, "outbox.batchSize", "must be positive")
+	check(s.Outbox.GapTimeout >= 0, "outbox.gapTimeout", "must not be negative")
+	check(s.Outbox.GapRetention >= s.Outbox.GapTimeout,
+		"outbox.gapRetention", "must not be below outbox.gapTimeout (%v)", s.Outbox.GapTimeout)
+	check(s.Webhooks.Workers > 0, "webhooks.workers", "must be positive")
+	check(s.Webhooks.Timeout > 0, "webhooks.timeout", "must be positive")
+	check(s.Webhooks.MaxAttempts > 0, "webhooks.maxAttempts", "must be positive")
+	check(s.Webhooks.InitialBackoff > 0, "webhooks.initialBackoff", "must be positive")
+	check(s.Webhooks.MaxBackoff >= s.Webhooks.InitialBackoff,
+		"webhooks.maxBackoff", "must not be below webhooks.initialBackoff (%v)", s.Webhooks.InitialBackoff)
+	check(s.Webhooks.PollInterval > 0, "webhooks.pollInterval", "must be positive")
+	check(s.Purge.Retention > 0, "purge.retention", "must be positive")
+	check(s.Purge.Interval > 0, "purge.interval", "must be positive")
+}
@@ -0,0 +1,103 @@
+package settings
+
+import (
+	"appconfig"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func env(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("defaults only", func(t *testing.T) {
+		c, err := Load(appconfig.Options{Getenv: env(nil)})
+		if err != nil {
+			t.Fatalf("was not expecting an error, got %v", err)
+		}
+		if c.Server.Port != 7788 || c.Auth.Enabled || !c.RateLimit.Enabled || c.Source("rateLimit.enabled") != "default" {
+			t.Errorf("wanted the defaults, got %+v", c.Sections)
+		}
+	})
+
+	t.Run("flags", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		RegisterFlags(fs)
+		if err := fs.Parse([]string{"-auth-enabled", "-rateLimit-enabled=false", "-db-driver", "sqlite"}); err != nil {
+			t.Fatalf("was not expecting an error, got %v", err)
+		}
+		c, err := Load(appconfig.Options{Flags: fs, Getenv: env(nil)})
+		if err != nil {
+			t.Fatalf("was not expecting an error, got %v", err)
+		}
+		if !c.Auth.Enabled || c.RateLimit.Enabled || c.DB.Driver != "sqlite" {
+			t.Errorf("wanted auth on, rate limits off and sqlite, got %v, %v and %v", c.Auth.Enabled, c.RateLimit.Enabled, c.DB.Driver)
+		}
+	})
+
+	t.Run("lists", func(t *testing.T) {
+		filename := writeFile(t, "config.yaml", "cors:\n  allowedOrigins:\n    - https://www.redbus.in\n    - https://*.redbus.in\n")
+		c, err := Load(appconfig.Options{File: filename, Getenv: env(map[string]string{"CORS_ALLOWED_METHODS": "GET, POST"})})
+		if err != nil {
+			t.Fatalf("was not expecting an error, got %v", err)
+		}
+		if len(c.CORS.AllowedOrigins) != 2 || c.CORS.AllowedOrigins[1] != "https://*.redbus.in" {
+			t.Errorf("wanted 2 origins from the file, got %v", c.CORS.AllowedOrigins)
+		}
+		if len(c.CORS.AllowedMethods) != 2 || c.CORS.AllowedMethods[1] != "POST" {
+			t.Errorf("wanted GET and POST from env, got %v", c.CORS.AllowedMethods)
+		}
+	})
+
+	t.Run("secrets", func(t *testing.T) {
+		c, err := Load(appconfig.Options{Getenv: env(map[string]string{"AUTH_HMAC_SECRET": strings.Repeat("s", 32)})})
+		if err != nil {
+			t.Fatalf("was not expecting an error, got %v", err)
+		}
+		if s := c.String(); strings.Contains(s, strings.Repeat("s", 32)) || !strings.Contains(s, "env AUTH_HMAC_SECRET") {
+			t.Errorf("wanted the secret redacted with its source, got\n%v", s)
+		}
+	})
+
+	negativeTests := []struct {
+		name   string
+		file   string
+		errmsg string
+	}{
+		{"credentials with any origin", `{"cors": {"allowCredentials": true}}`, "cors.allowCredentials"},
+		{"short hmac secret", `{"auth": {"hmacSecret": "short"}}`, "auth.hmacSecret"},
+		{"no rate", `{"rateLimit": {"requests": 0}}`, "rateLimit.requests"},
+		{"no idempotency ttl", `{"idempotency": {"ttl": "0s"}}`, "idempotency.ttl"},
+		{"unknown outbox sink", `{"outbox": {"sinks": ["log", "kafka"]}}`, `unsupported sink "kafka"`},
+		{"file sink without file", `{"outbox": {"sinks": ["file"]}}`, "outbox.file"},
+		{"gap retention below timeout", `{"outbox": {"gapTimeout": "1m", "gapRetention": "10s"}}`, "outbox.gapRetention"},
+		{"webhook sink without url", `{"outbox": {"sinks": ["webhook"], "webhookUrl": "ftp://x"}}`, "outbox.webhookUrl"},
+		{"backoff cap below start", `{"webhooks": {"initialBackoff": "1m", "maxBackoff": "10s"}}`, "webhooks.maxBackoff"},
+		{"no purge retention", `{"purge": {"retention": "0s"}}`, "purge.retention"},
+		{"unknown setting", `{"auth": {"enabld": true}}`, `unknown setting "auth.enabld"`},
+	}
+	for _, nt := range negativeTests {
+		t.Run(nt.name, func(t *testing.T) {
+			filename := writeFile(t, "config.json", nt.file)
+			_, err := Load(appconfig.Options{File: filename, Getenv: env(nil)})
+			if err == nil {
+				t.Fatal("was expecting an error; did not get one")
+			}
+			if !strings.Contains(err.Error(), nt.errmsg) {
+				t.Errorf("wanted error containing '%v', got '%v'", nt.errmsg, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"api/dao"
+	"api/migrations"
+	"appconfig"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// customerStore is the repository chosen with -store, plus the database
+// pool behind it when there is one. API keys, idempotency keys, the
+// outbox of customer events, the audit trail and webhooks live in the
+// same database; the memory and file stores keep them in memory.
+type customerStore struct {
+	repo        dao.CustomerRepository
+	keys        dao.APIKeyRepository
+	idempotency dao.IdempotencyRepository
+	events      dao.CustomerEventRepository
+	audit       dao.CustomerAuditRepository
+	webhooks    dao.WebhookRepository
+	db          *sql.DB
+}
+
+func openStore(store, dataFile string, dbConfig appconfig.DB, migrate bool) (*customerStore, error) {
+	switch store {
+	case "db":
+		db, err := dao.OpenDb(dbConfig)
+		if err != nil {
+			return nil, err
+		}
+		// an in-memory database always starts out empty
+		if migrate || dbConfig.InMemory() {
+			if err := applyMigrations(db, dbConfig.Driver); err != nil {
+				db.Close()
+				return nil, err
+			}
+		}
+		repo, err := dao.NewSqlCustomerRepository(db)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return &customerStore{
+			repo:        repo,
+			keys:        dao.NewSqlAPIKeyRepository(db),
+			idempotency: dao.NewSqlIdempotencyRepository(db),
+			events:      dao.NewSqlCustomerEventRepository(db),
+			audit:       dao.NewSqlCustomerAuditRepository(db),
+			webhooks:    dao.NewSqlWebhookRepository(db),
+			db:          db,
+		}, nil
+	case "memory":
+		repo := dao.NewMemoryCustomerRepository()
+		return &customerStore{
+			repo:        repo,
+			keys:        dao.NewMemoryAPIKeyRepository(),
+			idempotency: dao.NewMemoryIdempotencyRepository(),
+			events:      repo.Events(),
+			audit:       repo.Audit(),
+			webhooks:    dao.NewMemoryWebhookRepository(),
+		}, nil
+	case "file":
+		repo, err := dao.NewJsonFileCustomerRepository(dataFile)
+		if err != nil {
+			return nil, err
+		}
+		return &customerStore{
+			repo:        repo,
+			keys:        dao.NewMemoryAPIKeyRepository(),
+			idempotency: dao.NewMemoryIdempotencyRepository(),
+			events:      repo.Events(),
+			audit:       repo.Audit(),
+			webhooks:    dao.NewMemoryWebhookRepository(),
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown store %q; use db, memory or file", store)
+}
+
+// Ping checks that the database answers; stores without one are always
+// reachable.
+func (s *customerStore) Ping(ctx context.Context) error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.PingContext(ctx)
+}
+
+// Close releases the prepared statements and then the connection pool.
+func (s *customerStore) Close() {
+	if repo, ok := s.repo.(*dao.SqlCustomerRepository); ok {
+		repo.Close()
+	}
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func applyMigrations(db *sql.DB, dialect string) error {
+	migrator, err := migrations.New(db, dialect)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up()
+	for _, m := range applied {
+		log.Printf("applied migration %04d_%s", m.Version, m.Name)
+	}
+	return err
+}
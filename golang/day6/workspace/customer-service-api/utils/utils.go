@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"unicode/utf8"
 )
 
 func CheckForError(err error) {
@@ -20,3 +21,14 @@ func Input(message string) string {
 	CheckForError(err)
 	return strings.TrimSpace(val)
 }
+
+// Truncate cuts s to at most max bytes, between runes.
+func Truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
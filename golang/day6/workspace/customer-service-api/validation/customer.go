@@ -0,0 +1,126 @@
+package validation
+
+import (
+	"api/model"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxFieldLength matches the VARCHAR(50) columns of the CUSTOMERS table.
+const MaxFieldLength = 50
+
+// CustomerValidator checks customers before they reach the repository.
+// When KnownCities is empty any city is accepted.
+type CustomerValidator struct {
+	KnownCities map[string]bool
+}
+
+// LoadCustomerValidator reads a JSON array of known city names from
+// filename. A missing file disables the city check.
+func LoadCustomerValidator(filename string) (CustomerValidator, error) {
+	var v CustomerValidator
+
+	content, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return v, nil
+	}
+	if err != nil {
+		return v, err
+	}
+
+	var cities []string
+	if err := json.Unmarshal(content, &cities); err != nil {
+		return v, fmt.Errorf("%s: %w", filename, err)
+	}
+	v.KnownCities = map[string]bool{}
+	for _, city := range cities {
+		v.KnownCities[strings.ToLower(city)] = true
+	}
+	return v, nil
+}
+
+// Validate trims surrounding spaces from every field of c and returns one
+// FieldError per problem found; nil means c is valid.
+func (v CustomerValidator) Validate(c *model.Customer) []model.FieldError {
+	c.Name = strings.TrimSpace(c.Name)
+	c.City = strings.TrimSpace(c.City)
+	c.Email = strings.TrimSpace(c.Email)
+
+	var errs []model.FieldError
+	errs = append(errs, v.name(c.Name)...)
+	errs = append(errs, v.city(c.City)...)
+	errs = append(errs, v.email(c.Email)...)
+	return errs
+}
+
+// ValidatePatch is Validate for the fields present in a merge-patch.
+func (v CustomerValidator) ValidatePatch(p *model.CustomerPatch) []model.FieldError {
+	var errs []model.FieldError
+	if p.Name != nil {
+		*p.Name = strings.TrimSpace(*p.Name)
+		errs = append(errs, v.name(*p.Name)...)
+	}
+	if p.City != nil {
+		*p.City = strings.TrimSpace(*p.City)
+		errs = append(errs, v.city(*p.City)...)
+	}
+	if p.Email != nil {
+		*p.Email = strings.TrimSpace(*p.Email)
+		errs = append(errs, v.email(*p.Email)...)
+	}
+	return errs
+}
+
+func (v CustomerValidator) name(name string) []model.FieldError {
+	if name == "" {
+		return fieldError("name", "is required")
+	}
+	return maxLength("name", name)
+}
+
+func (v CustomerValidator) city(city string) []model.FieldError {
+	if city == "" {
+		return nil
+	}
+	if errs := maxLength("city", city); errs != nil {
+		return errs
+	}
+	if len(v.KnownCities) > 0 && !v.KnownCities[strings.ToLower(city)] {
+		return fieldError("city", fmt.Sprintf("%q is not a known city", city))
+	}
+	return nil
+}
+
+func (v CustomerValidator) email(email string) []model.FieldError {
+	if email == "" {
+		return fieldError("email", "is required")
+	}
+	if errs := maxLength("email", email); errs != nil {
+		return errs
+	}
+	// ParseAddress accepts "Name <addr>" as well; only a bare addr-spec is
+	// allowed here
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return fieldError("email", "is not a valid email address")
+	}
+	if !strings.Contains(email[strings.LastIndex(email, "@")+1:], ".") {
+		return fieldError("email", "must have a fully qualified domain")
+	}
+	return nil
+}
+
+func maxLength(field, value string) []model.FieldError {
+	if utf8.RuneCountInString(value) > MaxFieldLength {
+		return fieldError(field, fmt.Sprintf("must be at most %d characters", MaxFieldLength))
+	}
+	return nil
+}
+
+func fieldError(field, message string) []model.FieldError {
+	return []model.FieldError{{Field: field, Message: message}}
+}
@@ -0,0 +1,48 @@
+package validation
+
+import (
+	"api/model"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	v := CustomerValidator{KnownCities: map[string]bool{"bangalore": true}}
+
+	subtests := []struct {
+		name     string
+		customer model.Customer
+		fields   []string
+	}{
+		{"valid customer", model.Customer{Name: "Vinod", City: "Bangalore", Email: "vinod@vinod.co"}, nil},
+		{"city is optional", model.Customer{Name: "Vinod", Email: "vinod@vinod.co"}, nil},
+		{"blank name", model.Customer{Name: "   ", Email: "vinod@vinod.co"}, []string{"name"}},
+		{"too long name", model.Customer{Name: strings.Repeat("v", 51), Email: "vinod@vinod.co"}, []string{"name"}},
+		{"unknown city", model.Customer{Name: "Vinod", City: "Atlantis", Email: "vinod@vinod.co"}, []string{"city"}},
+		{"malformed email", model.Customer{Name: "Vinod", Email: "vinod.vinod.co"}, []string{"email"}},
+		{"email with display name", model.Customer{Name: "Vinod", Email: "Vinod <vinod@vinod.co>"}, []string{"email"}},
+		{"email without domain", model.Customer{Name: "Vinod", Email: "vinod@localhost"}, []string{"email"}},
+		{"everything wrong", model.Customer{City: "Atlantis"}, []string{"name", "city", "email"}},
+	}
+	for _, st := range subtests {
+		t.Run(st.name, func(t *testing.T) {
+			errs := v.Validate(&st.customer)
+			if len(errs) != len(st.fields) {
+				t.Fatalf("wanted errors for %v, got %v", st.fields, errs)
+			}
+			for i, e := range errs {
+				if e.Field != st.fields[i] {
+					t.Errorf("wanted error for %v, got %v", st.fields[i], e.Field)
+				}
+			}
+		})
+	}
+
+	t.Run("patch with blank name", func(t *testing.T) {
+		blank := " "
+		errs := v.ValidatePatch(&model.CustomerPatch{Name: &blank})
+		if len(errs) != 1 || errs[0].Field != "name" {
+			t.Errorf("wanted an error for name, got %v", errs)
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package webhooks
+
+import (
+	"api/dao"
+	"api/model"
+	"api/settings"
+	"api/utils"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxErrorLength is the most of an error kept with an attempt.
+const maxErrorLength = 500
+
+// Deliverer POSTs the deliveries that are due with a pool of workers. It
+// claims as many deliveries as there are workers at a time, for long
+// enough to try them, so that several instances can share the work.
+type Deliverer struct {
+	repo   dao.WebhookRepository
+	client *http.Client
+	config settings.Webhooks
+	now    func() time.Time
+
+	// a random duration in [0, d), taken off backoffs
+	jitter func(d time.Duration) time.Duration
+}
+
+func NewDeliverer(repo dao.WebhookRepository, config settings.Webhooks) *Deliverer {
+	return &Deliverer{
+		repo:   repo,
+		client: &http.Client{Timeout: config.Timeout},
+		config: config,
+		now:    time.Now,
+		jitter: func(d time.Duration) time.Duration {
+			if d <= 0 {
+				return 0
+			}
+			return rand.N(d)
+		},
+	}
+}
+
+// Run delivers until ctx is done. Deliveries cut short by ctx are tried
+// again once their claim runs out.
+func (d *Deliverer) Run(ctx context.Context) {
+	jobs := make(chan model.WebhookDelivery)
+	defer close(jobs)
+	var wg sync.WaitGroup
+	for i := 0; i < d.config.Workers; i++ {
+		go func() {
+			for delivery := range jobs {
+				d.deliver(ctx, delivery)
+				wg.Done()
+			}
+		}()
+	}
+
+	for {
+		now := d.now()
+		claimed, err := d.repo.ClaimDeliveries(ctx, now, now.Add(d.config.Timeout+time.Minute), d.config.Workers)
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("claiming webhook deliveries", "error", err)
+		}
+		wg.Add(len(claimed))
+		for _, delivery := range claimed {
+			jobs <- delivery
+		}
+		wg.Wait()
+
+		wait := d.config.PollInterval
+		if len(claimed) == d.config.Workers {
+			// there may be more due
+			wait = 0
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// deliver makes one attempt at delivery and records how it went.
+func (d *Deliverer) deliver(ctx context.Context, delivery model.WebhookDelivery) {
+	logger := slog.Default().With("delivery_id", delivery.Id, "subscription_id", delivery.SubscriptionId)
+	sub, err := d.repo.FindSubscription(ctx, delivery.SubscriptionId)
+	if errors.Is(err, dao.ErrWebhookNotFound) {
+		// unsubscribed meanwhile; the delivery went with it
+		return
+	}
+	if err != nil {
+		logger.Warn("finding webhook subscription", "error", err)
+		return
+	}
+
+	start := d.now()
+	status, err := d.post(ctx, sub, delivery, start)
+	if ctx.Err() != nil {
+		return
+	}
+	now := d.now()
+	delivery.AttemptCount++
+	delivery.UpdatedAt = now
+	attempt := model.WebhookAttempt{
+		Attempt:     delivery.AttemptCount,
+		StatusCode:  status,
+		DurationMs:  now.Sub(start).Milliseconds(),
+		AttemptedAt: start,
+	}
+
+	switch {
+	case err == nil && status >= 200 && status <= 299:
+		delivery.Status, delivery.NextAttemptAt, delivery.LastError = model.DeliverySucceeded, nil, ""
+	default:
+		if err != nil {
+			attempt.Error = utils.Truncate(err.Error(), maxErrorLength)
+			delivery.LastError = attempt.Error
+		} else {
+			delivery.LastError = strconv.Itoa(status) + " " + http.StatusText(status)
+		}
+		if delivery.AttemptCount >= d.config.MaxAttempts {
+			delivery.Status, delivery.NextAttemptAt = model.DeliveryDead, nil
+			logger.Warn("webhook delivery dead-lettered", "attempts", delivery.AttemptCount, "error", delivery.LastError)
+		} else {
+			next := now.Add(d.backoff(delivery.AttemptCount))
+			delivery.NextAttemptAt = &next
+		}
+	}
+
+	if err := d.repo.RecordAttempt(context.WithoutCancel(ctx), delivery, attempt); err != nil {
+		logger.Warn("recording webhook attempt", "error", err)
+	}
+}
+
+// post sends the payload of delivery to sub, returning the status it was
+// answered with.
+func (d *Deliverer) post(ctx context.Context, sub model.WebhookSubscription, delivery model.WebhookDelivery, at time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := at.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.Id, 10))
+	req.Header.Set(EventIdHeader, strconv.FormatInt(delivery.EventId, 10))
+	req.Header.Set(EventTypeHeader, delivery.EventType)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	return resp.StatusCode, nil
+}
+
+// backoff is how long to wait after the attempt-th failure:
+// InitialBackoff, doubled for every failure before it, up to MaxBackoff.
+// Up to half of it is taken off at random, so that deliveries failing
+// together do not all come back at once.
+func (d *Deliverer) backoff(attempt int) time.Duration {
+	wait := d.config.MaxBackoff
+	if shift := attempt - 1; shift < 32 {
+		if b := d.config.InitialBackoff << shift; b > 0 && b < wait {
+			wait = b
+		}
+	}
+	return wait - d.jitter(wait/2)
+}
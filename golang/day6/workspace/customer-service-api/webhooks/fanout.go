@@ -0,0 +1,58 @@
+package webhooks
+
+import (
+	"api/dao"
+	"api/model"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// SinkName is the name of Fanout among the outbox sinks.
+const SinkName = "webhooks"
+
+// Fanout is the outbox sink of the webhooks: it queues a delivery of each
+// event to every subscription wanting its type. Deliveries are unique per
+// event and subscription, so events relayed again are not sent twice.
+type Fanout struct {
+	repo dao.WebhookRepository
+	now  func() time.Time
+}
+
+func NewFanout(repo dao.WebhookRepository) *Fanout {
+	return &Fanout{repo: repo, now: time.Now}
+}
+
+func (f *Fanout) Name() string { return SinkName }
+
+func (f *Fanout) Publish(ctx context.Context, event model.CustomerEvent) error {
+	subs, err := f.repo.FindSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	now := f.now()
+	for _, sub := range subs {
+		if !sub.Wants(event.Type) {
+			continue
+		}
+		err := f.repo.AddDelivery(ctx, model.WebhookDelivery{
+			SubscriptionId: sub.Id,
+			EventId:        event.Id,
+			EventType:      event.Type,
+			Payload:        payload,
+			Status:         model.DeliveryPending,
+			NextAttemptAt:  &now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+// Package webhooks delivers customer events to the URLs partners subscribe
+// with. Events reach it through the outbox, as the sink Fanout, which
+// queues a delivery per subscription; a Deliverer POSTs them, signed with
+// the secret of the subscription, trying failed ones again with backoff
+// until they succeed or are dead-lettered.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// Headers of every delivery besides Content-Type.
+const (
+	SignatureHeader = "X-Signature"
+	TimestampHeader = "X-Signature-Timestamp"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	EventIdHeader   = "X-Event-Id"
+	EventTypeHeader = "X-Event-Type"
+)
+
+// Sign returns the X-Signature of body sent at timestamp, in Unix seconds:
+// "sha256=" and the HMAC-SHA256, keyed with secret, of the timestamp, a
+// dot and body, in hex. Signing the timestamp lets receivers turn away
+// old deliveries sent again by someone else.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the one of body sent at timestamp,
+// comparing in constant time.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
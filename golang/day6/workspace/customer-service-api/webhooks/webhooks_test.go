@@ -0,0 +1,208 @@
+package webhooks
+
+import (
+	"api/dao"
+	"api/model"
+	"api/settings"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const secret = "partner-secret-0123456789"
+
+// receiver is a webhook endpoint answering with the statuses in order,
+// then 204, and checking every signature.
+type receiver struct {
+	t        *testing.T
+	mu       sync.Mutex
+	statuses []int
+	received []string
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	timestamp, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+	if !Verify(secret, timestamp, body, r.Header.Get(SignatureHeader)) {
+		rc.t.Errorf("wanted a valid signature, got %q", r.Header.Get(SignatureHeader))
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.received = append(rc.received, r.Header.Get(EventTypeHeader))
+	status := http.StatusNoContent
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+var testConfig = settings.Webhooks{
+	Workers: 2, Timeout: time.Second, MaxAttempts: 3,
+	InitialBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond, PollInterval: time.Millisecond,
+}
+
+// subscribe returns a repository with a subscription of rc to types, after
+// a customer.created and a customer.deleted event went through Fanout.
+func subscribe(t *testing.T, rc *receiver, types ...string) (*dao.MemoryWebhookRepository, model.WebhookSubscription) {
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	repo := dao.NewMemoryWebhookRepository()
+	sub, _ := repo.CreateSubscription(ctx, model.WebhookSubscription{URL: server.URL, Events: types, Secret: secret})
+	fanout := NewFanout(repo)
+	for id, eventType := range []string{model.CustomerCreated, model.CustomerDeleted} {
+		if err := fanout.Publish(ctx, model.CustomerEvent{Id: int64(id + 1), Type: eventType, CustomerId: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo, sub
+}
+
+func TestSign(t *testing.T) {
+	signature := Sign(secret, 1700000000, []byte(`{"id":1}`))
+	if len(signature) != len("sha256=")+64 || !Verify(secret, 1700000000, []byte(`{"id":1}`), signature) {
+		t.Errorf("wanted a valid signature, got %q", signature)
+	}
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      string
+	}{
+		{"other secret", "another-secret", 1700000000, `{"id":1}`},
+		{"other timestamp", secret, 1700000001, `{"id":1}`},
+		{"other body", secret, 1700000000, `{"id":2}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if Verify(tt.secret, tt.timestamp, []byte(tt.body), signature) {
+				t.Error("wanted the signature rejected")
+			}
+		})
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("retried until it succeeds", func(t *testing.T) {
+		rc := &receiver{t: t, statuses: []int{500, 503}}
+		repo, sub := subscribe(t, rc, model.CustomerCreated)
+		d := NewDeliverer(repo, testConfig)
+		d.jitter = func(time.Duration) time.Duration { return 0 }
+
+		for i := 0; i < 3; i++ {
+			claimed, _ := repo.ClaimDeliveries(ctx, time.Now().Add(time.Hour), time.Now().Add(time.Hour), 10)
+			if len(claimed) != 1 {
+				t.Fatalf("attempt %d: wanted one delivery due, got %v", i+1, claimed)
+			}
+			d.deliver(ctx, claimed[0])
+		}
+
+		deliveries, _ := repo.FindDeliveries(ctx, sub.Id, "", 10)
+		if len(deliveries) != 1 || deliveries[0].Status != model.DeliverySucceeded || len(deliveries[0].Attempts) != 3 {
+			t.Fatalf("wanted one succeeded delivery after 3 attempts, got %+v", deliveries)
+		}
+		for i, status := range []int{500, 503, 204} {
+			if got := deliveries[0].Attempts[i]; got.StatusCode != status || got.Attempt != i+1 {
+				t.Errorf("attempt %d: wanted %v, got %+v", i+1, status, got)
+			}
+		}
+		if len(rc.received) != 3 || rc.received[0] != model.CustomerCreated {
+			t.Errorf("wanted 3 customer.created requests, got %v", rc.received)
+		}
+	})
+
+	t.Run("dead-lettered", func(t *testing.T) {
+		rc := &receiver{t: t, statuses: []int{500, 500, 500, 500, 500, 500}}
+		repo, sub := subscribe(t, rc, model.CustomerCreated, model.CustomerDeleted)
+		d := NewDeliverer(repo, testConfig)
+
+		for {
+			claimed, _ := repo.ClaimDeliveries(ctx, time.Now().Add(time.Hour), time.Now().Add(time.Hour), 10)
+			if len(claimed) == 0 {
+				break
+			}
+			for _, delivery := range claimed {
+				d.deliver(ctx, delivery)
+			}
+		}
+
+		dead, _ := repo.FindDeliveries(ctx, sub.Id, model.DeliveryDead, 10)
+		if len(dead) != 2 || dead[0].AttemptCount != testConfig.MaxAttempts || dead[0].LastError != "500 Internal Server Error" {
+			t.Fatalf("wanted both deliveries dead after %d attempts, got %+v", testConfig.MaxAttempts, dead)
+		}
+
+		// a redelivered delivery gets another go
+		repo.Redeliver(ctx, sub.Id, dead[0].Id, time.Now())
+		claimed, _ := repo.ClaimDeliveries(ctx, time.Now(), time.Now().Add(time.Hour), 10)
+		if len(claimed) != 1 {
+			t.Fatalf("wanted the redelivered delivery due, got %v", claimed)
+		}
+		d.deliver(ctx, claimed[0])
+		if succeeded, _ := repo.FindDeliveries(ctx, sub.Id, model.DeliverySucceeded, 10); len(succeeded) != 1 {
+			t.Errorf("wanted one succeeded delivery, got %+v", succeeded)
+		}
+	})
+}
+
+func TestRun(t *testing.T) {
+	rc := &receiver{t: t, statuses: []int{500}}
+	repo, sub := subscribe(t, rc, model.CustomerCreated, model.CustomerDeleted)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewDeliverer(repo, testConfig).Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		succeeded, _ := repo.FindDeliveries(ctx, sub.Id, model.DeliverySucceeded, 10)
+		if len(succeeded) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("wanted both deliveries to succeed, got %+v", succeeded)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestBackoff(t *testing.T) {
+	config := settings.Webhooks{InitialBackoff: 10 * time.Second, MaxBackoff: time.Minute}
+	d := NewDeliverer(nil, config)
+	tests := []struct {
+		attempt int
+		jitter  bool
+		wanted  time.Duration
+	}{
+		{1, false, 10 * time.Second},
+		{2, false, 20 * time.Second},
+		{3, false, 40 * time.Second},
+		{4, false, time.Minute},
+		{100, false, time.Minute},
+		// at most half is taken off
+		{2, true, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		d.jitter = func(d time.Duration) time.Duration {
+			if tt.jitter {
+				return d
+			}
+			return 0
+		}
+		if got := d.backoff(tt.attempt); got != tt.wanted {
+			t.Errorf("attempt %d: wanted %v, got %v", tt.attempt, tt.wanted, got)
+		}
+	}
+}